@@ -0,0 +1,64 @@
+package httpcache
+
+import (
+	"net/http"
+)
+
+// AuthorizationPolicy decides how the responses to requests carrying an Authorization header are
+// cached. See CacheOptions.Authorization.
+type AuthorizationPolicy int
+
+const (
+	// AuthorizationDefault stores them in private clients, and only when explicitly allowed by
+	// public, must-revalidate or s-maxage in shared ones (see CacheOptions.SharedCache)
+	AuthorizationDefault AuthorizationPolicy = iota
+	// AuthorizationNever never stores them
+	AuthorizationNever
+	// AuthorizationExplicit only stores them when public, must-revalidate or s-maxage explicitly
+	// allows it, even in private clients
+	AuthorizationExplicit
+	// AuthorizationPerCredentials stores them under keys derived from a digest of the Authorization
+	// header, so that they are only served to requests carrying the same credentials
+	AuthorizationPerCredentials
+)
+
+// authorizationAllows returns true if resp, a response to req with the given cache control
+// directives, can be stored according to the Authorization policy of the client
+func (cc *CachedClient) authorizationAllows(req *http.Request, respCacheControl cacheControl) bool {
+	if req.Header.Get("Authorization") == "" {
+		return true
+	}
+	switch cc.Options.Authorization {
+	case AuthorizationNever:
+		return false
+	case AuthorizationPerCredentials:
+		return true
+	case AuthorizationExplicit:
+		return explicitlyShared(respCacheControl)
+	}
+	return !cc.Options.SharedCache || explicitlyShared(respCacheControl)
+}
+
+// explicitlyShared returns true if a response with the given cache control directives may be
+// stored by shared caches even if the request was authorized (RFC 9111 section 3.5)
+func explicitlyShared(respCacheControl cacheControl) bool {
+	for _, directive := range []string{"public", "must-revalidate", "s-maxage"} {
+		if _, ok := respCacheControl[directive]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialsPrefix returns the key prefix isolating the entries of the credentials of req, if the
+// client stores authorized responses per credentials
+func (cc *CachedClient) credentialsPrefix(req *http.Request) string {
+	if cc.Options.Authorization != AuthorizationPerCredentials {
+		return ""
+	}
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return ""
+	}
+	return "credentials:" + HashString(cc.Options.Hasher, authorization) + " "
+}
@@ -0,0 +1,87 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorizationPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		policy       AuthorizationPolicy
+		shared       bool
+		cacheControl string
+		stored       bool
+	}{
+		{"default", AuthorizationDefault, false, "max-age=60", true},
+		{"default shared", AuthorizationDefault, true, "max-age=60", false},
+		{"never", AuthorizationNever, false, "max-age=60, public", false},
+		{"explicit", AuthorizationExplicit, false, "max-age=60", false},
+		{"explicit public", AuthorizationExplicit, false, "max-age=60, public", true},
+		{"per credentials shared", AuthorizationPerCredentials, true, "max-age=60", true},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", tc.cacheControl)
+			w.Write([]byte("body"))
+		}))
+		cache := NewMemoryCache()
+		client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{
+			SharedCache:   tc.shared,
+			Authorization: tc.policy,
+		}}
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		server.Close()
+		if stored := len(cache.Keys()) == 1; stored != tc.stored {
+			t.Fatalf("%s: got stored %v, want %v", tc.name, stored, tc.stored)
+		}
+	}
+}
+
+func TestAuthorizationPerCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}, Options: CacheOptions{
+		Authorization: AuthorizationPerCredentials,
+	}}
+	do := func(authorization string) string {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body)
+	}
+
+	for _, authorization := range []string{"Bearer a", "Bearer b", "", "Bearer a", "Bearer b", ""} {
+		if got := do(authorization); got != authorization {
+			t.Fatalf("got body %q for credentials %q", got, authorization)
+		}
+	}
+	keys := client.Cache.(KeyLister).Keys()
+	if len(keys) != 3 {
+		t.Fatalf("got keys %v, want one per credentials", keys)
+	}
+	for _, key := range keys {
+		if strings.Contains(key, "Bearer") {
+			t.Fatalf("got key %q leaking the credentials", key)
+		}
+	}
+}
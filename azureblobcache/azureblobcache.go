@@ -0,0 +1,137 @@
+// Package azureblobcache provides an implementation of httpcache.CacheV2 that stores responses as
+// block blobs of an Azure Blob Storage container, through the Blob service REST API.
+//
+// Requests are authorized either by a SAS token in the container URL, or by the given http.Client
+// (for instance, one whose transport adds Azure AD bearer tokens).
+package azureblobcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+)
+
+// apiVersion is the Blob service REST API version requests are made with
+const apiVersion = "2020-10-02"
+
+// expiresHeader holds the expiration time of a blob, in unix seconds. Azure metadata names must be
+// valid C# identifiers, hence no dashes.
+const expiresHeader = "X-Ms-Meta-Httpcacheexpires"
+
+// now returns the current time, and is replaced in tests
+var now = time.Now
+
+// Cache is an implementation of httpcache.CacheV2 storing every entry as a block blob named after
+// the hash of its key, below a prefix of a container. The expiration of entries with a TTL is
+// stored in the blob metadata and checked on every read. Expired blobs aren't deleted: configure a
+// lifecycle management policy on the storage account to remove them.
+//
+// Use httpcache.AdaptCacheV2 to use it where a httpcache.Cache is expected.
+type Cache struct {
+	client    *http.Client
+	container *url.URL
+	prefix    string
+}
+
+// New returns a new Cache storing blobs in the container at containerURL (such as
+// https://account.blob.core.windows.net/container?<SAS token>), with names starting with prefix
+func New(client *http.Client, containerURL, prefix string) (*Cache, error) {
+	u, err := url.Parse(containerURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{client: client, container: u, prefix: prefix}, nil
+}
+
+// Get returns the []byte representation of the response stored with key, or httpcache.ErrCacheMiss
+// if there is none
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.do(ctx, "GET", key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, httpcache.ErrCacheMiss
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("get", key, resp)
+	}
+	if expires := resp.Header.Get(expiresHeader); expires != "" {
+		secs, err := strconv.ParseInt(expires, 10, 64)
+		if err == nil && !now().Before(time.Unix(secs, 0)) {
+			return nil, httpcache.ErrCacheMiss
+		}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Set stores response resp with key, expiring after ttl seconds if positive
+func (c *Cache) Set(ctx context.Context, key string, resp []byte, ttl int) error {
+	header := http.Header{}
+	header.Set("X-Ms-Blob-Type", "BlockBlob")
+	header.Set("Content-Type", "application/octet-stream")
+	if ttl > 0 {
+		header.Set(expiresHeader, strconv.FormatInt(now().Add(time.Duration(ttl)*time.Second).Unix(), 10))
+	}
+	r, err := c.do(ctx, "PUT", key, header, resp)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusCreated {
+		return statusError("set", key, r)
+	}
+	return nil
+}
+
+// Delete removes key from the container
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, "DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusNotFound {
+		return statusError("delete", key, resp)
+	}
+	return nil
+}
+
+// blobURL returns the URL of the blob of key, keeping the query of the container URL
+func (c *Cache) blobURL(key string) string {
+	u := *c.container
+	u.Path = u.Path + "/" + c.prefix + httpcache.HashString(nil, key)
+	u.RawPath = ""
+	return u.String()
+}
+
+func (c *Cache) do(ctx context.Context, method, key string, header http.Header, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.blobURL(key), r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("X-Ms-Version", apiVersion)
+	req.Header.Set("X-Ms-Date", now().UTC().Format(http.TimeFormat))
+	return c.client.Do(req.WithContext(ctx))
+}
+
+func statusError(op, key string, resp *http.Response) error {
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("azureblobcache: %s %q: %s: %s", op, key, resp.Status, bytes.TrimSpace(msg))
+}
@@ -0,0 +1,115 @@
+package azureblobcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+type blob struct {
+	data    []byte
+	expires string
+}
+
+// fakeAzure serves the subset of the Blob service API used by Cache, requiring a SAS signature
+func fakeAzure(t *testing.T) (*httptest.Server, map[string]blob) {
+	var mu sync.Mutex
+	blobs := map[string]blob{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Query().Get("sig") != "secret" || r.Header.Get("X-Ms-Version") == "" {
+			http.Error(w, "AuthenticationFailed", http.StatusForbidden)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		switch r.Method {
+		case "GET":
+			b, ok := blobs[name]
+			if !ok {
+				http.Error(w, "BlobNotFound", http.StatusNotFound)
+				return
+			}
+			if b.expires != "" {
+				w.Header().Set(expiresHeader, b.expires)
+			}
+			w.Write(b.data)
+		case "PUT":
+			if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" {
+				http.Error(w, "MissingRequiredHeader", http.StatusBadRequest)
+				return
+			}
+			data, _ := ioutil.ReadAll(r.Body)
+			blobs[name] = blob{data: data, expires: r.Header.Get(expiresHeader)}
+			w.WriteHeader(http.StatusCreated)
+		case "DELETE":
+			if _, ok := blobs[name]; !ok {
+				http.Error(w, "BlobNotFound", http.StatusNotFound)
+				return
+			}
+			delete(blobs, name)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	return server, blobs
+}
+
+func newCache(t *testing.T, server *httptest.Server, sig string) *Cache {
+	c, err := New(server.Client(), server.URL+"/container?sv=2020-10-02&sig="+sig, "responses/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestAzureBlobCache(t *testing.T) {
+	server, blobs := fakeAzure(t)
+	defer server.Close()
+	c := newCache(t, server, "secret")
+	test.Cache(t, httpcache.AdaptCacheV2(c))
+
+	c.Set(context.Background(), "key", []byte("value"), 0)
+	if _, ok := blobs["container/responses/"+httpcache.HashString(nil, "key")]; !ok {
+		t.Fatalf("got blobs %v, want the entry below the prefix", blobs)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	server, _ := fakeAzure(t)
+	defer server.Close()
+	defer func() { now = time.Now }()
+	ctx := context.Background()
+	c := newCache(t, server, "secret")
+
+	if err := c.Set(ctx, "expiring", []byte("1"), 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "expiring"); err != nil {
+		t.Fatalf("got error %v before the entry TTL", err)
+	}
+	now = func() time.Time { return time.Now().Add(time.Minute) }
+	if _, err := c.Get(ctx, "expiring"); err != httpcache.ErrCacheMiss {
+		t.Fatalf("got error %v after the entry TTL, want ErrCacheMiss", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server, _ := fakeAzure(t)
+	defer server.Close()
+	c := newCache(t, server, "wrong")
+
+	if _, err := c.Get(context.Background(), "key"); err == nil || err == httpcache.ErrCacheMiss {
+		t.Fatalf("got error %v, want a backend error", err)
+	}
+	if err := c.Set(context.Background(), "key", []byte("value"), 0); err == nil || !strings.Contains(err.Error(), "AuthenticationFailed") {
+		t.Fatalf("got error %v, want the Azure error", err)
+	}
+}
@@ -0,0 +1,115 @@
+package httpcache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// BatchResult holds the outcome of one of the requests of a BatchGet
+type BatchResult struct {
+	Response *http.Response
+	Err      error
+}
+
+// BatchGet executes reqs through the client, running at most concurrency of them at once (all of
+// them if concurrency <= 0) and at most Options.MaxBatchPerHost per host if set. Identical GET
+// requests within the batch reach the cache or the origin only once, and get their own copy of
+// the response. GET response bodies are buffered in memory.
+//
+// The results are returned in the order of reqs. Requests keep their own context, along with the
+// values it carries, and are also canceled once ctx is done. Requests not started by then fail with
+// the context error, and callers must close the body of every returned response.
+func (cc *CachedClient) BatchGet(ctx context.Context, reqs []*http.Request, concurrency int) []BatchResult {
+	if concurrency <= 0 || concurrency > len(reqs) {
+		concurrency = len(reqs)
+	}
+	results := make([]BatchResult, len(reqs))
+	sem := make(chan struct{}, concurrency)
+	hosts := map[string]chan struct{}{}
+	var flights flightGroup
+	var wg sync.WaitGroup
+
+	for i, req := range reqs {
+		hostSem := sem
+		if cc.Options.MaxBatchPerHost > 0 {
+			if hostSem = hosts[req.URL.Host]; hostSem == nil {
+				hostSem = make(chan struct{}, cc.Options.MaxBatchPerHost)
+				hosts[req.URL.Host] = hostSem
+			}
+		}
+
+		if ctx.Err() != nil {
+			results[i].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, req *http.Request, hostSem chan struct{}) {
+			defer wg.Done()
+			// The host slot is taken first, so that requests waiting for a busy host don't hold
+			// the slots the requests to other hosts could use
+			if hostSem != sem {
+				select {
+				case hostSem <- struct{}{}:
+					defer func() { <-hostSem }()
+				case <-ctx.Done():
+					results[i].Err = ctx.Err()
+					return
+				}
+			}
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+
+			reqCtx, cancel := batchContext(ctx, req)
+			req = req.WithContext(reqCtx)
+			if req.Method != http.MethodGet {
+				results[i].Response, results[i].Err = cc.Do(req)
+			} else {
+				results[i].Response, _, results[i].Err = flights.do(reqCtx, flightKey(cc.cacheKey(req), req), func() (*http.Response, error) {
+					return cc.Do(req)
+				})
+			}
+			if resp := results[i].Response; resp != nil && resp.Body != nil {
+				resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+			} else {
+				cancel()
+			}
+		}(i, req, hostSem)
+	}
+	wg.Wait()
+	return results
+}
+
+// batchContext returns a copy of the context of req that is also done once ctx, the context of the
+// batch, is done, along with the function releasing it
+func batchContext(ctx context.Context, req *http.Request) (context.Context, context.CancelFunc) {
+	reqCtx, cancel := context.WithCancel(req.Context())
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				cancel()
+			case <-reqCtx.Done():
+			}
+		}()
+	}
+	return reqCtx, cancel
+}
+
+// cancelReadCloser is a response body releasing the context of its request once closed
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
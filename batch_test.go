@@ -0,0 +1,150 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchGet(t *testing.T) {
+	var hits, inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{MaxBatchPerHost: 2},
+	}
+	var reqs []*http.Request
+	for i := 0; i < 8; i++ {
+		// Every path is requested twice
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/%d", server.URL, i%4), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs = append(reqs, req)
+	}
+
+	results := client.BatchGet(context.Background(), reqs, 8)
+	if len(results) != len(reqs) {
+		t.Fatalf("got %d results, want %d", len(results), len(reqs))
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatalf("request %d: %v", i, r.Err)
+		}
+		body, _ := ioutil.ReadAll(r.Response.Body)
+		r.Response.Body.Close()
+		if want := fmt.Sprintf("/%d", i%4); string(body) != want {
+			t.Fatalf("request %d: got body %q, want %q", i, body, want)
+		}
+	}
+	if hits != 4 {
+		t.Fatalf("got %d origin requests, want one per distinct URL", hits)
+	}
+	if maxInFlight > 2 {
+		t.Fatalf("got %d concurrent requests to the same host, want at most 2", maxInFlight)
+	}
+}
+
+func TestBatchGetPartition(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	cache := NewMemoryCache()
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}}
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(WithPartition(context.Background(), "tenant-a"))
+
+	for i, r := range client.BatchGet(context.Background(), []*http.Request{req}, 1) {
+		if r.Err != nil {
+			t.Fatalf("request %d: %v", i, r.Err)
+		}
+		ioutil.ReadAll(r.Response.Body)
+		r.Response.Body.Close()
+	}
+	if keys := cache.Keys(); len(keys) != 1 || keys[0] != "partition:tenant-a "+server.URL {
+		t.Fatalf("got keys %v, want the entry stored in the partition of the request", keys)
+	}
+}
+
+func TestBatchGetCanceled(t *testing.T) {
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: transportMock{}}
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i, r := range client.BatchGet(ctx, []*http.Request{req, req}, 1) {
+		if r.Err != context.Canceled || r.Response != nil {
+			t.Fatalf("request %d: got %v, %v, want context.Canceled", i, r.Response, r.Err)
+		}
+	}
+}
+
+func TestBatchGetBusyHostDoesntBlockOthers(t *testing.T) {
+	release := make(chan struct{})
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer busy.Close()
+	served := make(chan struct{})
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(served)
+	}))
+	defer other.Close()
+
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{MaxBatchPerHost: 1},
+	}
+	var reqs []*http.Request
+	for _, u := range []string{busy.URL + "/1", busy.URL + "/2", busy.URL + "/3", other.URL} {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs = append(reqs, req)
+	}
+
+	done := make(chan []BatchResult)
+	go func() { done <- client.BatchGet(context.Background(), reqs, 2) }()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Error("requests waiting for a busy host held every batch slot")
+	}
+	close(release)
+	for _, r := range <-done {
+		if r.Err == nil {
+			r.Response.Body.Close()
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package benchmarks provides reproducible workloads to evaluate the performance of a
+// httpcache.CachedClient, along with a simulated origin serving them.
+//
+// Workloads are fully determined by their seed, so results can be compared between runs:
+//
+//	w := benchmarks.Workload{Seed: 1, URLs: 1000, Requests: 100000, OriginLatency: time.Millisecond}
+//	result, err := benchmarks.Run("memory", w, func(origin http.RoundTripper) httpcache.Doer {
+//		return httpcache.NewCachedClient(&http.Client{Transport: origin}, httpcache.NewMemoryCache(), httpcache.CacheOptions{})
+//	})
+package benchmarks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+)
+
+// Workload describes a sequence of requests and the origin answering them. Zero fields take
+// the defaults documented on each of them.
+type Workload struct {
+	// Seed of the random generator deciding the request sequence and response sizes
+	Seed int64
+	// URLs is the number of distinct URLs requested. Defaults to 1000.
+	URLs int
+	// Requests is the total number of requests. Defaults to 10000.
+	Requests int
+	// Skew is the s parameter of the Zipfian distribution of the URL popularity, which must be
+	// greater than 1. Higher values concentrate the requests on fewer URLs. Defaults to 1.1.
+	Skew float64
+	// MinResponseSize and MaxResponseSize bound the size in bytes of the response bodies, which is
+	// picked uniformly for every URL. Both default to 4096.
+	MinResponseSize int
+	MaxResponseSize int
+	// OriginLatency is the time the origin takes to answer each request
+	OriginLatency time.Duration
+	// MaxAge is the max-age the origin sets on its responses. Defaults to an hour; a negative
+	// value makes the responses uncacheable.
+	MaxAge int
+	// Concurrency is the number of goroutines sending the requests. Defaults to 1.
+	Concurrency int
+}
+
+func (w Workload) withDefaults() Workload {
+	if w.URLs <= 0 {
+		w.URLs = 1000
+	}
+	if w.Requests <= 0 {
+		w.Requests = 10000
+	}
+	if w.Skew <= 1 {
+		w.Skew = 1.1
+	}
+	if w.MinResponseSize <= 0 {
+		w.MinResponseSize = 4096
+	}
+	if w.MaxResponseSize < w.MinResponseSize {
+		w.MaxResponseSize = w.MinResponseSize
+	}
+	if w.MaxAge == 0 {
+		w.MaxAge = 3600
+	}
+	if w.Concurrency <= 0 {
+		w.Concurrency = 1
+	}
+	return w
+}
+
+// url returns the URL of the i-th most popular resource
+func url(i int) string {
+	return "http://origin.invalid/resource/" + strconv.Itoa(i)
+}
+
+// Sequence returns the URLs requested by the workload, in order
+func (w Workload) Sequence() []string {
+	w = w.withDefaults()
+	r := rand.New(rand.NewSource(w.Seed))
+	zipf := rand.NewZipf(r, w.Skew, 1, uint64(w.URLs-1))
+	seq := make([]string, w.Requests)
+	for i := range seq {
+		seq[i] = url(int(zipf.Uint64()))
+	}
+	return seq
+}
+
+// Origin is a simulated origin serving the responses of a workload without any network access.
+// It implements http.RoundTripper.
+type Origin struct {
+	workload Workload
+	bodies   map[string][]byte
+	requests int64
+}
+
+// NewOrigin returns the origin of workload w
+func NewOrigin(w Workload) *Origin {
+	w = w.withDefaults()
+	r := rand.New(rand.NewSource(w.Seed))
+	bodies := make(map[string][]byte, w.URLs)
+	for i := 0; i < w.URLs; i++ {
+		body := make([]byte, w.MinResponseSize+r.Intn(w.MaxResponseSize-w.MinResponseSize+1))
+		r.Read(body)
+		bodies[url(i)] = body
+	}
+	return &Origin{workload: w, bodies: bodies}
+}
+
+// Requests returns the number of requests the origin received
+func (o *Origin) Requests() int64 {
+	return atomic.LoadInt64(&o.requests)
+}
+
+// RoundTrip answers req after the workload origin latency
+func (o *Origin) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&o.requests, 1)
+	if o.workload.OriginLatency > 0 {
+		time.Sleep(o.workload.OriginLatency)
+	}
+
+	body, ok := o.bodies[req.URL.String()]
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+	}
+	header := http.Header{}
+	header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	if o.workload.MaxAge > 0 {
+		header.Set("Cache-Control", "max-age="+strconv.Itoa(o.workload.MaxAge))
+	} else {
+		header.Set("Cache-Control", "no-store")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Result holds the measurements of a workload run
+type Result struct {
+	Name              string        `json:"name"`
+	Requests          int           `json:"requests"`
+	OriginRequests    int64         `json:"origin_requests"`
+	Errors            int64         `json:"errors"`
+	BytesRead         int64         `json:"bytes_read"`
+	Duration          time.Duration `json:"duration_ns"`
+	RequestsPerSecond float64       `json:"requests_per_second"`
+	HitRatio          float64       `json:"hit_ratio"`
+}
+
+// Run sends the requests of workload w through the client returned by newClient, which must send
+// its upstream requests through the given origin, and returns the measurements of the run
+func Run(name string, w Workload, newClient func(origin http.RoundTripper) httpcache.Doer) (Result, error) {
+	w = w.withDefaults()
+	seq := w.Sequence()
+	origin := NewOrigin(w)
+	client := newClient(origin)
+
+	var next, errs, bytesRead int64
+	var firstErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < w.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := atomic.AddInt64(&next, 1) - 1
+				if i >= int64(len(seq)) {
+					return
+				}
+				n, err := fetch(client, seq[i])
+				atomic.AddInt64(&bytesRead, n)
+				if err != nil {
+					atomic.AddInt64(&errs, 1)
+					errOnce.Do(func() { firstErr = err })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	result := Result{
+		Name:           name,
+		Requests:       len(seq),
+		OriginRequests: origin.Requests(),
+		Errors:         errs,
+		BytesRead:      bytesRead,
+		Duration:       elapsed,
+		HitRatio:       float64(int64(len(seq))-origin.Requests()) / float64(len(seq)),
+	}
+	if elapsed > 0 {
+		result.RequestsPerSecond = float64(len(seq)) / elapsed.Seconds()
+	}
+	return result, firstErr
+}
+
+func fetch(client httpcache.Doer, u string) (int64, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(ioutil.Discard, resp.Body)
+}
+
+// WriteJSON writes results to w as an indented JSON array
+func WriteJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
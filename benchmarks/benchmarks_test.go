@@ -0,0 +1,99 @@
+package benchmarks
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/lggomez/httpcache/v2"
+)
+
+func memoryClient(origin http.RoundTripper) httpcache.Doer {
+	return httpcache.NewCachedClient(&http.Client{Transport: origin}, httpcache.NewMemoryCache(), httpcache.CacheOptions{})
+}
+
+func lruClient(origin http.RoundTripper) httpcache.Doer {
+	return httpcache.NewCachedClient(&http.Client{Transport: origin}, httpcache.NewLRUCache(100, 0), httpcache.CacheOptions{})
+}
+
+func TestSequenceIsReproducible(t *testing.T) {
+	w := Workload{Seed: 42, URLs: 50, Requests: 200}
+	a, b := w.Sequence(), w.Sequence()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("request %d: got %q and %q for the same seed", i, a[i], b[i])
+		}
+	}
+
+	counts := map[string]int{}
+	for _, u := range a {
+		counts[u]++
+	}
+	if counts[url(0)] <= counts[url(49)] {
+		t.Fatalf("got %d requests for the most popular URL and %d for the least, want a skewed distribution", counts[url(0)], counts[url(49)])
+	}
+}
+
+func TestRun(t *testing.T) {
+	w := Workload{Seed: 1, URLs: 20, Requests: 500, Concurrency: 4, MinResponseSize: 10, MaxResponseSize: 100}
+	result, err := Run("memory", w, memoryClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Requests != 500 || result.Errors != 0 {
+		t.Fatalf("got %d requests and %d errors, want 500 and 0", result.Requests, result.Errors)
+	}
+	if result.OriginRequests < 1 || result.OriginRequests > 20+4 {
+		t.Fatalf("got %d origin requests, want at most one per URL and worker", result.OriginRequests)
+	}
+	if result.BytesRead < 500*10 {
+		t.Fatalf("got %d bytes read, want at least %d", result.BytesRead, 500*10)
+	}
+
+	uncached, err := Run("uncacheable", Workload{Seed: 1, URLs: 20, Requests: 100, MaxAge: -1}, memoryClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uncached.OriginRequests != 100 || uncached.HitRatio != 0 {
+		t.Fatalf("got %d origin requests for uncacheable responses, want 100", uncached.OriginRequests)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, []Result{result, uncached}); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Result
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0] != result {
+		t.Fatalf("got %+v, want the results back", decoded)
+	}
+}
+
+func benchmark(b *testing.B, w Workload, newClient func(http.RoundTripper) httpcache.Doer) {
+	w.Seed = 1
+	w.Requests = b.N
+	b.ReportAllocs()
+	b.ResetTimer()
+	if _, err := Run(b.Name(), w, newClient); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkMemoryCache(b *testing.B) {
+	benchmark(b, Workload{}, memoryClient)
+}
+
+func BenchmarkMemoryCacheParallel(b *testing.B) {
+	benchmark(b, Workload{Concurrency: 8}, memoryClient)
+}
+
+func BenchmarkLRUCache(b *testing.B) {
+	benchmark(b, Workload{}, lruClient)
+}
+
+func BenchmarkLargeResponses(b *testing.B) {
+	benchmark(b, Workload{MinResponseSize: 64 << 10, MaxResponseSize: 1 << 20}, memoryClient)
+}
@@ -0,0 +1,93 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// bodyHashHeader holds the hash of the body of stored responses
+const bodyHashHeader = "X-Httpcache-Body-Hash"
+
+// syntheticETagHeader flags cached entries whose ETag was synthesized by SynthesizeValidators
+const syntheticETagHeader = "X-Httpcache-Synthetic-Etag"
+
+// hashBody sets the body hash header of resp, a response about to be stored, if HashBodies or
+// SynthesizeValidators is set, along with its synthetic ETag for the latter. The body of resp is
+// read and replaced, and its header copied so that the one of the response returned to the caller
+// is left untouched.
+func (cc *CachedClient) hashBody(resp *http.Response) {
+	if !cc.Options.HashBodies && !cc.Options.SynthesizeValidators {
+		return
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	header := make(http.Header, len(resp.Header)+1)
+	for k, v := range resp.Header {
+		header[k] = v
+	}
+	hash := hashBytes(cc.hasher(), body)
+	header.Set(bodyHashHeader, hash)
+	if cc.Options.SynthesizeValidators && resp.StatusCode == http.StatusOK && hasNoValidators(header) {
+		header.Set("ETag", `W/"`+hash+`"`)
+		header.Set(syntheticETagHeader, "1")
+	}
+	resp.Header = header
+}
+
+// hasNoValidators returns true if header has neither Last-Modified nor an ETag from the origin
+func hasNoValidators(header http.Header) bool {
+	return header.Get("Last-Modified") == "" && (header.Get("ETag") == "" || header.Get(syntheticETagHeader) != "")
+}
+
+// probeUnchanged checks with a HEAD request whether the entry cachedResp, stored for req with a
+// synthetic ETag, is still current: the origin must still send no validators, and the same
+// Content-Length. If so, the HEAD response is returned as a 304 response. Otherwise, or on error,
+// it returns nil.
+func (cc *CachedClient) probeUnchanged(req *http.Request, cachedResp *http.Response) *http.Response {
+	head := cloneRequest(req)
+	head.Method = "HEAD"
+	resp, err := cc.roundTrip(head)
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	length := resp.Header.Get("Content-Length")
+	if resp.StatusCode != http.StatusOK || !hasNoValidators(resp.Header) ||
+		length == "" || length != cachedResp.Header.Get("Content-Length") {
+		return nil
+	}
+	cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) HEAD probe matched the entry with synthetic validator", req))
+	resp.StatusCode = http.StatusNotModified
+	resp.Status = "304 Not Modified"
+	resp.Body = http.NoBody
+	return resp
+}
+
+// DoIfChanged does req like Do, for pollers of APIs without validators: if the body of the response
+// hashes to previousHash, the response is discarded and nil is returned, as there is nothing new to
+// process. Otherwise the response is returned, with its body fully read and buffered. The body hash
+// is returned in both cases, to be passed on the next call.
+//
+// The hash is always computed from the body read, never taken from the response headers.
+func (cc *CachedClient) DoIfChanged(req *http.Request, previousHash string) (*http.Response, string, error) {
+	resp, err := cc.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	hash := hashBytes(cc.hasher(), body)
+	if previousHash != "" && hash == previousHash {
+		return nil, hash, nil
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, hash, nil
+}
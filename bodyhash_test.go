@@ -0,0 +1,139 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestBodyHash(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{HashBodies: true, BodyHashHeader: "X-Body-Hash"},
+	}
+	want := HashString(nil, "body")
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got, cached := resp.Header.Get("X-Body-Hash"), i == 1; (got == want) != cached {
+			t.Fatalf("request %d: got hash header %q", i, got)
+		}
+	}
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	if meta, ok := client.EntryMetadata(req); !ok || meta.BodyHash != want {
+		t.Fatalf("got metadata %+v (found: %v), want body hash %q", meta, ok, want)
+	}
+}
+
+func TestDoIfChanged(t *testing.T) {
+	body := "v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	for _, hashBodies := range []bool{false, true} {
+		body = "v1"
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}, Options: CacheOptions{HashBodies: hashBodies}}
+		poll := func(previousHash string) (string, string) {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, hash, err := client.DoIfChanged(req, previousHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp == nil {
+				return "", hash
+			}
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			return string(b), hash
+		}
+
+		got, hash := poll("")
+		if got != "v1" || hash != HashString(nil, "v1") {
+			t.Fatalf("got body %q and hash %q, want v1 and its hash", got, hash)
+		}
+		if got, next := poll(hash); got != "" || next != hash {
+			t.Fatalf("got body %q and hash %q, want no change", got, next)
+		}
+		body = "v2"
+		if got, next := poll(hash); got != "v2" || next != HashString(nil, "v2") {
+			t.Fatalf("got body %q and hash %q, want v2 and its hash", got, next)
+		}
+	}
+
+	// A hash sent by the origin can't force an unchanged result
+	forged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(bodyHashHeader, HashString(nil, "v1"))
+		w.Write([]byte("v2"))
+	}))
+	defer forged.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	req, _ := http.NewRequest("GET", forged.URL, nil)
+	if resp, hash, err := client.DoIfChanged(req, HashString(nil, "v1")); err != nil || resp == nil || hash != HashString(nil, "v2") {
+		t.Fatalf("got %v, %q, %v, want the v2 response and its hash", resp, hash, err)
+	} else {
+		resp.Body.Close()
+	}
+}
+
+func TestSynthesizeValidators(t *testing.T) {
+	body := "hello"
+	methods := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods[r.Method]++
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == "GET" {
+			w.Write([]byte(body))
+		}
+	}))
+	defer server.Close()
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{MarkCachedResponses: true, SynthesizeValidators: true},
+	}
+	get := func() *http.Response {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != body {
+			t.Fatalf("got body %q, want %q", b, body)
+		}
+		return resp
+	}
+
+	get()
+	resp := get()
+	if resp.Header.Get(XFromCache) == "" || methods["GET"] != 1 || methods["HEAD"] != 1 {
+		t.Fatalf("got %v origin requests (from cache: %q), want the entry kept after a HEAD probe", methods, resp.Header.Get(XFromCache))
+	}
+	if want := `W/"` + HashString(nil, body) + `"`; resp.Header.Get("ETag") != want {
+		t.Fatalf("got ETag %q, want %q", resp.Header.Get("ETag"), want)
+	}
+
+	body = "hello, world"
+	if resp := get(); resp.Header.Get(XFromCache) != "" || methods["GET"] != 2 || methods["HEAD"] != 2 {
+		t.Fatalf("got %v origin requests (from cache: %q), want a download after a failed probe", methods, resp.Header.Get(XFromCache))
+	}
+}
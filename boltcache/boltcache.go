@@ -0,0 +1,203 @@
+// Package boltcache provides an implementation of httpcache.Cache that stores responses in a
+// bbolt database file, giving single binary applications a crash-safe persistent cache.
+//
+// It is a separate module so that the main one stays free of dependencies.
+package boltcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// entriesBucket holds the stored responses, each prefixed with its expiration
+	entriesBucket = []byte("entries")
+	// expiryBucket indexes the entries that expire by expiration time, so they can be swept in order
+	expiryBucket = []byte("expiry")
+)
+
+// now returns the current time, and is replaced in tests
+var now = time.Now
+
+// Cache is an implementation of httpcache.Cache backed by a bbolt database. Entries with a TTL are
+// reported as missing once expired, and removed from the file by DeleteExpired.
+//
+// Cache also implements httpcache.KeyLister.
+type Cache struct {
+	db *bolt.DB
+}
+
+// New opens (creating it if needed) the bbolt database file at path and returns a Cache using it
+func New(path string) (*Cache, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewWithDB(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewWithDB returns a Cache using the already opened database db, creating its buckets if needed
+func NewWithDB(db *bolt.DB) (*Cache, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(entriesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(expiryBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{db: db}, nil
+}
+
+// Close closes the underlying database
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *Cache) Get(key string) (resp []byte, ok bool) {
+	c.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(entriesBucket).Get([]byte(key))
+		if v == nil || expired(v, now()) {
+			return nil
+		}
+		// Values are only valid during the transaction
+		resp, ok = append([]byte(nil), v[8:]...), true
+		return nil
+	})
+	return resp, ok
+}
+
+// Set saves response resp to the cache with key, expiring after ttl seconds if positive
+func (c *Cache) Set(key string, resp []byte, ttl int) {
+	var expires int64
+	if ttl > 0 {
+		expires = now().Add(time.Duration(ttl) * time.Second).UnixNano()
+	}
+	v := make([]byte, 8+len(resp))
+	binary.BigEndian.PutUint64(v, uint64(expires))
+	copy(v[8:], resp)
+
+	c.db.Update(func(tx *bolt.Tx) error {
+		if err := remove(tx, []byte(key)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(entriesBucket).Put([]byte(key), v); err != nil {
+			return err
+		}
+		if expires == 0 {
+			return nil
+		}
+		return tx.Bucket(expiryBucket).Put(indexKey(expires, []byte(key)), nil)
+	})
+}
+
+// Delete removes key from the cache
+func (c *Cache) Delete(key string) {
+	c.db.Update(func(tx *bolt.Tx) error {
+		return remove(tx, []byte(key))
+	})
+}
+
+// Keys returns the keys of all the unexpired entries in the cache
+func (c *Cache) Keys() []string {
+	var keys []string
+	t := now()
+	c.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(entriesBucket).ForEach(func(k, v []byte) error {
+			if !expired(v, t) {
+				keys = append(keys, string(k))
+			}
+			return nil
+		})
+	})
+	return keys
+}
+
+// DeleteExpired removes all the expired entries from the database, walking the expiry index
+// from the oldest expiration. It returns the number of removed entries.
+func (c *Cache) DeleteExpired() (int, error) {
+	removed := 0
+	t := now().UnixNano()
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		var keys [][]byte
+		cur := tx.Bucket(expiryBucket).Cursor()
+		for k, _ := cur.First(); k != nil && int64(binary.BigEndian.Uint64(k)) <= t; k, _ = cur.Next() {
+			keys = append(keys, append([]byte(nil), k[8:]...))
+		}
+		for _, key := range keys {
+			if err := remove(tx, key); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	return removed, err
+}
+
+// StartJanitor runs DeleteExpired every interval in a background goroutine until the returned
+// stop function is called
+func (c *Cache) StartJanitor(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.DeleteExpired()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// remove deletes key and its expiry index entry, if any
+func remove(tx *bolt.Tx, key []byte) error {
+	entries := tx.Bucket(entriesBucket)
+	v := entries.Get(key)
+	if v == nil {
+		return nil
+	}
+	if expires := int64(binary.BigEndian.Uint64(v)); expires != 0 {
+		if err := tx.Bucket(expiryBucket).Delete(indexKey(expires, key)); err != nil {
+			return err
+		}
+	}
+	return entries.Delete(key)
+}
+
+// expired returns true if the stored value v has expired at t. Malformed values count as expired.
+func expired(v []byte, t time.Time) bool {
+	if len(v) < 8 {
+		return true
+	}
+	expires := int64(binary.BigEndian.Uint64(v))
+	return expires != 0 && expires <= t.UnixNano()
+}
+
+// indexKey returns the expiry index key of key, which sorts by expiration time
+func indexKey(expires int64, key []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint64(expires))
+	b.Write(key)
+	return b.Bytes()
+}
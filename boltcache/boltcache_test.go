@@ -0,0 +1,93 @@
+package boltcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+func newCache(t *testing.T) (*Cache, func()) {
+	dir, err := ioutil.TempDir("", "boltcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return c, func() {
+		c.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltCache(t *testing.T) {
+	c, cleanup := newCache(t)
+	defer cleanup()
+	test.Cache(t, c)
+}
+
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boltcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.db")
+
+	c, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("key", []byte("value"), 0)
+	c.Close()
+
+	if c, err = New(path); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if val, ok := c.Get("key"); !ok || string(val) != "value" {
+		t.Fatalf("got %q, %v, want value, true", val, ok)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c, cleanup := newCache(t)
+	defer cleanup()
+	defer func() { now = time.Now }()
+
+	c.Set("expiring", []byte("1"), 10)
+	c.Set("replaced", []byte("2"), 10)
+	c.Set("replaced", []byte("3"), 0)
+	c.Set("permanent", []byte("4"), 0)
+	if _, ok := c.Get("expiring"); !ok {
+		t.Fatal("entry expired before its TTL")
+	}
+
+	now = func() time.Time { return time.Now().Add(time.Minute) }
+	if _, ok := c.Get("expiring"); ok {
+		t.Fatal("entry didn't expire after its TTL")
+	}
+	if val, ok := c.Get("replaced"); !ok || string(val) != "3" {
+		t.Fatalf("got %q, %v for an entry replaced without TTL, want 3, true", val, ok)
+	}
+	if keys := c.Keys(); len(keys) != 2 {
+		t.Fatalf("got keys %v, want [permanent replaced]", keys)
+	}
+
+	removed, err := c.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("removed %d entries, want 1", removed)
+	}
+	if removed, _ := c.DeleteExpired(); removed != 0 {
+		t.Fatalf("removed %d entries on the second sweep, want 0", removed)
+	}
+}
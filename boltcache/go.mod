@@ -0,0 +1,12 @@
+module github.com/lggomez/httpcache/v2/boltcache
+
+go 1.17
+
+require (
+	github.com/lggomez/httpcache/v2 v2.0.0
+	go.etcd.io/bbolt v1.3.7
+)
+
+require golang.org/x/sys v0.4.0 // indirect
+
+replace github.com/lggomez/httpcache/v2 => ../
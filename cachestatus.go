@@ -0,0 +1,91 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheStatusHeader is the header describing how caches handled a response, defined by RFC 9211.
+// See CacheOptions.CacheStatus.
+const CacheStatusHeader = "Cache-Status"
+
+// Forward reasons of the Cache-Status header, telling why a request was sent to the origin
+const (
+	fwdBypass   = "bypass"
+	fwdURIMiss  = "uri-miss"
+	fwdVaryMiss = "vary-miss"
+	fwdStale    = "stale"
+	fwdRequest  = "request"
+)
+
+// cacheStatus describes how the client handled a response, as a member of the Cache-Status header
+type cacheStatus struct {
+	hit bool
+	// ttl is the remaining freshness lifetime of the response, negative if it is stale
+	ttl       time.Duration
+	hasTTL    bool
+	fwd       string
+	fwdStatus int
+	stored    bool
+	collapsed bool
+}
+
+// member returns the Cache-Status member describing s for the cache called name
+func (s cacheStatus) member(name string) string {
+	parts := []string{name}
+	if s.hit {
+		parts = append(parts, "hit")
+	}
+	if s.fwd != "" {
+		parts = append(parts, "fwd="+s.fwd)
+		if s.fwdStatus != 0 {
+			parts = append(parts, "fwd-status="+strconv.Itoa(s.fwdStatus))
+		}
+	}
+	if s.hasTTL {
+		parts = append(parts, "ttl="+strconv.FormatInt(int64(s.ttl/time.Second), 10))
+	}
+	if s.stored {
+		parts = append(parts, "stored")
+	}
+	if s.collapsed {
+		parts = append(parts, "collapsed")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// withTTL returns s with the remaining freshness lifetime of resp, a response to req, if it can be
+// computed
+func (cc *CachedClient) withTTL(s cacheStatus, req *http.Request, resp *http.Response) cacheStatus {
+	date, err := responseDate(resp.Header)
+	if err != nil {
+		return s
+	}
+	lifetime := cc.capLifetime(req, cc.lifetime(resp.Header, parseCacheControl(resp.Header), date))
+	s.ttl, s.hasTTL = lifetime-currentAge(resp.Header, date), true
+	return s
+}
+
+// setCacheStatus appends the member describing s to the Cache-Status header of resp, if
+// Options.CacheStatus is set. A member left by the client on a stored response is replaced, while
+// the members of other caches, such as the ones upstream, are kept.
+func (cc *CachedClient) setCacheStatus(resp *http.Response, s cacheStatus) {
+	name := cc.Options.CacheStatus
+	if name == "" || resp == nil {
+		return
+	}
+	var members []string
+	for _, line := range resp.Header[CacheStatusHeader] {
+		for _, m := range strings.Split(line, ",") {
+			m = strings.TrimSpace(m)
+			if m == "" || strings.TrimSpace(strings.SplitN(m, ";", 2)[0]) == name {
+				continue
+			}
+			members = append(members, m)
+		}
+	}
+	members = append(members, s.member(name))
+	resp.Header.Set(CacheStatusHeader, strings.Join(members, ", "))
+}
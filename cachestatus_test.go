@@ -0,0 +1,69 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheStatus(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Header().Set("Cache-Status", "CDN; hit")
+		w.Header().Set("Etag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}, Options: CacheOptions{CacheStatus: "httpcache"}}
+	do := func(method string) string {
+		req, _ := http.NewRequest(method, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.Header.Get(CacheStatusHeader)
+	}
+
+	for i, tc := range []struct {
+		method  string
+		elapsed time.Duration
+		want    string
+	}{
+		{"GET", 0, "CDN; hit, httpcache; fwd=uri-miss; fwd-status=200; stored"},
+		{"GET", 4 * time.Second, "CDN; hit, httpcache; hit; ttl=6"},
+		{"GET", 20 * time.Second, "CDN; hit, httpcache; fwd=stale; fwd-status=304; stored"},
+		{"POST", 0, "CDN; hit, httpcache; fwd=bypass; fwd-status=200"},
+	} {
+		clock = &fakeClock{elapsed: tc.elapsed}
+		if got := do(tc.method); got != tc.want {
+			t.Fatalf("request %d: got Cache-Status %q, want %q", i, got, tc.want)
+		}
+	}
+}
+
+func TestCacheStatusDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get(CacheStatusHeader); got != "" {
+		t.Fatalf("got Cache-Status %q, want none", got)
+	}
+}
@@ -0,0 +1,78 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrCacheMiss is returned by CacheV2.Get when there is no value stored for a key
+var ErrCacheMiss = errors.New("httpcache: cache miss")
+
+// A CacheV2 interface is a context-aware alternative to Cache whose operations can report backend
+// errors. It allows implementations backed by remote stores to honor request deadlines and to tell
+// a miss apart from an outage.
+type CacheV2 interface {
+	// Get returns the []byte representation of a cached response, or ErrCacheMiss if there is none
+	Get(ctx context.Context, key string) (responseBytes []byte, err error)
+	// Set stores the []byte representation of a response for a given key, with a TTL for supporting implementations
+	Set(ctx context.Context, key string, responseBytes []byte, ttl int) error
+	// Delete removes the value associated with the key
+	Delete(ctx context.Context, key string) error
+}
+
+// AdaptCache returns a CacheV2 backed by the Cache c. Contexts are ignored and no errors
+// other than ErrCacheMiss are ever returned.
+func AdaptCache(c Cache) CacheV2 {
+	if a, ok := c.(*cacheV2Adapter); ok {
+		return a.c
+	}
+	return &cacheAdapter{c: c}
+}
+
+// AdaptCacheV2 returns a Cache backed by the CacheV2 c, so it can be used with the helpers and
+// wrappers accepting a Cache. Operations run with a background context and backend errors are
+// reported as misses.
+func AdaptCacheV2(c CacheV2) Cache {
+	if a, ok := c.(*cacheAdapter); ok {
+		return a.c
+	}
+	return &cacheV2Adapter{c: c}
+}
+
+type cacheAdapter struct {
+	c Cache
+}
+
+func (a *cacheAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	if val, ok := a.c.Get(key); ok {
+		return val, nil
+	}
+	return nil, ErrCacheMiss
+}
+
+func (a *cacheAdapter) Set(ctx context.Context, key string, responseBytes []byte, ttl int) error {
+	a.c.Set(key, responseBytes, ttl)
+	return nil
+}
+
+func (a *cacheAdapter) Delete(ctx context.Context, key string) error {
+	a.c.Delete(key)
+	return nil
+}
+
+type cacheV2Adapter struct {
+	c CacheV2
+}
+
+func (a *cacheV2Adapter) Get(key string) ([]byte, bool) {
+	val, err := a.c.Get(context.Background(), key)
+	return val, err == nil
+}
+
+func (a *cacheV2Adapter) Set(key string, responseBytes []byte, ttl int) {
+	a.c.Set(context.Background(), key, responseBytes, ttl)
+}
+
+func (a *cacheV2Adapter) Delete(key string) {
+	a.c.Delete(context.Background(), key)
+}
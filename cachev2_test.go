@@ -0,0 +1,68 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+type failingCacheV2 struct {
+	gets int
+}
+
+func (c *failingCacheV2) Get(ctx context.Context, key string) ([]byte, error) {
+	c.gets++
+	return nil, errors.New("backend unavailable")
+}
+
+func (c *failingCacheV2) Set(ctx context.Context, key string, responseBytes []byte, ttl int) error {
+	return errors.New("backend unavailable")
+}
+
+func (c *failingCacheV2) Delete(ctx context.Context, key string) error {
+	return errors.New("backend unavailable")
+}
+
+func TestAdaptCache(t *testing.T) {
+	ctx := context.Background()
+	c := AdaptCache(NewMemoryCache())
+	if _, err := c.Get(ctx, "key"); err != ErrCacheMiss {
+		t.Fatalf("got error %v, want ErrCacheMiss", err)
+	}
+	if err := c.Set(ctx, "key", []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := c.Get(ctx, "key"); err != nil || string(val) != "value" {
+		t.Fatalf("got %q, %v, want value, nil", val, err)
+	}
+
+	mc := NewMemoryCache()
+	if AdaptCacheV2(AdaptCache(mc)) != Cache(mc) {
+		t.Fatal("adapting twice doesn't return the original Cache")
+	}
+}
+
+func TestCacheV2BackendErrorsGoToOrigin(t *testing.T) {
+	resetTest()
+	backend := &failingCacheV2{}
+	client := &CachedClient{CacheV2: backend, Transport: &http.Transport{}}
+
+	req, err := http.NewRequest("GET", s.server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", resp.StatusCode)
+	}
+	if backend.gets != 1 {
+		t.Fatalf("backend Get called %d times, want 1", backend.gets)
+	}
+}
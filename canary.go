@@ -0,0 +1,123 @@
+package httpcache
+
+import (
+	"hash/fnv"
+	"net/http"
+	"sync/atomic"
+)
+
+// CanaryOptions configure a second cache policy that runs alongside the main one on a share of
+// the traffic, so risky policy changes can be rolled out gradually and compared through CanaryStats
+type CanaryOptions struct {
+	// Options used for the requests routed to the canary. Its own Canary field is ignored.
+	Options CacheOptions
+	// Percent, between 0 and 100, of the cache keys routed to the canary. A given key is always
+	// routed to the same policy, so its entries are never shared between both.
+	Percent int
+}
+
+// CanaryStats holds the request counters of one of the policies of a canary rollout
+type CanaryStats struct {
+	// Requests is the number of requests handled by the policy
+	Requests int64
+	// OriginRequests is the number of requests that reached the origin, revalidations included
+	OriginRequests int64
+	// NotModified is the number of revalidations answered with 304 Not Modified
+	NotModified int64
+}
+
+// HitRatio returns the share of requests that were served from the cache without reaching the origin
+func (s CanaryStats) HitRatio() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Requests-s.OriginRequests) / float64(s.Requests)
+}
+
+// canaryArm is the client of one of the policies of a canary rollout
+type canaryArm struct {
+	stats  CanaryStats
+	client *CachedClient
+}
+
+// countingTransport counts the round trips of an arm
+type countingTransport struct {
+	http.RoundTripper
+	stats *CanaryStats
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.stats.OriginRequests, 1)
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusNotModified {
+		atomic.AddInt64(&t.stats.NotModified, 1)
+	}
+	return resp, err
+}
+
+// newCanaryArm returns an arm sharing the transport and backend of cc, using options
+func (cc *CachedClient) newCanaryArm(options CacheOptions) *canaryArm {
+	options.Canary = nil
+	arm := &canaryArm{}
+	arm.client = &CachedClient{
+		Transport: countingTransport{RoundTripper: cc.Transport, stats: &arm.stats},
+		Cache:     cc.Cache,
+		CacheV2:   cc.CacheV2,
+		Options:   options,
+		parent:    cc,
+	}
+	return arm
+}
+
+// canaryArm returns the arm req is routed to when a canary is configured, nil otherwise
+func (cc *CachedClient) canaryArm(req *http.Request) *canaryArm {
+	canary := cc.Options.Canary
+	if canary == nil {
+		return nil
+	}
+
+	cc.mu.Lock()
+	if cc.arms[0] == nil {
+		cc.arms[0] = cc.newCanaryArm(cc.Options)
+		cc.arms[1] = cc.newCanaryArm(canary.Options)
+	}
+	control, arm := cc.arms[0], cc.arms[1]
+	cc.mu.Unlock()
+
+	h := fnv.New32a()
+	h.Write([]byte(cc.cacheKey(req)))
+	if int(h.Sum32()%100) >= canary.Percent {
+		arm = control
+	}
+	atomic.AddInt64(&arm.stats.Requests, 1)
+	return arm
+}
+
+// CanaryStats returns the stats of the main policy and of the canary policy, as configured
+// through CacheOptions.Canary
+func (cc *CachedClient) CanaryStats() (control, canary CanaryStats) {
+	cc.mu.Lock()
+	arms := cc.arms
+	cc.mu.Unlock()
+	if arms[0] == nil {
+		return CanaryStats{}, CanaryStats{}
+	}
+	return arms[0].snapshot(), arms[1].snapshot()
+}
+
+func (a *canaryArm) snapshot() CanaryStats {
+	return CanaryStats{
+		Requests:       atomic.LoadInt64(&a.stats.Requests),
+		OriginRequests: atomic.LoadInt64(&a.stats.OriginRequests),
+		NotModified:    atomic.LoadInt64(&a.stats.NotModified),
+	}
+}
+
+// owner returns the client whose shared state cc uses: the client that created it for canary arms,
+// and cc itself otherwise
+func (cc *CachedClient) owner() *CachedClient {
+	if cc.parent != nil {
+		return cc.parent
+	}
+	return cc
+}
@@ -0,0 +1,78 @@
+package httpcache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestCanary(t *testing.T) {
+	resetTest()
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options: CacheOptions{
+			MarkCachedResponses: true,
+			// The canary never serves from the cache
+			Canary: &CanaryOptions{Options: CacheOptions{WriteOnly: true}, Percent: 50},
+		},
+	}
+
+	const urls = 40
+	for round := 0; round < 2; round++ {
+		for i := 0; i < urls; i++ {
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s?page=%d", s.server.URL, i), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+	}
+
+	control, canary := client.CanaryStats()
+	if control.Requests+canary.Requests != 2*urls {
+		t.Fatalf("got %d+%d requests, want %d", control.Requests, canary.Requests, 2*urls)
+	}
+	if control.Requests == 0 || canary.Requests == 0 {
+		t.Fatalf("got %d control and %d canary requests, want both policies used", control.Requests, canary.Requests)
+	}
+	if control.OriginRequests != control.Requests/2 || control.HitRatio() != 0.5 {
+		t.Fatalf("control: got %d origin requests out of %d, want half", control.OriginRequests, control.Requests)
+	}
+	if canary.OriginRequests != canary.Requests || canary.HitRatio() != 0 {
+		t.Fatalf("canary: got %d origin requests out of %d, want all", canary.OriginRequests, canary.Requests)
+	}
+}
+
+func TestCanaryRoutingIsStable(t *testing.T) {
+	client := &CachedClient{Options: CacheOptions{Canary: &CanaryOptions{Percent: 30}}}
+	for i := 0; i < 20; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://example.com/%d", i), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.canaryArm(req) != client.canaryArm(req) {
+			t.Fatalf("request %d routed to different policies", i)
+		}
+	}
+}
+
+func TestCanaryDisabled(t *testing.T) {
+	client := &CachedClient{}
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.canaryArm(req) != nil {
+		t.Fatal("request routed to a canary arm without canary options")
+	}
+	if control, canary := client.CanaryStats(); control.Requests != 0 || canary.Requests != 0 {
+		t.Fatal("got stats without canary options")
+	}
+}
@@ -0,0 +1,133 @@
+package httpcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCircuitFailures is the default number of consecutive backend errors tripping a CircuitBreakerCache
+	DefaultCircuitFailures = 5
+	// DefaultCircuitCooldown is the default time a CircuitBreakerCache stays open before retrying the backend
+	DefaultCircuitCooldown = 30 * time.Second
+)
+
+// CircuitBreakerOptions configures a CircuitBreakerCache
+type CircuitBreakerOptions struct {
+	// Failures is the number of consecutive backend errors that opens the circuit. Defaults to
+	// DefaultCircuitFailures.
+	Failures int
+	// Cooldown is how long the circuit stays open before a single trial operation is let through
+	// to the backend. Defaults to DefaultCircuitCooldown.
+	Cooldown time.Duration
+}
+
+// CircuitBreakerCache is a CacheV2 wrapper that stops calling a failing backend, so requests don't
+// pay its timeouts on every lookup while it is down. After Failures consecutive errors the circuit
+// opens: Get reports misses and Set and Delete are skipped without reaching the backend. Once
+// Cooldown has elapsed one operation is let through, closing the circuit if it succeeds.
+//
+// ErrCacheMiss isn't a failure, and neither are the errors of operations whose context was
+// canceled or expired, as they tell nothing about the backend health.
+type CircuitBreakerCache struct {
+	inner CacheV2
+	opts  CircuitBreakerOptions
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreakerCache returns a new CircuitBreakerCache guarding inner
+func NewCircuitBreakerCache(inner CacheV2, opts CircuitBreakerOptions) *CircuitBreakerCache {
+	if opts.Failures <= 0 {
+		opts.Failures = DefaultCircuitFailures
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = DefaultCircuitCooldown
+	}
+	return &CircuitBreakerCache{inner: inner, opts: opts}
+}
+
+// Open reports whether the circuit is open, bypassing the backend
+func (c *CircuitBreakerCache) Open() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.openedAt.IsZero()
+}
+
+// Get returns the []byte representation of the response stored with key, or ErrCacheMiss if there
+// is none or the circuit is open
+func (c *CircuitBreakerCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if !c.allow() {
+		return nil, ErrCacheMiss
+	}
+	val, err := c.inner.Get(ctx, key)
+	c.record(ctx, err)
+	return val, err
+}
+
+// Set stores the []byte representation of a response with key, unless the circuit is open
+func (c *CircuitBreakerCache) Set(ctx context.Context, key string, responseBytes []byte, ttl int) error {
+	if !c.allow() {
+		return nil
+	}
+	err := c.inner.Set(ctx, key, responseBytes, ttl)
+	c.record(ctx, err)
+	return err
+}
+
+// Delete removes key from the backend, unless the circuit is open
+func (c *CircuitBreakerCache) Delete(ctx context.Context, key string) error {
+	if !c.allow() {
+		return nil
+	}
+	err := c.inner.Delete(ctx, key)
+	c.record(ctx, err)
+	return err
+}
+
+// Keys returns the keys of the backend, if it implements KeyLister and the circuit is closed
+func (c *CircuitBreakerCache) Keys() []string {
+	kl, ok := c.inner.(KeyLister)
+	if !ok || c.Open() {
+		return nil
+	}
+	return kl.Keys()
+}
+
+// allow reports whether an operation may reach the backend. While the circuit is open, only one
+// trial operation is allowed once the cooldown has elapsed.
+func (c *CircuitBreakerCache) allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.openedAt.IsZero() {
+		return true
+	}
+	if c.trial || clock.since(c.openedAt) < c.opts.Cooldown {
+		return false
+	}
+	c.trial = true
+	return true
+}
+
+// record updates the circuit with the outcome of an operation
+func (c *CircuitBreakerCache) record(ctx context.Context, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wasTrial := c.trial
+	c.trial = false
+	if err == nil || err == ErrCacheMiss {
+		c.failures, c.openedAt = 0, time.Time{}
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	c.failures++
+	if wasTrial || c.failures >= c.opts.Failures {
+		c.openedAt = time.Now()
+	}
+}
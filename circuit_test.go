@@ -0,0 +1,98 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyCache is a CacheV2 failing every operation while down is set
+type flakyCache struct {
+	CacheV2
+	down  bool
+	calls int
+}
+
+var errBackendDown = errors.New("backend down")
+
+func (c *flakyCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.calls++
+	if c.down {
+		return nil, errBackendDown
+	}
+	return c.CacheV2.Get(ctx, key)
+}
+
+func (c *flakyCache) Set(ctx context.Context, key string, responseBytes []byte, ttl int) error {
+	c.calls++
+	if c.down {
+		return errBackendDown
+	}
+	return c.CacheV2.Set(ctx, key, responseBytes, ttl)
+}
+
+func TestCircuitBreakerCache(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	ctx := context.Background()
+	backend := &flakyCache{CacheV2: AdaptCache(NewMemoryCache())}
+	c := NewCircuitBreakerCache(backend, CircuitBreakerOptions{Failures: 3, Cooldown: time.Minute})
+
+	if err := c.Set(ctx, "a", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "missing"); err != ErrCacheMiss {
+		t.Fatalf("got error %v, want ErrCacheMiss", err)
+	}
+
+	backend.down = true
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(ctx, "a"); err != errBackendDown {
+			t.Fatalf("got error %v, want the backend error", err)
+		}
+	}
+	if !c.Open() {
+		t.Fatal("circuit didn't open after 3 failures")
+	}
+
+	calls := backend.calls
+	if _, err := c.Get(ctx, "a"); err != ErrCacheMiss {
+		t.Fatalf("got error %v with the circuit open, want ErrCacheMiss", err)
+	}
+	if err := c.Set(ctx, "b", []byte("2"), 0); err != nil {
+		t.Fatalf("got error %v with the circuit open, want none", err)
+	}
+	if backend.calls != calls {
+		t.Fatal("backend called with the circuit open")
+	}
+
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	if _, err := c.Get(ctx, "a"); err != errBackendDown {
+		t.Fatalf("got error %v, want the trial operation to reach the backend", err)
+	}
+	clock = &realClock{}
+	if !c.Open() || backend.calls != calls+1 {
+		t.Fatal("failed trial operation didn't reopen the circuit")
+	}
+
+	backend.down = false
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	if val, err := c.Get(ctx, "a"); err != nil || string(val) != "1" {
+		t.Fatalf("got %q, %v, want the stored entry", val, err)
+	}
+	if c.Open() {
+		t.Fatal("successful trial operation didn't close the circuit")
+	}
+}
+
+func TestCircuitBreakerCacheIgnoresCanceledContexts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	backend := &flakyCache{CacheV2: AdaptCache(NewMemoryCache()), down: true}
+	c := NewCircuitBreakerCache(backend, CircuitBreakerOptions{Failures: 1})
+	c.Get(ctx, "a")
+	if c.Open() {
+		t.Fatal("circuit opened on a canceled operation")
+	}
+}
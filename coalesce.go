@@ -0,0 +1,113 @@
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// flightGroup coalesces concurrent upstream requests sharing the same key into a single
+// round trip, whose buffered response is handed to every caller
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{} // closed once the call completes
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// do executes fn once for all the concurrent callers using key. The first caller gets
+// shared set to false; callers that waited on its round trip get shared set to true. Every
+// caller gets its own copy of the response. Waiting callers stop waiting once their own ctx is
+// done, and get its error.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (*http.Response, error)) (resp *http.Response, shared bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		}
+		if c.err != nil {
+			return nil, true, c.err
+		}
+		return c.response(), true, nil
+	}
+	c := &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	g.call(key, c, fn)
+	if c.err != nil {
+		return nil, false, c.err
+	}
+	return c.response(), false, nil
+}
+
+// call runs fn for c, releasing the callers waiting on it even if fn panics. The waiting callers
+// get an error while the panic goes on in the calling goroutine.
+func (g *flightGroup) call(key string, c *flightCall, fn func() (*http.Response, error)) {
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("httpcache: coalesced request panicked: %v", r)
+			panic(r)
+		}
+	}()
+
+	c.resp, c.err = fn()
+	if c.err == nil {
+		c.body, c.err = ioutil.ReadAll(c.resp.Body)
+		c.resp.Body.Close()
+	}
+}
+
+// response returns a copy of the call response, with its own headers and body reader
+func (c *flightCall) response() *http.Response {
+	resp := *c.resp
+	resp.Header = make(http.Header, len(c.resp.Header))
+	for k, v := range c.resp.Header {
+		resp.Header[k] = append([]string(nil), v...)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(c.body))
+	return &resp
+}
+
+// flightKey returns the key used to coalesce req: requests are only coalesced when both
+// their cache key and their headers are identical, so responses never leak between callers
+// with different credentials or content negotiation
+func flightKey(cacheKey string, req *http.Request) string {
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(cacheKey)
+	for _, name := range names {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(req.Header[name], ", "))
+	}
+	return b.String()
+}
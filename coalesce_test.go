@@ -0,0 +1,150 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalesceRequests(t *testing.T) {
+	resetTest()
+	var calls int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("shared body"))
+	}))
+	defer server.Close()
+
+	cache := NewMemoryCache()
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{CoalesceRequests: true}}
+
+	const n = 10
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			b, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			bodies[i], errs[i] = string(b), err
+		}(i)
+	}
+	// Give every goroutine a chance to join the in-flight request
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if bodies[i] != "shared body" {
+			t.Fatalf("got body %q, want shared body", bodies[i])
+		}
+	}
+	if c := atomic.LoadInt32(&calls); c != 1 {
+		t.Fatalf("origin called %d times, want 1", c)
+	}
+	if _, ok := cache.Get(server.URL); !ok {
+		t.Fatal("coalesced response wasn't stored")
+	}
+}
+
+func TestFlightKeyIncludesHeaders(t *testing.T) {
+	a, _ := http.NewRequest("GET", "http://example.com/", nil)
+	b, _ := http.NewRequest("GET", "http://example.com/", nil)
+	b.Header.Set("Authorization", "Bearer other")
+	if flightKey(cacheKey(a), a) == flightKey(cacheKey(b), b) {
+		t.Fatal("requests with different headers share a flight key")
+	}
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+	var g flightGroup
+	started, release := make(chan struct{}), make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() { panicked <- recover() }()
+		g.do(context.Background(), "key", func() (*http.Response, error) {
+			close(started)
+			<-release
+			panic("transport panic")
+		})
+	}()
+	<-started
+
+	errs := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(context.Background(), "key", func() (*http.Response, error) {
+			t.Error("waiting caller executed the request")
+			return nil, nil
+		})
+		errs <- err
+	}()
+	// Give the second caller a chance to join the in-flight request
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if r := <-panicked; r != "transport panic" {
+		t.Fatalf("got panic %v, want it propagated to the executing caller", r)
+	}
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("waiting caller got no error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting caller blocked after the panic")
+	}
+	if len(g.calls) != 0 {
+		t.Fatal("panicked call wasn't removed")
+	}
+}
+
+func TestFlightGroupWaiterCanceled(t *testing.T) {
+	var g flightGroup
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	go g.do(context.Background(), "key", func() (*http.Response, error) {
+		close(started)
+		<-release
+		return nil, errors.New("origin unreachable")
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(ctx, "key", func() (*http.Response, error) {
+			t.Error("waiting caller executed the request")
+			return nil, nil
+		})
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("got error %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting caller kept waiting past its deadline")
+	}
+}
@@ -0,0 +1,100 @@
+package httpcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// A Codec compresses and decompresses cache entries. Implementations must be safe for concurrent use.
+type Codec interface {
+	// Compress returns the compressed form of b
+	Compress(b []byte) ([]byte, error)
+	// Decompress returns the original form of b, compressed by Compress
+	Decompress(b []byte) ([]byte, error)
+}
+
+// GzipCodec is a Codec compressing entries with gzip
+type GzipCodec struct {
+	// Level is the gzip compression level. Zero means gzip.DefaultCompression.
+	Level int
+}
+
+// Compress returns the gzip compressed form of b
+func (c GzipCodec) Compress(b []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress returns the original form of b, compressed by Compress
+func (c GzipCodec) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// CompressedCache is a Cache wrapper compressing entries with a Codec before storing them in the
+// wrapped cache, reducing the footprint of large textual responses in remote or disk backends.
+// Entries that can't be decompressed (such as the ones stored before compression was enabled) are
+// reported as missing.
+type CompressedCache struct {
+	inner Cache
+	codec Codec
+}
+
+// NewCompressedCache returns a new CompressedCache storing entries in inner, compressed with codec
+func NewCompressedCache(inner Cache, codec Codec) *CompressedCache {
+	return &CompressedCache{inner: inner, codec: codec}
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *CompressedCache) Get(key string) (resp []byte, ok bool) {
+	b, ok := c.inner.Get(key)
+	if !ok {
+		return nil, false
+	}
+	resp, err := c.codec.Decompress(b)
+	if err != nil {
+		return nil, false
+	}
+	return resp, true
+}
+
+// Set saves response resp compressed to the cache with key
+func (c *CompressedCache) Set(key string, resp []byte, ttl int) {
+	b, err := c.codec.Compress(resp)
+	if err != nil {
+		return
+	}
+	c.inner.Set(key, b, ttl)
+}
+
+// Delete removes key from the cache
+func (c *CompressedCache) Delete(key string) {
+	c.inner.Delete(key)
+}
+
+// Keys returns the keys of the wrapped cache, if it implements KeyLister
+func (c *CompressedCache) Keys() []string {
+	if kl, ok := c.inner.(KeyLister); ok {
+		return kl.Keys()
+	}
+	return nil
+}
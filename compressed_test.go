@@ -0,0 +1,34 @@
+package httpcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+func TestCompressedCache(t *testing.T) {
+	inner := NewMemoryCache()
+	c := NewCompressedCache(inner, GzipCodec{Level: gzip.BestCompression})
+	entry := bytes.Repeat([]byte(`{"id":1,"name":"item"},`), 100)
+
+	c.Set("key", entry, 0)
+	stored, ok := inner.Get("key")
+	if !ok || len(stored) >= len(entry) {
+		t.Fatalf("got %d stored bytes (found: %v), want fewer than %d", len(stored), ok, len(entry))
+	}
+	if got, ok := c.Get("key"); !ok || !bytes.Equal(got, entry) {
+		t.Fatalf("got %d bytes (found: %v), want the original entry", len(got), ok)
+	}
+
+	inner.Set("plain", entry, 0)
+	if _, ok := c.Get("plain"); ok {
+		t.Fatal("got an uncompressed entry, want it reported as missing")
+	}
+	if keys := c.Keys(); len(keys) != 2 {
+		t.Fatalf("got keys %v, want the keys of the inner cache", keys)
+	}
+	c.Delete("key")
+	if _, ok := inner.Get("key"); ok {
+		t.Fatal("entry left in the inner cache after Delete")
+	}
+}
@@ -0,0 +1,138 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultTombstoneTTL is how long invalidated keys are remembered when CacheOptions.TombstoneTTL isn't set
+const DefaultTombstoneTTL = 30 * time.Second
+
+// keyLockStripes is the number of mutexes keyLocks spreads keys over
+const keyLockStripes = 64
+
+// keyLocks serializes the writes and invalidations of a key, so checking its tombstone and writing
+// it happen atomically. Keys are hashed over a fixed set of mutexes, so unrelated keys may share one.
+type keyLocks [keyLockStripes]sync.Mutex
+
+// lock locks key and returns the function unlocking it
+func (l *keyLocks) lock(key string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	m := &l[h.Sum32()%keyLockStripes]
+	m.Lock()
+	return m.Unlock
+}
+
+// tombstones remembers when keys were invalidated, so responses fetched before that can't be
+// stored back once their request completes
+type tombstones struct {
+	mu          sync.Mutex
+	at          map[string]time.Time
+	collections []collectionTombstone
+}
+
+// collectionTombstone records the invalidation of a whole collection, see InvalidateCollection
+type collectionTombstone struct {
+	base *url.URL
+	at   time.Time
+}
+
+// tombstoneTTL returns the time invalidated keys are remembered for
+func (cc *CachedClient) tombstoneTTL() time.Duration {
+	if ttl := cc.owner().Options.TombstoneTTL; ttl > 0 {
+		return ttl
+	}
+	return DefaultTombstoneTTL
+}
+
+// bury records that key was invalidated now, dropping the expired tombstones
+func (cc *CachedClient) bury(key string) {
+	t := &cc.owner().tombs
+	ttl := cc.tombstoneTTL()
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.at == nil {
+		t.at = map[string]time.Time{}
+	}
+	for k, at := range t.at {
+		if now.Sub(at) >= ttl {
+			delete(t.at, k)
+		}
+	}
+	t.at[key] = now
+}
+
+// buryCollection records that the collection designated by base was invalidated now, so the
+// entries of the collection being fetched aren't stored
+func (cc *CachedClient) buryCollection(base *url.URL) {
+	t := &cc.owner().tombs
+	ttl := cc.tombstoneTTL()
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	live := t.collections[:0]
+	for _, c := range t.collections {
+		if now.Sub(c.at) < ttl {
+			live = append(live, c)
+		}
+	}
+	t.collections = append(live, collectionTombstone{base: base, at: now})
+}
+
+// buriedSince returns true if key was invalidated after started, so a response fetched by a
+// request started then must not be stored
+func (cc *CachedClient) buriedSince(key string, started time.Time) bool {
+	t := &cc.owner().tombs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ttl := cc.tombstoneTTL()
+	if at, ok := t.at[key]; ok && !at.Before(started) && time.Since(at) < ttl {
+		return true
+	}
+	for _, c := range t.collections {
+		if !c.at.Before(started) && time.Since(c.at) < ttl && inCollection(c.base, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateEntry removes the entry stored under key, and prevents the requests in flight from
+// storing it back once they complete
+func (cc *CachedClient) invalidateEntry(ctx context.Context, key string) {
+	unlock := cc.owner().keyLocks.lock(key)
+	defer unlock()
+	cc.bury(key)
+	cc.evictEntry(ctx, key)
+}
+
+// isUnsafeMethod returns true if method may change the state of the origin (RFC 9110 section 9.2.1)
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// invalidateTarget invalidates the GET and HEAD entries of the target URI of req, an unsafe request
+// that received resp, as required by RFC 9111 section 4.4 when resp isn't an error
+func (cc *CachedClient) invalidateTarget(req *http.Request, resp *http.Response) {
+	if !cc.Options.InvalidateOnUnsafeMethods || !isUnsafeMethod(req.Method) || resp.StatusCode >= 400 {
+		return
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		target := *req
+		target.Method = method
+		key := cc.cacheKey(&target)
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) invalidating entry (reason: unsafe method %v) for key %v", req, req.Method, key))
+		cc.invalidateEntry(req.Context(), key)
+	}
+}
@@ -0,0 +1,93 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReadYourWritesAfterInvalidation(t *testing.T) {
+	var fetched int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			atomic.AddInt32(&fetched, 1)
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{InvalidateOnUnsafeMethods: true},
+	}
+	do := func(method string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	read := func(resp *http.Response) {
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	origin := func(want int32) {
+		t.Helper()
+		if got := atomic.LoadInt32(&fetched); got != want {
+			t.Fatalf("got %d GET requests to the origin, want %d", got, want)
+		}
+	}
+
+	read(do("GET"))
+	read(do("GET"))
+	origin(1)
+	read(do("POST"))
+	read(do("GET"))
+	origin(2)
+
+	// A response fetched before an invalidation isn't stored once it completes
+	read(do("PUT"))
+	slow := do("GET")
+	origin(3)
+	read(do("DELETE"))
+	read(slow)
+	read(do("GET"))
+	read(do("GET"))
+	origin(4)
+
+	// Same with an explicit purge
+	read(do("PUT"))
+	slow = do("GET")
+	if _, err := client.InvalidateCollection(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	read(slow)
+	read(do("GET"))
+	origin(6)
+}
+
+func TestUnsafeMethodsKeepEntriesByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	for _, method := range []string{"GET", "POST", "GET"} {
+		req, _ := http.NewRequest(method, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if _, ok := client.Cache.Get(server.URL); !ok {
+		t.Fatal("entry removed by an unsafe request without InvalidateOnUnsafeMethods")
+	}
+}
@@ -0,0 +1,76 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers steering the cache for a single request when CacheOptions.ControlHeaders is set.
+// They are never forwarded to the origin.
+const (
+	// BypassHeader, set to 1, has the request skip the cache entirely: the cache is neither read
+	// nor written
+	BypassHeader = "X-Httpcache-Bypass"
+	// TTLHeader, set to a number of seconds, has the response stored for as long, like WithTTL
+	TTLHeader = "X-Httpcache-TTL"
+	// DebugHeader, set to 1, has the handling of the request logged, like WithDebug
+	DebugHeader = "X-Httpcache-Debug"
+)
+
+// controlHeaderPrefix is the prefix of the control headers, which are stripped from requests, and of
+// the internal headers recorded in stored responses
+const controlHeaderPrefix = "X-Httpcache-"
+
+// applyControlHeaders returns req with the control headers it carries applied to its context, and
+// removed from its headers, along with true if it bypasses the cache. req is returned as is if
+// Options.ControlHeaders isn't set or it carries none.
+func (cc *CachedClient) applyControlHeaders(req *http.Request) (*http.Request, bool) {
+	if !cc.Options.ControlHeaders {
+		return req, false
+	}
+	var control http.Header
+	for name, values := range req.Header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), controlHeaderPrefix) {
+			if control == nil {
+				control = http.Header{}
+			}
+			control[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	if control == nil {
+		return req, false
+	}
+
+	ctx := req.Context()
+	if control.Get(DebugHeader) == "1" {
+		ctx = WithDebug(ctx)
+	}
+	if secs, err := strconv.ParseInt(strings.TrimSpace(control.Get(TTLHeader)), 10, 64); err == nil && secs > 0 {
+		ctx = WithTTL(ctx, time.Duration(secs)*time.Second)
+	}
+	req = cloneRequest(req.WithContext(ctx))
+	for name := range req.Header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), controlHeaderPrefix) {
+			delete(req.Header, name)
+		}
+	}
+	cc.log(ctx, fmt.Sprintf("[httpcache](%p) applied control headers %v", req, control))
+	return req, control.Get(BypassHeader) == "1"
+}
+
+// doBypass does req without reading nor writing the cache, as requested through BypassHeader
+func (cc *CachedClient) doBypass(req *http.Request) (*http.Response, error) {
+	key := cc.cacheKey(req)
+	cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) bypass requested. executing remote request", req),
+		"key", key, "outcome", DecisionBypass.String())
+	cc.emit(Event{Type: EventDecision, Key: key, Decision: DecisionBypass})
+	resp, err := cc.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	cc.setCacheStatus(resp, cacheStatus{fwd: fwdBypass, fwdStatus: resp.StatusCode})
+	return resp, nil
+}
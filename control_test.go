@@ -0,0 +1,64 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestControlHeaders(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var requests int
+	var forwarded http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		forwarded = r.Header
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	for _, enabled := range []bool{false, true} {
+		requests = 0
+		cache := NewMemoryCache()
+		client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{ControlHeaders: enabled}}
+		do := func(headers map[string]string, elapsed time.Duration) {
+			clock = &fakeClock{elapsed: elapsed}
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			for name, value := range headers {
+				req.Header.Set(name, value)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		do(map[string]string{"X-Httpcache-TTL": "300"}, 0)
+		if stripped := forwarded.Get(TTLHeader) == ""; stripped != enabled {
+			t.Fatalf("enabled %v: got the TTL header stripped %v", enabled, stripped)
+		}
+		do(nil, time.Minute)
+		if want := map[bool]int{false: 2, true: 1}[enabled]; requests != want {
+			t.Fatalf("enabled %v: got %d origin requests, want %d", enabled, requests, want)
+		}
+		if !enabled {
+			continue
+		}
+
+		do(map[string]string{BypassHeader: "1"}, time.Minute)
+		if requests != 2 || forwarded.Get(BypassHeader) != "" {
+			t.Fatalf("got %d origin requests, want the bypassing request forwarded without control headers", requests)
+		}
+		if keys := cache.Keys(); len(keys) != 1 {
+			t.Fatalf("got keys %v, want the entry kept by the bypassing request", keys)
+		}
+		do(nil, time.Minute)
+		if requests != 2 {
+			t.Fatalf("got %d origin requests, want the entry still served", requests)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// cacheDecision is the outcome of looking up the cached entry for a request and evaluating it
+type cacheDecision struct {
+	resp        *http.Response
+	err         error
+	varyMatches bool
+	freshness   entryFreshness
+	// revalidateBy, if not zero, is the end of the stale-while-revalidate window of a stale entry
+	// that can be served while revalidated in the background
+	revalidateBy time.Time
+	// lookup is the time spent retrieving the entry from the backend
+	lookup time.Duration
+}
+
+// decide retrieves the entry stored under key and evaluates whether it can be used for req
+func (cc *CachedClient) decide(req *http.Request, key string) cacheDecision {
+	var d cacheDecision
+	misses := cc.negativeLookups(req.Context())
+	if misses.knownMiss(key) {
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) known miss for key %v. skipping cache lookup", req, key))
+		return d
+	}
+	seq := misses.lookupSeq()
+	start := time.Now()
+	if sc, ok := cc.streamingCache(req.Context()); ok {
+		d.resp, d.err = cachedResponseStream(sc, key, req)
+	} else {
+		d.resp, d.err = cachedResponse(cc.backend(req.Context()), key, req)
+	}
+	d.lookup = time.Since(start)
+	if d.err == ErrCacheMiss {
+		d.err = nil
+		misses.rememberMiss(key, seq)
+	}
+	if d.resp == nil || d.err != nil {
+		return d
+	}
+
+	d.varyMatches = cc.varyMatches(d.resp, req)
+	if !d.varyMatches {
+		if variant := cc.lookupVariant(req, key, d.resp); variant != nil {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) stored variant matches request for key %v", req, key))
+			d.resp.Body.Close()
+			d.resp, d.varyMatches = variant, true
+		}
+	}
+	if d.varyMatches {
+		d.freshness = cc.getFreshness(req, d.resp.Header)
+		if d.freshness == fresh && varyStar(d.resp.Header) {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) response vary * header found. downgrading to stale freshness", req))
+			d.freshness = stale
+		}
+		if d.freshness == fresh && cc.sunsetImminent(d.resp) {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) resource sunset within refresh window. downgrading to stale freshness", req))
+			d.freshness = stale
+		}
+	}
+	if name := cc.Options.BodyHashHeader; name != "" {
+		if hash := d.resp.Header.Get(bodyHashHeader); hash != "" {
+			d.resp.Header.Set(name, hash)
+		}
+	}
+	if d.resp.Header.Get(softPurgedHeader) != "" {
+		// Dropped so the marker isn't stored back once the entry is revalidated
+		d.resp.Header.Del(softPurgedHeader)
+		if d.freshness == fresh {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) entry soft purged. downgrading to stale freshness", req))
+			d.freshness = stale
+		}
+	}
+	if d.freshness == stale {
+		if deadline, ok := cc.staleWhileRevalidate(req, d.resp.Header); ok {
+			d.revalidateBy = deadline
+		}
+	}
+	return d
+}
+
+// decideWithin runs decide, giving up once the DecisionTimeout elapses. The lookup runs under a
+// context bounded by the timeout, so that context-aware backends give up too. A decision that took
+// too long is reported as a cache miss, and its entry is released in the background whenever it
+// completes.
+func (cc *CachedClient) decideWithin(req *http.Request, key string) cacheDecision {
+	if cc.Options.DecisionTimeout <= 0 {
+		return cc.decide(req, key)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), cc.Options.DecisionTimeout)
+	decisions := make(chan cacheDecision, 1)
+	go func() {
+		decisions <- cc.decide(req.WithContext(ctx), key)
+	}()
+
+	timeout := time.NewTimer(cc.Options.DecisionTimeout)
+	defer timeout.Stop()
+	select {
+	case d := <-decisions:
+		cancel()
+		if d.resp != nil {
+			// The entry answers req, not the request bounded by the decision timeout
+			d.resp.Request = req
+		}
+		return d
+	case <-timeout.C:
+		go func() {
+			defer cancel()
+			if d := <-decisions; d.resp != nil {
+				d.resp.Body.Close()
+			}
+		}()
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) cache decision timed out after %v for key %v. treating as a miss", req, cc.Options.DecisionTimeout, key))
+		return cacheDecision{lookup: cc.Options.DecisionTimeout}
+	}
+}
+
+// serveHit returns resp, the response to req built from the entry stored under key, found in
+// lookup, once the hit is accounted for
+func (cc *CachedClient) serveHit(req *http.Request, key string, resp *http.Response, lookup time.Duration) *http.Response {
+	e := Event{Type: EventDecision, Key: key, Decision: DecisionHit, Latency: lookup}
+	cc.emit(e)
+	cc.hook(req, e)
+	if cc.Options.MarkCachedResponses {
+		resp.Header.Set(XFromCache, "1")
+	}
+	if cc.expired(resp.Header) {
+		cc.labelStale(key, resp, false)
+	}
+	cc.countServed(resp, false)
+	cc.setCacheStatus(resp, cc.withTTL(cacheStatus{hit: true}, req, resp))
+	return resp
+}
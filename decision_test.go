@@ -0,0 +1,116 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// slowCache is an empty Cache whose reads block until release is closed
+type slowCache struct {
+	*MemoryCache
+	release chan struct{}
+}
+
+func (c *slowCache) Get(key string) ([]byte, bool) {
+	<-c.release
+	return nil, false
+}
+
+func TestDecisionTimeout(t *testing.T) {
+	resetTest()
+	c := &slowCache{MemoryCache: NewMemoryCache(), release: make(chan struct{})}
+	defer close(c.release)
+	client := &CachedClient{
+		Cache:     c,
+		Transport: &http.Transport{},
+		Options:   CacheOptions{MarkCachedResponses: true, DecisionTimeout: 10 * time.Millisecond},
+	}
+
+	req, err := http.NewRequest("GET", s.server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("request took %v despite the decision timeout", elapsed)
+	}
+}
+
+func TestDecisionWithinTimeout(t *testing.T) {
+	resetTest()
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{MarkCachedResponses: true, DecisionTimeout: time.Minute},
+	}
+
+	for i, want := range []string{"", "1"} {
+		req, err := http.NewRequest("GET", s.server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got := resp.Header.Get(XFromCache); got != want {
+			t.Fatalf("request %d: got XFromCache %q, want %q", i, got, want)
+		}
+	}
+}
+
+// hungCacheV2 is an empty CacheV2 whose reads block until their context is done
+type hungCacheV2 struct {
+	returned chan struct{}
+}
+
+func (c *hungCacheV2) Get(ctx context.Context, key string) ([]byte, error) {
+	<-ctx.Done()
+	close(c.returned)
+	return nil, ctx.Err()
+}
+
+func (c *hungCacheV2) Set(ctx context.Context, key string, responseBytes []byte, ttl int) error {
+	return nil
+}
+
+func (c *hungCacheV2) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func TestDecisionTimeoutCancelsLookup(t *testing.T) {
+	resetTest()
+	backend := &hungCacheV2{returned: make(chan struct{})}
+	client := &CachedClient{
+		CacheV2:   backend,
+		Transport: &http.Transport{},
+		Options:   CacheOptions{DecisionTimeout: 10 * time.Millisecond},
+	}
+
+	req, err := http.NewRequest("GET", s.server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	select {
+	case <-backend.returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out lookup wasn't cancelled")
+	}
+}
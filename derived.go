@@ -0,0 +1,95 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// derivedIndexSuffix is appended to a response cache key to build the key of the index listing
+// the artifacts derived from that response
+const derivedIndexSuffix = " derived"
+
+// GetOrFetch implements the cache-aside pattern on top of c: it returns the value stored under key
+// if present, and otherwise calls fetch, stores the returned value with the returned TTL and returns it.
+// Errors returned by fetch are passed through and nothing is stored.
+func GetOrFetch(c Cache, key string, fetch func() ([]byte, int, error)) ([]byte, error) {
+	if val, ok := c.Get(key); ok {
+		return val, nil
+	}
+
+	val, ttl, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.Set(key, val, ttl)
+	return val, nil
+}
+
+// GetOrFetch works like the package level GetOrFetch for a non-HTTP artifact called name that is
+// derived from the response to req (a parsed index, a thumbnail...). The artifact is stored next to
+// the cached response and is purged together with it, whenever the response is evicted or replaced.
+// The artifacts are tracked by the client that stored them: those stored by another process sharing
+// the backend aren't purged.
+func (cc *CachedClient) GetOrFetch(req *http.Request, name string, fetch func() ([]byte, int, error)) ([]byte, error) {
+	ctx := req.Context()
+	key := cc.cacheKey(req)
+	derivedKey := key + derivedIndexSuffix + ":" + name
+	if val, err := cc.backend(ctx).Get(ctx, derivedKey); err == nil {
+		return val, nil
+	}
+
+	val, ttl, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	cc.backend(ctx).Set(ctx, derivedKey, val, ttl)
+	cc.addDerived(ctx, key, name)
+	return val, nil
+}
+
+func (cc *CachedClient) addDerived(ctx context.Context, key, name string) {
+	owner := cc.owner()
+	owner.mu.Lock()
+	defer owner.mu.Unlock()
+	if owner.derived == nil {
+		owner.derived = map[string]bool{}
+	}
+	owner.derived[key] = true
+
+	indexKey := key + derivedIndexSuffix
+	index, _ := cc.backend(ctx).Get(ctx, indexKey)
+	for _, existing := range strings.Split(string(index), "\n") {
+		if existing == name {
+			return
+		}
+	}
+	if len(index) > 0 {
+		index = append(index, '\n')
+	}
+	cc.backend(ctx).Set(ctx, indexKey, append(index, name...), 0)
+}
+
+// purgeDerived removes every artifact derived from the response stored under key. The index is
+// only looked up if the client stored artifacts for key, so that writes don't cost an extra read
+// otherwise.
+func (cc *CachedClient) purgeDerived(ctx context.Context, key string) {
+	owner := cc.owner()
+	owner.mu.Lock()
+	derived := owner.derived[key]
+	delete(owner.derived, key)
+	owner.mu.Unlock()
+	if !derived {
+		return
+	}
+
+	indexKey := key + derivedIndexSuffix
+	index, err := cc.backend(ctx).Get(ctx, indexKey)
+	if err != nil {
+		return
+	}
+	for _, name := range strings.Split(string(index), "\n") {
+		cc.backend(ctx).Delete(ctx, indexKey+":"+name)
+	}
+	cc.backend(ctx).Delete(ctx, indexKey)
+}
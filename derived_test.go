@@ -0,0 +1,122 @@
+package httpcache
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetOrFetch(t *testing.T) {
+	c := NewMemoryCache()
+	calls := 0
+	fetch := func() ([]byte, int, error) {
+		calls++
+		return []byte("value"), 0, nil
+	}
+	for i := 0; i < 2; i++ {
+		val, err := GetOrFetch(c, "key", fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != "value" {
+			t.Fatalf("got %q, want value", val)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("fetch called %d times, want 1", calls)
+	}
+
+	_, err := GetOrFetch(c, "failing", func() ([]byte, int, error) {
+		return nil, 0, errors.New("fetch error")
+	})
+	if err == nil {
+		t.Fatal("fetch error wasn't returned")
+	}
+	if _, ok := c.Get("failing"); ok {
+		t.Fatal("value stored despite the fetch error")
+	}
+}
+
+func TestGetOrFetchPurgedWithResponse(t *testing.T) {
+	resetTest()
+	req, err := http.NewRequest("GET", s.server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	calls := 0
+	fetch := func() ([]byte, int, error) {
+		calls++
+		return []byte("derived"), 0, nil
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.client.GetOrFetch(req, "index", fetch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("fetch called %d times, want 1", calls)
+	}
+
+	s.client.evictEntry(req.Context(), cacheKey(req))
+	if _, err := s.client.GetOrFetch(req, "index", fetch); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatal("derived artifact wasn't purged with its response")
+	}
+}
+
+// getRecordingCache is a MemoryCache recording the keys read from it
+type getRecordingCache struct {
+	*MemoryCache
+	gets []string
+}
+
+func (c *getRecordingCache) Get(key string) ([]byte, bool) {
+	c.gets = append(c.gets, key)
+	return c.MemoryCache.Get(key)
+}
+
+func TestDerivedIndexReadOnlyIfUsed(t *testing.T) {
+	resetTest()
+	cache := &getRecordingCache{MemoryCache: NewMemoryCache()}
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}}
+	req, err := http.NewRequest("GET", s.server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func() {
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		client.evictEntry(req.Context(), cacheKey(req))
+	}
+
+	do()
+	for _, key := range cache.gets {
+		if strings.HasSuffix(key, derivedIndexSuffix) {
+			t.Fatalf("got derived index %q read without derived artifacts", key)
+		}
+	}
+	if _, err := client.GetOrFetch(req, "index", func() ([]byte, int, error) {
+		return []byte("derived"), 0, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	do()
+	if _, ok := cache.MemoryCache.Get(cacheKey(req) + derivedIndexSuffix + ":index"); ok {
+		t.Fatal("derived artifact wasn't purged with its response")
+	}
+}
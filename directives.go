@@ -0,0 +1,168 @@
+package httpcache
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// directive is a single Cache-Control directive, with its value unquoted
+type directive struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+// parseDirectives returns every directive found in the Cache-Control header lines of headers, in
+// order of appearance. Directive names are lowercased, and commas inside quoted values are honored.
+func parseDirectives(headers http.Header) []directive {
+	var directives []directive
+	for _, line := range headers[http.CanonicalHeaderKey("Cache-Control")] {
+		for _, part := range splitDirectives(line) {
+			if d, ok := parseDirective(part); ok {
+				directives = append(directives, d)
+			}
+		}
+	}
+	return directives
+}
+
+// parseDirective parses a single directive of a Cache-Control header line. It returns false if part
+// is empty.
+func parseDirective(part string) (directive, bool) {
+	part = strings.TrimSpace(part)
+	if part == "" {
+		return directive{}, false
+	}
+	d := directive{name: part}
+	if i := strings.IndexByte(part, '='); i >= 0 {
+		d.name = strings.TrimSpace(part[:i])
+		d.value = strings.TrimSpace(part[i+1:])
+		d.hasValue = true
+		if len(d.value) >= 2 && d.value[0] == '"' && d.value[len(d.value)-1] == '"' {
+			d.value = strings.Replace(d.value[1:len(d.value)-1], `\"`, `"`, -1)
+		}
+	}
+	d.name = strings.ToLower(d.name)
+	return d, true
+}
+
+// splitDirectives splits a Cache-Control header line on the commas that aren't part of a quoted string
+func splitDirectives(line string) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			parts = append(parts, line[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, line[start:])
+}
+
+// pragmaNoCache returns true if headers carry a Pragma: no-cache header, as sent by HTTP/1.0
+// clients, and no Cache-Control header overriding it
+func pragmaNoCache(headers http.Header) bool {
+	if len(headers[http.CanonicalHeaderKey("Cache-Control")]) > 0 {
+		return false
+	}
+	for _, value := range headerAllCommaSepValues(headers, "pragma") {
+		if strings.EqualFold(value, "no-cache") {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldNames returns the canonical header names listed in the value of a qualified no-cache or
+// private response directive, such as no-cache="Set-Cookie, Set-Cookie2"
+func fieldNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	return names
+}
+
+// parseDeltaSeconds parses a delta-seconds directive value. Values too large to be represented
+// are capped, as allowed by RFC 9111 section 1.2.2.
+func parseDeltaSeconds(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	secs, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || secs > int64(math.MaxInt64/time.Second) {
+		return time.Duration(math.MaxInt64), true
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// requestDirectives holds the request Cache-Control directives that bound the age of the
+// responses a client is willing to accept
+type requestDirectives struct {
+	// maxAge, if hasMaxAge is set, is the maximum age of an acceptable response
+	maxAge    time.Duration
+	hasMaxAge bool
+	// maxStale, if hasMaxStale is set, is how long past its expiration a response is still
+	// acceptable. anyStale is set when max-stale had no value.
+	maxStale    time.Duration
+	hasMaxStale bool
+	anyStale    bool
+	// minFresh is how long an acceptable response must still be fresh for
+	minFresh time.Duration
+}
+
+// parseRequestDirectives parses the freshness related directives of a request. Repeated directives
+// resolve to their most restrictive value: the lowest max-age and max-stale, and the highest min-fresh.
+// An invalid max-age is treated as max-age=0, while invalid or missing min-fresh and max-stale values
+// are ignored (except for a bare max-stale, which accepts any staleness).
+func parseRequestDirectives(headers http.Header) requestDirectives {
+	var rd requestDirectives
+	for _, d := range parseDirectives(headers) {
+		switch d.name {
+		case "max-age":
+			maxAge, ok := parseDeltaSeconds(d.value)
+			if !ok {
+				maxAge = 0
+			}
+			if !rd.hasMaxAge || maxAge < rd.maxAge {
+				rd.maxAge, rd.hasMaxAge = maxAge, true
+			}
+		case "max-stale":
+			if !d.hasValue {
+				if !rd.hasMaxStale {
+					rd.hasMaxStale, rd.anyStale = true, true
+				}
+				continue
+			}
+			maxStale, ok := parseDeltaSeconds(d.value)
+			if !ok {
+				continue
+			}
+			if !rd.hasMaxStale || rd.anyStale || maxStale < rd.maxStale {
+				rd.maxStale, rd.hasMaxStale, rd.anyStale = maxStale, true, false
+			}
+		case "min-fresh":
+			if minFresh, ok := parseDeltaSeconds(d.value); ok && minFresh > rd.minFresh {
+				rd.minFresh = minFresh
+			}
+		}
+	}
+	return rd
+}
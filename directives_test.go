@@ -0,0 +1,111 @@
+package httpcache
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDirectives(t *testing.T) {
+	h := http.Header{}
+	h.Add("Cache-Control", `Max-Age=60, no-cache="Set-Cookie, X-Token", private`)
+	h.Add("Cache-Control", "max-age=10")
+
+	got := parseDirectives(h)
+	want := []directive{
+		{name: "max-age", value: "60", hasValue: true},
+		{name: "no-cache", value: "Set-Cookie, X-Token", hasValue: true},
+		{name: "private"},
+		{name: "max-age", value: "10", hasValue: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if cc := parseCacheControl(h); cc["max-age"] != "60" {
+		t.Fatalf("got max-age %q, want the first occurrence", cc["max-age"])
+	}
+}
+
+func TestParseRequestDirectives(t *testing.T) {
+	tests := []struct {
+		header string
+		want   requestDirectives
+	}{
+		{"min-fresh", requestDirectives{}},
+		{"min-fresh=abc", requestDirectives{}},
+		{"min-fresh=5, min-fresh=10", requestDirectives{minFresh: 10 * time.Second}},
+		{"max-age=20, max-age=10", requestDirectives{maxAge: 10 * time.Second, hasMaxAge: true}},
+		{"max-age=-1", requestDirectives{maxAge: 0, hasMaxAge: true}},
+		{"max-stale", requestDirectives{hasMaxStale: true, anyStale: true}},
+		{"max-stale, max-stale=30", requestDirectives{maxStale: 30 * time.Second, hasMaxStale: true}},
+		{"max-stale=30, max-stale=5", requestDirectives{maxStale: 5 * time.Second, hasMaxStale: true}},
+		{"max-stale=x", requestDirectives{}},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set("Cache-Control", tt.header)
+		if got := parseRequestDirectives(h); got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMaxAgeBoundsMaxStale(t *testing.T) {
+	resetTest()
+	respHeaders := http.Header{}
+	respHeaders.Set("date", time.Now().Format(time.RFC1123))
+	respHeaders.Set("cache-control", "max-age=10")
+
+	reqHeaders := http.Header{}
+	reqHeaders.Set("cache-control", "max-age=20, max-stale")
+	cc := CachedClient{Options: CacheOptions{Debug: true}}
+	req := &http.Request{Header: reqHeaders}
+
+	clock = &fakeClock{elapsed: 15 * time.Second}
+	if cc.getFreshness(req, respHeaders) != fresh {
+		t.Fatal("freshness isn't fresh")
+	}
+	clock = &fakeClock{elapsed: 25 * time.Second}
+	if cc.getFreshness(req, respHeaders) != stale {
+		t.Fatal("freshness isn't stale past the request max-age")
+	}
+}
+
+func TestMinFreshAndMaxStale(t *testing.T) {
+	resetTest()
+	respHeaders := http.Header{}
+	respHeaders.Set("date", time.Now().Format(time.RFC1123))
+	respHeaders.Set("cache-control", "max-age=10")
+
+	reqHeaders := http.Header{}
+	reqHeaders.Set("cache-control", "min-fresh=5, max-stale=10, max-stale=3")
+	cc := CachedClient{Options: CacheOptions{Debug: true}}
+	req := &http.Request{Header: reqHeaders}
+
+	clock = &fakeClock{elapsed: 7 * time.Second}
+	if cc.getFreshness(req, respHeaders) != fresh {
+		t.Fatal("freshness isn't fresh")
+	}
+	clock = &fakeClock{elapsed: 9 * time.Second}
+	if cc.getFreshness(req, respHeaders) != stale {
+		t.Fatal("freshness isn't stale")
+	}
+}
+
+func TestRequestMaxAgeDoesNotExtendLifetime(t *testing.T) {
+	resetTest()
+	respHeaders := http.Header{}
+	respHeaders.Set("date", time.Now().Format(time.RFC1123))
+	respHeaders.Set("cache-control", "max-age=5")
+
+	reqHeaders := http.Header{}
+	reqHeaders.Set("cache-control", "max-age=60")
+	cc := CachedClient{Options: CacheOptions{Debug: true}}
+	req := &http.Request{Header: reqHeaders}
+
+	clock = &fakeClock{elapsed: 10 * time.Second}
+	if cc.getFreshness(req, respHeaders) != stale {
+		t.Fatal("freshness isn't stale")
+	}
+}
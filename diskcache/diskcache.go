@@ -0,0 +1,240 @@
+// Package diskcache provides an implementation of httpcache.Cache that stores responses on the
+// filesystem, so that they survive process restarts.
+package diskcache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+)
+
+// tempPrefix is the prefix of the files being written, which are ignored until renamed into place
+const tempPrefix = ".tmp-"
+
+// now returns the current time, and is replaced in tests
+var now = time.Now
+
+// Cache is an implementation of httpcache.Cache that stores every entry in its own file below a
+// root directory. Files are sharded into two levels of subdirectories named after the hash of their
+// key, so no directory grows too large.
+//
+// Each file starts with a header line holding the entry expiration time and its key, followed by the
+// stored response. Writes go to a temporary file that is renamed into place once complete, so readers
+// never see partial entries.
+//
+// Cache also implements httpcache.StreamingCache and httpcache.KeyLister, and its writers implement
+// httpcache.AbortWriter.
+type Cache struct {
+	root   string
+	hasher httpcache.Hasher
+}
+
+// New returns a new Cache that will store files below root, creating it if needed
+func New(root string) *Cache {
+	return NewWithHasher(root, httpcache.SHA256)
+}
+
+// NewWithHasher returns a new Cache that will store files below root, naming them with hasher.
+// Files stored with a different hasher aren't found.
+func NewWithHasher(root string, hasher httpcache.Hasher) *Cache {
+	return &Cache{root: root, hasher: hasher}
+}
+
+// Root returns the directory the cache stores its files in
+func (c *Cache) Root() string {
+	return c.root
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *Cache) Get(key string) (resp []byte, ok bool) {
+	r, ok := c.GetReader(key)
+	if !ok {
+		return nil, false
+	}
+	defer r.Close()
+	resp, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, false
+	}
+	return resp, true
+}
+
+// Set saves response resp to the cache with key, expiring after ttl seconds if positive
+func (c *Cache) Set(key string, resp []byte, ttl int) {
+	w, err := c.SetWriter(key, ttl)
+	if err != nil {
+		return
+	}
+	// A failed write makes Close discard the entry
+	w.Write(resp)
+	w.Close()
+}
+
+// Delete removes key from the cache
+func (c *Cache) Delete(key string) {
+	os.Remove(c.path(key))
+}
+
+// GetReader returns a reader over the response stored with key and true if present, false if not
+func (c *Cache) GetReader(key string) (io.ReadCloser, bool) {
+	f, err := os.Open(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	br := bufio.NewReader(f)
+	expires, storedKey, err := readHeader(br)
+	if err != nil || storedKey != key {
+		f.Close()
+		return nil, false
+	}
+	if !expires.IsZero() && !now().Before(expires) {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, false
+	}
+	return &fileReader{Reader: br, f: f}, true
+}
+
+// SetWriter returns a writer storing a response with key, which becomes visible once the writer is closed
+func (c *Cache) SetWriter(key string, ttl int) (io.WriteCloser, error) {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), tempPrefix)
+	if err != nil {
+		return nil, err
+	}
+	w := &fileWriter{f: f, path: path}
+	if err := writeHeader(f, key, ttl); err != nil {
+		w.Abort()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Keys returns the keys of all the unexpired entries in the cache
+func (c *Cache) Keys() []string {
+	var keys []string
+	c.walk(func(path, key string, expires time.Time) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// walk calls fn with the file path, key and expiration of every unexpired entry in the cache
+func (c *Cache) walk(fn func(path, key string, expires time.Time)) {
+	t := now()
+	filepath.Walk(c.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || strings.HasPrefix(info.Name(), tempPrefix) {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		expires, key, err := readHeader(bufio.NewReader(f))
+		f.Close()
+		if err == nil && (expires.IsZero() || t.Before(expires)) {
+			fn(path, key, expires)
+		}
+		return nil
+	})
+}
+
+// path returns the file path of key: its hash, sharded by the first two bytes of it. Hashes too short
+// to be sharded are stored at the root.
+func (c *Cache) path(key string) string {
+	name := httpcache.HashString(c.hasher, key)
+	if len(name) < 4 {
+		return filepath.Join(c.root, name)
+	}
+	return filepath.Join(c.root, name[0:2], name[2:4], name)
+}
+
+// writeHeader writes the header line of an entry: its expiration as a unix timestamp (0 if it
+// doesn't expire) and its key
+func writeHeader(w io.Writer, key string, ttl int) error {
+	var expires int64
+	if ttl > 0 {
+		expires = now().Add(time.Duration(ttl) * time.Second).Unix()
+	}
+	_, err := fmt.Fprintf(w, "%d %s\n", expires, strconv.Quote(key))
+	return err
+}
+
+func readHeader(r *bufio.Reader) (expires time.Time, key string, err error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	parts := strings.SplitN(strings.TrimSuffix(line, "\n"), " ", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", fmt.Errorf("diskcache: malformed entry header %q", line)
+	}
+	secs, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	if key, err = strconv.Unquote(parts[1]); err != nil {
+		return time.Time{}, "", err
+	}
+	if secs > 0 {
+		expires = time.Unix(secs, 0)
+	}
+	return expires, key, nil
+}
+
+type fileReader struct {
+	io.Reader
+	f *os.File
+}
+
+func (r *fileReader) Close() error {
+	return r.f.Close()
+}
+
+// fileWriter writes an entry into a temporary file, renaming it into place on Close. If any write
+// failed, Close discards the temporary file instead.
+type fileWriter struct {
+	f    *os.File
+	path string
+	err  error
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.f.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *fileWriter) Close() error {
+	if w.err != nil {
+		w.Abort()
+		return w.err
+	}
+	if err := w.f.Close(); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	return os.Rename(w.f.Name(), w.path)
+}
+
+// Abort discards the temporary file, leaving any entry previously stored with the key in place
+func (w *fileWriter) Abort() error {
+	w.f.Close()
+	return os.Remove(w.f.Name())
+}
@@ -0,0 +1,210 @@
+package diskcache
+
+import (
+	"hash"
+	"hash/fnv"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	test.Cache(t, New(dir))
+}
+
+func TestPersistence(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	New(dir).Set("http://example.com/a b", []byte("value"), 0)
+	val, ok := New(dir).Get("http://example.com/a b")
+	if !ok || string(val) != "value" {
+		t.Fatalf("got %q, %v, want value, true", val, ok)
+	}
+
+	files := 0
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files++
+			if rel, _ := filepath.Rel(dir, path); strings.Count(rel, string(filepath.Separator)) != 2 {
+				t.Fatalf("entry stored at %q, want two shard levels", rel)
+			}
+		}
+		return nil
+	})
+	if files != 1 {
+		t.Fatalf("got %d files, want 1", files)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	defer func() { now = time.Now }()
+
+	c := New(dir)
+	c.Set("expiring", []byte("1"), 10)
+	c.Set("permanent", []byte("2"), 0)
+	if _, ok := c.Get("expiring"); !ok {
+		t.Fatal("entry expired before its TTL")
+	}
+
+	now = func() time.Time { return time.Now().Add(time.Minute) }
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "permanent" {
+		t.Fatalf("got keys %v, want [permanent]", keys)
+	}
+	if _, ok := c.Get("expiring"); ok {
+		t.Fatal("entry didn't expire after its TTL")
+	}
+	if _, err := os.Stat(c.path("expiring")); !os.IsNotExist(err) {
+		t.Fatal("expired entry wasn't removed from disk")
+	}
+}
+
+func TestAbortedWrite(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := New(dir)
+	w, err := c.SetWriter("key", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("partial"))
+	if _, ok := c.Get("key"); ok {
+		t.Fatal("entry visible before its writer was closed")
+	}
+	if keys := c.Keys(); len(keys) != 0 {
+		t.Fatalf("got keys %v for an unfinished write, want none", keys)
+	}
+	w.Close()
+	if val, ok := c.Get("key"); !ok || string(val) != "partial" {
+		t.Fatalf("got %q, %v, want partial, true", val, ok)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := New(dir)
+	c.Set("key", []byte("previous"), 0)
+	w, err := c.SetWriter("key", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("partial"))
+	if err := w.(httpcache.AbortWriter).Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if val, ok := c.Get("key"); !ok || string(val) != "previous" {
+		t.Fatalf("got %q, %v, want previous, true", val, ok)
+	}
+	files, err := ioutil.ReadDir(filepath.Dir(c.path("key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want the aborted temporary file removed", len(files))
+	}
+}
+
+func TestCachedClient(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	for i, want := range []string{"", "1", "1"} {
+		// A new client and cache every time, as a restarted process would have
+		client := httpcache.NewCachedClient(&http.Client{Transport: &http.Transport{}}, New(dir), httpcache.CacheOptions{MarkCachedResponses: true})
+		req, err := http.NewRequest("GET", server.URL+"/page", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "body" {
+			t.Fatalf("request %d: got body %q, want body", i, body)
+		}
+		if got := resp.Header.Get(httpcache.XFromCache); got != want {
+			t.Fatalf("request %d: got XFromCache %q, want %q", i, got, want)
+		}
+	}
+
+	keys := New(dir).Keys()
+	sort.Strings(keys)
+	if len(keys) != 1 || keys[0] != server.URL+"/page" {
+		t.Fatalf("got keys %v, want [%s/page]", keys, server.URL)
+	}
+}
+
+func TestHasher(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	fnv64 := httpcache.HasherFunc(func() hash.Hash { return fnv.New64a() })
+	c := NewWithHasher(dir, fnv64)
+	test.Cache(t, c)
+	c.Set("key", []byte("value"), 0)
+	if _, err := os.Stat(c.path("key")); err != nil {
+		t.Fatal(err)
+	}
+	if name := filepath.Base(c.path("key")); name != httpcache.HashString(fnv64, "key") {
+		t.Fatalf("got file name %q, want the digest of the key", name)
+	}
+	if _, ok := New(dir).Get("key"); ok {
+		t.Fatal("entry found with a different hasher")
+	}
+}
+
+// shortHash is an 8-bit hash, whose hex digest is too short to be sharded
+type shortHash struct {
+	hash.Hash
+}
+
+func (h shortHash) Sum(b []byte) []byte {
+	return h.Hash.Sum(b)[:len(b)+1]
+}
+
+func (h shortHash) Size() int {
+	return 1
+}
+
+func TestShortHasher(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	c := NewWithHasher(dir, httpcache.HasherFunc(func() hash.Hash { return shortHash{fnv.New32a()} }))
+	test.Cache(t, c)
+	c.Set("key", []byte("value"), 0)
+	if val, ok := c.Get("key"); !ok || string(val) != "value" {
+		t.Fatalf("got %q, %v, want value, true", val, ok)
+	}
+}
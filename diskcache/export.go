@@ -0,0 +1,152 @@
+package diskcache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+)
+
+// ManifestName is the name of the manifest file written by Export
+const ManifestName = "manifest.json"
+
+// Manifest describes the entries written by Export
+type Manifest struct {
+	Version  int             `json:"version"`
+	Exported time.Time       `json:"exported"`
+	Entries  []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry describes a single exported entry. Its files are found in the Dir subdirectory of
+// the export directory.
+type ManifestEntry struct {
+	Key           string     `json:"key"`
+	URL           string     `json:"url"`
+	Status        int        `json:"status"`
+	ContentType   string     `json:"content_type,omitempty"`
+	ContentLength int64      `json:"content_length"`
+	Expires       *time.Time `json:"expires,omitempty"`
+	Dir           string     `json:"dir"`
+}
+
+// Export writes a copy of every unexpired entry of the cache below dir, in a layout meant to be
+// consumed by other programs and people rather than by Cache. Each entry gets its own subdirectory
+// holding three files:
+//
+//   - url: the URL of the request the response was stored for
+//   - headers: the status line and header fields of the response, in HTTP/1.1 wire format
+//   - body: the response body, with its transfer coding removed but any content coding kept
+//
+// Entries that aren't HTTP responses, such as the artifacts derived from them or unreadable ones,
+// are skipped, and the internal headers of the client are left out. A manifest.json file listing
+// the entries (see Manifest) is written last, so its presence tells that the export is complete.
+// Export returns the number of entries exported.
+func (c *Cache) Export(dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return 0, err
+	}
+	manifest := Manifest{Version: 1, Exported: now().UTC(), Entries: []ManifestEntry{}}
+	var firstErr error
+	c.walk(func(path, key string, expires time.Time) {
+		if httpcache.IsInternalKey(key) {
+			return
+		}
+		entry, ok, err := exportEntry(path, key, dir)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		if !ok {
+			return
+		}
+		if !expires.IsZero() {
+			expires = expires.UTC()
+			entry.Expires = &expires
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	})
+	if firstErr != nil {
+		return 0, firstErr
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestName), b, 0600); err != nil {
+		return 0, err
+	}
+	return len(manifest.Entries), nil
+}
+
+// exportEntry writes the entry stored at path with key into its own subdirectory of dir. It returns
+// false if the entry isn't an HTTP response, and was skipped.
+func exportEntry(path, key, dir string) (ManifestEntry, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	if _, _, err := readHeader(br); err != nil {
+		return ManifestEntry{}, false, nil
+	}
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return ManifestEntry{}, false, nil
+	}
+	defer resp.Body.Close()
+
+	entry := ManifestEntry{
+		Key:         key,
+		URL:         httpcache.KeyURL(key),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Dir:         filepath.Base(path),
+	}
+	entryDir := filepath.Join(dir, entry.Dir)
+	if err := os.MkdirAll(entryDir, 0700); err != nil {
+		return ManifestEntry{}, false, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(entryDir, "url"), []byte(entry.URL+"\n"), 0600); err != nil {
+		return ManifestEntry{}, false, err
+	}
+
+	body, err := os.Create(filepath.Join(entryDir, "body"))
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	entry.ContentLength, err = io.Copy(body, resp.Body)
+	if cerr := body.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+
+	headers, err := os.Create(filepath.Join(entryDir, "headers"))
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	httpcache.StripInternalHeaders(resp.Header)
+	// The body is stored decoded, so it is described by its actual length
+	resp.Header.Del("Transfer-Encoding")
+	resp.Header.Set("Content-Length", fmt.Sprint(entry.ContentLength))
+	_, err = fmt.Fprintf(headers, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
+	if err == nil {
+		err = resp.Header.Write(headers)
+	}
+	if cerr := headers.Close(); err == nil {
+		err = cerr
+	}
+	return entry, err == nil, err
+}
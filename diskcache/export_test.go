@@ -0,0 +1,85 @@
+package diskcache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lggomez/httpcache/v2"
+)
+
+func TestExport(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	c := New(filepath.Join(dir, "cache"))
+	client := httpcache.NewCachedClient(&http.Client{Transport: &http.Transport{}}, c, httpcache.CacheOptions{TTL: 60})
+	for _, path := range []string{"/a", "/b"} {
+		resp, err := client.Do(mustRequest(t, server.URL+path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	// Artifacts derived from the responses, and entries that aren't responses, aren't exported
+	c.Set(server.URL+"/a derived:index", []byte("HTTP/1.1 200 OK\r\n\r\n"), 60)
+	c.Set(server.URL+"/a derived", []byte("index"), 60)
+	c.Set("other", []byte("not a response"), 60)
+
+	exportDir := filepath.Join(dir, "export")
+	n, err := c.Export(exportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("got %d entries exported, want 2", n)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(exportDir, ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range manifest.Entries {
+		path := strings.TrimPrefix(entry.URL, server.URL)
+		if entry.Status != http.StatusOK || entry.ContentType != "text/plain" || entry.Expires == nil {
+			t.Fatalf("got manifest entry %+v, want a 200 text/plain response expiring", entry)
+		}
+		url, _ := ioutil.ReadFile(filepath.Join(exportDir, entry.Dir, "url"))
+		if string(url) != entry.URL+"\n" {
+			t.Fatalf("got url file %q, want %q", url, entry.URL)
+		}
+		body, _ := ioutil.ReadFile(filepath.Join(exportDir, entry.Dir, "body"))
+		if string(body) != "hello "+path || entry.ContentLength != int64(len(body)) {
+			t.Fatalf("got body %q of length %d, want the response to %v", body, entry.ContentLength, path)
+		}
+		headers, _ := ioutil.ReadFile(filepath.Join(exportDir, entry.Dir, "headers"))
+		if !strings.HasPrefix(string(headers), "HTTP/1.1 200 OK\r\n") || !strings.Contains(string(headers), "Cache-Control: max-age=3600\r\n") ||
+			strings.Contains(string(headers), "X-Httpcache-") {
+			t.Fatalf("got headers file %q, want the response status line and headers", headers)
+		}
+	}
+}
+
+func mustRequest(t *testing.T, url string) *http.Request {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
@@ -0,0 +1,117 @@
+// Package dynamodbcache provides an implementation of httpcache.CacheV2 that stores responses in a
+// DynamoDB table, for serverless deployments without local disk or a cache server.
+//
+// The table must have a string partition key, named "key" by default. Enabling DynamoDB TTL on the
+// expiration attribute ("expires" by default) lets DynamoDB delete expired entries on its own.
+//
+// It is a separate module so that the main one stays free of dependencies.
+package dynamodbcache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lggomez/httpcache/v2"
+)
+
+// now returns the current time, and is replaced in tests
+var now = time.Now
+
+// API is the subset of the DynamoDB client used by Cache. It is implemented by *dynamodb.Client.
+type API interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// Cache is an implementation of httpcache.CacheV2 backed by a DynamoDB table. Use
+// httpcache.AdaptCacheV2 to use it where a httpcache.Cache is expected.
+//
+// Since DynamoDB deletes expired items lazily, expiration is also checked on every read. Items are
+// limited to 400KB by DynamoDB, so storing larger responses fails.
+type Cache struct {
+	client API
+	table  string
+
+	// KeyAttribute is the name of the partition key attribute holding the cache key
+	KeyAttribute string
+	// ValueAttribute is the name of the binary attribute holding the stored response
+	ValueAttribute string
+	// TTLAttribute is the name of the number attribute holding the expiration time, in unix
+	// seconds. It is only set on entries with a TTL.
+	TTLAttribute string
+	// If true, reads are strongly consistent
+	ConsistentRead bool
+}
+
+// New returns a new Cache storing entries in table through client, with the default attribute names
+func New(client API, table string) *Cache {
+	return &Cache{
+		client:         client,
+		table:          table,
+		KeyAttribute:   "key",
+		ValueAttribute: "value",
+		TTLAttribute:   "expires",
+	}
+}
+
+// Get returns the []byte representation of the response stored with key, or httpcache.ErrCacheMiss
+// if there is none
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := c.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(c.table),
+		Key:            c.key(key),
+		ConsistentRead: aws.Bool(c.ConsistentRead),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, httpcache.ErrCacheMiss
+	}
+	if expires, ok := out.Item[c.TTLAttribute].(*types.AttributeValueMemberN); ok {
+		secs, err := strconv.ParseInt(expires.Value, 10, 64)
+		if err == nil && !now().Before(time.Unix(secs, 0)) {
+			return nil, httpcache.ErrCacheMiss
+		}
+	}
+	value, ok := out.Item[c.ValueAttribute].(*types.AttributeValueMemberB)
+	if !ok {
+		return nil, httpcache.ErrCacheMiss
+	}
+	return value.Value, nil
+}
+
+// Set stores response resp with key, expiring after ttl seconds if positive
+func (c *Cache) Set(ctx context.Context, key string, resp []byte, ttl int) error {
+	item := c.key(key)
+	item[c.ValueAttribute] = &types.AttributeValueMemberB{Value: resp}
+	if ttl > 0 {
+		expires := now().Add(time.Duration(ttl) * time.Second).Unix()
+		item[c.TTLAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expires, 10)}
+	}
+	_, err := c.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.table),
+		Item:      item,
+	})
+	return err
+}
+
+// Delete removes key from the table
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	_, err := c.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(c.table),
+		Key:       c.key(key),
+	})
+	return err
+}
+
+func (c *Cache) key(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		c.KeyAttribute: &types.AttributeValueMemberS{Value: key},
+	}
+}
@@ -0,0 +1,82 @@
+package dynamodbcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/lggomez/httpcache/v2"
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+var _ API = (*dynamodb.Client)(nil)
+
+// fakeTable is an in-memory API implementation keyed by the "key" attribute
+type fakeTable struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func newFakeTable() *fakeTable {
+	return &fakeTable{items: map[string]map[string]types.AttributeValue{}}
+}
+
+func keyOf(key map[string]types.AttributeValue) string {
+	return key["key"].(*types.AttributeValueMemberS).Value
+}
+
+func (f *fakeTable) GetItem(ctx context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: f.items[keyOf(in.Key)]}, nil
+}
+
+func (f *fakeTable) PutItem(ctx context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items[keyOf(in.Item)] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeTable) DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.items, keyOf(in.Key))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestDynamoDBCache(t *testing.T) {
+	test.Cache(t, httpcache.AdaptCacheV2(New(newFakeTable(), "cache")))
+}
+
+func TestExpiry(t *testing.T) {
+	defer func() { now = time.Now }()
+	ctx := context.Background()
+	table := newFakeTable()
+	c := New(table, "cache")
+
+	if err := c.Set(ctx, "expiring", []byte("1"), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "permanent", []byte("2"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := table.items["permanent"]["expires"]; ok {
+		t.Fatal("expiration attribute set on an entry without TTL")
+	}
+	if _, err := c.Get(ctx, "expiring"); err != nil {
+		t.Fatalf("got error %v before the entry TTL", err)
+	}
+
+	// DynamoDB may keep expired items for a while
+	now = func() time.Time { return time.Now().Add(time.Minute) }
+	if _, err := c.Get(ctx, "expiring"); err != httpcache.ErrCacheMiss {
+		t.Fatalf("got error %v after the entry TTL, want ErrCacheMiss", err)
+	}
+	if val, err := c.Get(ctx, "permanent"); err != nil || string(val) != "2" {
+		t.Fatalf("got %q, %v, want 2, nil", val, err)
+	}
+}
@@ -0,0 +1,73 @@
+package httpcache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+)
+
+// EncryptedCache is a Cache wrapper encrypting entries with AES-GCM before storing them in the
+// wrapped cache, so that responses containing personal data can be kept on shared disks or remote
+// stores. Every entry is bound to its key, so entries can't be swapped in the wrapped cache. Entries
+// that can't be decrypted are reported as missing.
+//
+// Keys themselves are stored as they are; wrap the inner cache with a key hashing cache if the
+// request URLs are sensitive too.
+type EncryptedCache struct {
+	inner Cache
+	aead  cipher.AEAD
+}
+
+// NewEncryptedCache returns a new EncryptedCache storing entries in inner, encrypted with key, which
+// must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256
+func NewEncryptedCache(inner Cache, key []byte) (*EncryptedCache, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedCache{inner: inner, aead: aead}, nil
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *EncryptedCache) Get(key string) (resp []byte, ok bool) {
+	b, ok := c.inner.Get(key)
+	if !ok {
+		return nil, false
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(b) < nonceSize {
+		return nil, false
+	}
+	resp, err := c.aead.Open(nil, b[:nonceSize], b[nonceSize:], []byte(key))
+	if err != nil {
+		return nil, false
+	}
+	return resp, true
+}
+
+// Set saves response resp encrypted to the cache with key
+func (c *EncryptedCache) Set(key string, resp []byte, ttl int) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(resp)+c.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+	c.inner.Set(key, c.aead.Seal(nonce, nonce, resp, []byte(key)), ttl)
+}
+
+// Delete removes key from the cache
+func (c *EncryptedCache) Delete(key string) {
+	c.inner.Delete(key)
+}
+
+// Keys returns the keys of the wrapped cache, if it implements KeyLister
+func (c *EncryptedCache) Keys() []string {
+	if kl, ok := c.inner.(KeyLister); ok {
+		return kl.Keys()
+	}
+	return nil
+}
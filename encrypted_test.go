@@ -0,0 +1,39 @@
+package httpcache
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptedCache(t *testing.T) {
+	if _, err := NewEncryptedCache(NewMemoryCache(), []byte("short")); err == nil {
+		t.Fatal("got no error for an invalid key size")
+	}
+
+	inner := NewMemoryCache()
+	key := bytes.Repeat([]byte{1}, 32)
+	c, err := NewEncryptedCache(inner, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := []byte(`HTTP/1.1 200 OK\r\n\r\n{"email":"someone@example.com"}`)
+
+	c.Set("a", entry, 0)
+	stored, _ := inner.Get("a")
+	if bytes.Contains(stored, []byte("someone@example.com")) {
+		t.Fatal("entry stored in clear text")
+	}
+	if got, ok := c.Get("a"); !ok || !bytes.Equal(got, entry) {
+		t.Fatalf("got %q (found: %v), want the original entry", got, ok)
+	}
+
+	// An entry moved to another key doesn't authenticate
+	inner.Set("b", stored, 0)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("got an entry stored under another key")
+	}
+	other, _ := NewEncryptedCache(inner, bytes.Repeat([]byte{2}, 32))
+	if _, ok := other.Get("a"); ok {
+		t.Fatal("got an entry decrypted with another key")
+	}
+}
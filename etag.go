@@ -0,0 +1,62 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+)
+
+// parseETags returns the entity-tags listed in an If-None-Match header value, as is. A value of *
+// is returned as a single "*" element.
+func parseETags(value string) []string {
+	var etags []string
+	for _, etag := range splitDirectives(value) {
+		if etag = strings.TrimSpace(etag); etag != "" {
+			etags = append(etags, etag)
+		}
+	}
+	return etags
+}
+
+// weakMatch returns true if the entity-tags a and b match using the weak comparison of RFC 9110
+// section 8.8.3.2: their opaque tags are equal, whether or not either is weak
+func weakMatch(a, b string) bool {
+	a, b = strings.TrimPrefix(a, "W/"), strings.TrimPrefix(b, "W/")
+	return a != "" && a == b
+}
+
+// etagListMatches returns true if etag weakly matches one of the entity-tags in etags, or if the
+// list is *
+func etagListMatches(etags []string, etag string) bool {
+	for _, e := range etags {
+		if e == "*" || weakMatch(e, etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeIfNoneMatch returns the If-None-Match header value to revalidate an entry with the ETag
+// etag, given the value sent by the client: etag is added to the entity-tags the client already
+// holds, unless one of them matches it
+func mergeIfNoneMatch(ifNoneMatch, etag string) string {
+	etags := parseETags(ifNoneMatch)
+	if len(etags) == 0 {
+		return etag
+	}
+	if etagListMatches(etags, etag) {
+		return ifNoneMatch
+	}
+	return strings.Join(append(etags, etag), ", ")
+}
+
+// notModifiedApplies returns true if resp, a 304 response to a revalidation of cachedResp,
+// validates the entry rather than only one of the representations listed by the client in its own
+// If-None-Match header. A 304 without ETag is assumed to apply to the entry.
+func notModifiedApplies(cachedResp, resp *http.Response) bool {
+	etag := resp.Header.Get("ETag")
+	cachedETag := cachedResp.Header.Get("ETag")
+	if etag == "" || cachedETag == "" || cachedResp.Header.Get(syntheticETagHeader) != "" {
+		return true
+	}
+	return weakMatch(etag, cachedETag)
+}
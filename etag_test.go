@@ -0,0 +1,96 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMergeIfNoneMatch(t *testing.T) {
+	for _, c := range []struct {
+		ifNoneMatch, etag, want string
+	}{
+		{"", `"v1"`, `"v1"`},
+		{`"a"`, `"v1"`, `"a", "v1"`},
+		{`"a", W/"b"`, `W/"v1"`, `"a", W/"b", W/"v1"`},
+		{`"a", W/"v1"`, `"v1"`, `"a", W/"v1"`},
+		{`"a,b"`, `"v1"`, `"a,b", "v1"`},
+		{`*`, `"v1"`, `*`},
+	} {
+		if got := mergeIfNoneMatch(c.ifNoneMatch, c.etag); got != c.want {
+			t.Errorf("mergeIfNoneMatch(%q, %q) = %q, want %q", c.ifNoneMatch, c.etag, got, c.want)
+		}
+	}
+}
+
+func TestWeakMatch(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{`"v1"`, `"v1"`, true},
+		{`W/"v1"`, `"v1"`, true},
+		{`W/"v1"`, `W/"v1"`, true},
+		{`"v1"`, `"v2"`, false},
+		{`W/"v1"`, `W/"v2"`, false},
+		{``, ``, false},
+	} {
+		if got := weakMatch(c.a, c.b); got != c.want {
+			t.Errorf("weakMatch(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestConditionalRevalidation(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	current := `W/"v1"`
+	var ifNoneMatch string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Header().Set("Etag", current)
+		if etagListMatches(parseETags(ifNoneMatch), current) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body " + current))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	do := func(ifNoneMatch string) (int, string) {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, string(body)
+	}
+
+	do("")
+	clock = &fakeClock{elapsed: 20 * time.Second}
+	// The origin confirms the entry: the client gets the cached response
+	if status, body := do(`"v0"`); status != http.StatusOK || body != `body W/"v1"` {
+		t.Fatalf("got %d %q, want the cached response", status, body)
+	}
+	if ifNoneMatch != `"v0", W/"v1"` {
+		t.Fatalf("got If-None-Match %q, want the cached ETag merged in", ifNoneMatch)
+	}
+
+	// The origin confirms the client's own representation only: the 304 is passed on
+	current = `"v0"`
+	clock = &fakeClock{elapsed: 40 * time.Second}
+	if status, _ := do(`"v0"`); status != http.StatusNotModified {
+		t.Fatalf("got status %d, want the 304 passed on", status)
+	}
+	if status, body := do(""); status != http.StatusOK || body != `body "v0"` {
+		t.Fatalf("got %d %q, want the new representation", status, body)
+	}
+}
@@ -0,0 +1,84 @@
+// Package etcdcache provides an implementation of httpcache.CacheV2 that stores responses in etcd,
+// for control-plane clients that already run it and want a consistent cache shared between replicas.
+//
+// Entries with a TTL are attached to an etcd lease, so that etcd removes them once expired.
+//
+// It is a separate module so that the main one stays free of dependencies.
+package etcdcache
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lggomez/httpcache/v2"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Cache is an implementation of httpcache.CacheV2 storing entries under a key prefix of etcd. Use
+// httpcache.AdaptCacheV2 to use it where a httpcache.Cache is expected.
+//
+// Every entry with a TTL is granted its own lease. etcd limits values to 1.5MB by default, so storing
+// larger responses fails.
+type Cache struct {
+	kv     clientv3.KV
+	lease  clientv3.Lease
+	prefix string
+}
+
+// New returns a new Cache storing entries through client, under keys starting with prefix
+func New(client *clientv3.Client, prefix string) *Cache {
+	return NewWithKV(client, client, prefix)
+}
+
+// NewWithKV returns a new Cache storing entries through kv, and granting their TTL leases through
+// lease (such as the ones of a namespaced client)
+func NewWithKV(kv clientv3.KV, lease clientv3.Lease, prefix string) *Cache {
+	return &Cache{kv: kv, lease: lease, prefix: prefix}
+}
+
+// Get returns the []byte representation of the response stored with key, or httpcache.ErrCacheMiss
+// if there is none
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.kv.Get(ctx, c.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, httpcache.ErrCacheMiss
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+// Set stores response resp with key, expiring after ttl seconds if positive
+func (c *Cache) Set(ctx context.Context, key string, resp []byte, ttl int) error {
+	var opts []clientv3.OpOption
+	if ttl > 0 {
+		lease, err := c.lease.Grant(ctx, int64(ttl))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, clientv3.WithLease(lease.ID))
+	}
+	_, err := c.kv.Put(ctx, c.prefix+key, string(resp), opts...)
+	return err
+}
+
+// Delete removes key from etcd
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	_, err := c.kv.Delete(ctx, c.prefix+key)
+	return err
+}
+
+// Keys returns the keys of all the entries stored under the prefix. It returns nil if etcd can't
+// be reached.
+func (c *Cache) Keys() []string {
+	resp, err := c.kv.Get(context.Background(), c.prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		keys = append(keys, strings.TrimPrefix(string(kv.Key), c.prefix))
+	}
+	return keys
+}
@@ -0,0 +1,111 @@
+package etcdcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/lggomez/httpcache/v2/test"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// startEtcd runs a single node etcd server and returns a client connected to it, along with a
+// function stopping both
+func startEtcd(t *testing.T) (*clientv3.Client, func()) {
+	dir, err := ioutil.TempDir("", "etcdcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := embed.NewConfig()
+	cfg.Dir = dir
+	cfg.LogLevel = "error"
+	clientURL, _ := url.Parse("http://127.0.0.1:0")
+	peerURL, _ := url.Parse("http://127.0.0.1:0")
+	cfg.LCUrls, cfg.ACUrls = []url.URL{*clientURL}, []url.URL{*clientURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{*peerURL}, []url.URL{*peerURL}
+	cfg.InitialCluster = cfg.Name + "=" + peerURL.String()
+	server, err := embed.StartEtcd(cfg)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	select {
+	case <-server.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		server.Close()
+		os.RemoveAll(dir)
+		t.Fatal("etcd server didn't start")
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{server.Clients[0].Addr().String()},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		server.Close()
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return client, func() {
+		client.Close()
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestEtcdCache(t *testing.T) {
+	client, stop := startEtcd(t)
+	defer stop()
+	ctx := context.Background()
+	c := New(client, "httpcache/")
+
+	test.Cache(t, httpcache.AdaptCacheV2(c))
+
+	c.Set(ctx, "b", []byte("2"), 0)
+	c.Set(ctx, "a", []byte("1"), 0)
+	client.Put(ctx, "other/c", "3")
+	keys := c.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Fatalf("got keys %v, want [a b]", keys)
+	}
+}
+
+func TestLeases(t *testing.T) {
+	client, stop := startEtcd(t)
+	defer stop()
+	ctx := context.Background()
+	c := New(client, "httpcache/")
+
+	if err := c.Set(ctx, "expiring", []byte("1"), 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "permanent", []byte("2"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(ctx, "httpcache/expiring")
+	if err != nil || len(resp.Kvs) != 1 || resp.Kvs[0].Lease == 0 {
+		t.Fatalf("got %v (err: %v), want an entry attached to a lease", resp, err)
+	}
+	ttl, err := client.TimeToLive(ctx, clientv3.LeaseID(resp.Kvs[0].Lease))
+	if err != nil || ttl.TTL <= 0 || ttl.TTL > 60 {
+		t.Fatalf("got lease TTL %v (err: %v), want at most 60 seconds", ttl, err)
+	}
+	if resp, _ := client.Get(ctx, "httpcache/permanent"); len(resp.Kvs) != 1 || resp.Kvs[0].Lease != 0 {
+		t.Fatalf("got %v, want an entry without lease", resp)
+	}
+
+	// Revoking the lease is what etcd does on expiry
+	if _, err := client.Revoke(ctx, clientv3.LeaseID(resp.Kvs[0].Lease)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "expiring"); err != httpcache.ErrCacheMiss {
+		t.Fatalf("got error %v after the lease expiry, want ErrCacheMiss", err)
+	}
+}
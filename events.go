@@ -0,0 +1,178 @@
+package httpcache
+
+import (
+	"time"
+)
+
+// DefaultEventBuffer is the capacity of the Events channel when CacheOptions.EventBuffer isn't set
+const DefaultEventBuffer = 1024
+
+// EventType is the kind of an Event
+type EventType int
+
+const (
+	// EventDecision is emitted once the client decided how to serve a request. See Decision.
+	EventDecision EventType = iota
+	// EventStore is emitted when a response is stored
+	EventStore
+	// EventEvict is emitted when an entry is removed, because it was invalidated or can't be used anymore
+	EventEvict
+	// EventRevalidate is emitted when a stale entry was revalidated with the origin. Its Status is
+	// 304 if the entry was kept.
+	EventRevalidate
+	// EventServeStale is emitted when a stale entry is served without being revalidated first
+	EventServeStale
+	// EventUpstream is emitted when a request was sent to the origin
+	EventUpstream
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventDecision:
+		return "decision"
+	case EventStore:
+		return "store"
+	case EventEvict:
+		return "evict"
+	case EventRevalidate:
+		return "revalidate"
+	case EventServeStale:
+		return "serve-stale"
+	case EventUpstream:
+		return "upstream"
+	}
+	return "unknown"
+}
+
+// Decision is the way a request is served, as reported by EventDecision events
+type Decision int
+
+const (
+	// DecisionMiss means no usable entry was found, and the request is sent to the origin
+	DecisionMiss Decision = iota
+	// DecisionHit means a fresh entry was found, and is served without contacting the origin
+	DecisionHit
+	// DecisionStale means a stale entry was found, and is revalidated with the origin
+	DecisionStale
+	// DecisionBypass means the request isn't served from the cache, because of its method,
+	// its headers or the client options
+	DecisionBypass
+)
+
+func (d Decision) String() string {
+	switch d {
+	case DecisionMiss:
+		return "miss"
+	case DecisionHit:
+		return "hit"
+	case DecisionStale:
+		return "stale"
+	case DecisionBypass:
+		return "bypass"
+	}
+	return "unknown"
+}
+
+// Event describes a step of the handling of a request by the cache
+type Event struct {
+	Type EventType
+	// Time at which the event happened
+	Time time.Time
+	// Key is the cache key of the entry concerned
+	Key string
+	// Decision, for EventDecision events
+	Decision Decision
+	// Size of the stored entry in bytes, for EventStore events. It is -1 for streamed entries.
+	Size int
+	// Status code of the origin response, for EventRevalidate and EventUpstream events. It is 0 if
+	// the request failed.
+	Status int
+	// Latency of the origin for EventUpstream events, and of the cache backend lookup for
+	// EventDecision events
+	Latency time.Duration
+	// Age of the entry found, for EventDecision events deciding on a hit or a stale entry
+	Age time.Duration
+}
+
+// An Observer receives every event of a CachedClient as it happens, usually to feed a metrics
+// system (see the metrics/prometheus package). Observe is called synchronously from the requests,
+// so it must be fast and safe for concurrent use.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc is an adapter to use an ordinary function as an Observer
+type ObserverFunc func(e Event)
+
+// Observe calls f(e)
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// Events returns the channel on which the client publishes its events, for processing by external
+// systems. The channel is created on the first call, and events are only published from then on.
+// It is bounded by CacheOptions.EventBuffer: when it is full, the oldest events are dropped, so a
+// slow consumer never blocks requests.
+func (cc *CachedClient) Events() <-chan Event {
+	owner := cc.owner()
+	owner.mu.Lock()
+	defer owner.mu.Unlock()
+	if ch, ok := owner.events.Load().(chan Event); ok {
+		return ch
+	}
+	size := owner.Options.EventBuffer
+	if size <= 0 {
+		size = DefaultEventBuffer
+	}
+	ch := make(chan Event, size)
+	owner.events.Store(ch)
+	return ch
+}
+
+// observed returns true if the events of the client are consumed
+func (cc *CachedClient) observed() bool {
+	owner := cc.owner()
+	_, ok := owner.events.Load().(chan Event)
+	return ok || owner.Options.Observer != nil || owner.Options.ExpvarName != ""
+}
+
+// emit counts e in the client Stats, passes it to the Observer and the expvar counters, if set, and
+// publishes it on the Events channel, if it was requested, dropping the oldest events if full
+func (cc *CachedClient) emit(e Event) {
+	cc.countDecision(e)
+	owner := cc.owner()
+	if !cc.observed() {
+		return
+	}
+	e.Time = time.Now()
+	if owner.Options.Observer != nil {
+		owner.Options.Observer.Observe(e)
+	}
+	cc.publishEvent(e)
+	ch, ok := owner.events.Load().(chan Event)
+	if !ok {
+		return
+	}
+	for {
+		select {
+		case ch <- e:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// decisionOf returns the Decision matching the outcome of a cache lookup
+func decisionOf(d cacheDecision) Decision {
+	switch {
+	case d.resp == nil || d.err != nil || !d.varyMatches || d.freshness == transparent:
+		return DecisionMiss
+	case d.freshness == fresh:
+		return DecisionHit
+	}
+	return DecisionStale
+}
@@ -0,0 +1,129 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	do := func(method string) {
+		req, _ := http.NewRequest(method, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	do("GET")
+	events := client.Events()
+	do("GET")
+	do("POST")
+	do("GET")
+
+	key := server.URL
+	want := []Event{
+		{Type: EventDecision, Key: key, Decision: DecisionHit},
+		{Type: EventDecision, Key: "POST " + key, Decision: DecisionBypass},
+		{Type: EventEvict, Key: "POST " + key},
+		{Type: EventUpstream, Key: "POST " + key},
+		{Type: EventEvict, Key: "POST " + key},
+		{Type: EventDecision, Key: key, Decision: DecisionHit},
+	}
+	for i, w := range want {
+		e := <-events
+		if e.Type != w.Type || e.Key != w.Key || e.Decision != w.Decision || e.Time.IsZero() {
+			t.Fatalf("event %d: got %v %q %v, want %v %q %v", i, e.Type, e.Key, e.Decision, w.Type, w.Key, w.Decision)
+		}
+	}
+	if len(events) != 0 {
+		t.Fatalf("got %d unexpected events", len(events))
+	}
+
+	client.Cache.Delete(key)
+	do("GET")
+	if e := <-events; e.Decision != DecisionMiss {
+		t.Fatalf("got decision %v, want miss", e.Decision)
+	}
+	if e := <-events; e.Type != EventUpstream || e.Status != http.StatusOK || e.Latency <= 0 {
+		t.Fatalf("got event %v with status %d and latency %v, want the origin request", e.Type, e.Status, e.Latency)
+	}
+	if e := <-events; e.Type != EventStore || e.Size <= len("body") {
+		t.Fatalf("got event %v of size %d, want the stored entry", e.Type, e.Size)
+	}
+}
+
+func TestEventsDropOldest(t *testing.T) {
+	client := &CachedClient{Options: CacheOptions{EventBuffer: 2}}
+	events := client.Events()
+	for _, key := range []string{"a", "b", "c"} {
+		client.emit(Event{Type: EventEvict, Key: key})
+	}
+	if a, b := <-events, <-events; a.Key != "b" || b.Key != "c" {
+		t.Fatalf("got events %q and %q, want the oldest dropped", a.Key, b.Key)
+	}
+	if client.Events() != events {
+		t.Fatal("got a new channel on the second call")
+	}
+}
+
+func TestObserver(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Header().Set("Etag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	counts := map[EventType]int{}
+	var revalidated int
+	var age time.Duration
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}, Options: CacheOptions{
+		Observer: ObserverFunc(func(e Event) {
+			counts[e.Type]++
+			if e.Type == EventRevalidate {
+				revalidated = e.Status
+			}
+			if e.Type == EventDecision {
+				age = e.Age
+			}
+		}),
+	}}
+	do := func() {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	do()
+	clock = &fakeClock{elapsed: 20 * time.Second}
+	do()
+	if counts[EventDecision] != 2 || counts[EventUpstream] != 2 || counts[EventStore] != 2 {
+		t.Fatalf("got events %v, want 2 decisions, 2 origin requests and 2 stores", counts)
+	}
+	if counts[EventRevalidate] != 1 || revalidated != http.StatusNotModified {
+		t.Fatalf("got %d revalidations with status %d, want 1 not modified", counts[EventRevalidate], revalidated)
+	}
+	if age != 20*time.Second {
+		t.Fatalf("got entry age %v, want 20s", age)
+	}
+}
@@ -0,0 +1,84 @@
+package httpcache
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+)
+
+// expvarMap returns the map the client publishes its counters in, publishing it on first use, or nil
+// if Options.ExpvarName isn't set. Clients configured with the same name share the map.
+func (cc *CachedClient) expvarMap() *expvar.Map {
+	owner := cc.owner()
+	name := owner.Options.ExpvarName
+	if name == "" {
+		return nil
+	}
+	if m, ok := owner.vars.Load().(*expvar.Map); ok {
+		return m
+	}
+	owner.mu.Lock()
+	defer owner.mu.Unlock()
+	if m, ok := owner.vars.Load().(*expvar.Map); ok {
+		return m
+	}
+	var m *expvar.Map
+	switch v := expvar.Get(name).(type) {
+	case nil:
+		m = expvar.NewMap(name)
+	case *expvar.Map:
+		m = v
+	default:
+		cc.log(context.Background(), fmt.Sprintf("[httpcache] expvar %v already published with another type. skipping stats publishing", name))
+		m = new(expvar.Map)
+	}
+	owner.vars.Store(m)
+	return m
+}
+
+// publishEvent updates the expvar counters affected by e
+func (cc *CachedClient) publishEvent(e Event) {
+	m := cc.expvarMap()
+	if m == nil {
+		return
+	}
+	switch e.Type {
+	case EventDecision:
+		switch e.Decision {
+		case DecisionHit:
+			m.Add("hits", 1)
+		case DecisionMiss:
+			m.Add("misses", 1)
+		case DecisionStale:
+			m.Add("stale", 1)
+		case DecisionBypass:
+			m.Add("bypassed", 1)
+		}
+	case EventRevalidate:
+		m.Add("revalidations", 1)
+		if e.Status == 304 {
+			m.Add("revalidations_not_modified", 1)
+		}
+	case EventServeStale:
+		m.Add("stale_served", 1)
+	case EventStore:
+		m.Add("stored_entries", 1)
+		if e.Size >= 0 {
+			m.Add("stored_bytes", int64(e.Size))
+		}
+	case EventEvict:
+		m.Add("evictions", 1)
+	case EventUpstream:
+		m.Add("upstream_requests", 1)
+	}
+}
+
+// publishRevalidationQueue publishes the queue of background revalidations of the client (see
+// RevalidationQueue) as revalidation_queue, if Options.ExpvarName is set
+func (cc *CachedClient) publishRevalidationQueue() {
+	if m := cc.expvarMap(); m != nil {
+		m.Set("revalidation_queue", expvar.Func(func() interface{} {
+			return cc.RevalidationQueue()
+		}))
+	}
+}
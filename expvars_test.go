@@ -0,0 +1,70 @@
+package httpcache
+
+import (
+	"expvar"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExpvarStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	if m, ok := expvar.Get("httpcache_test_stats").(*expvar.Map); ok {
+		// Published by a previous run of the test
+		m.Init()
+	}
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{ExpvarName: "httpcache_test_stats"},
+	}
+	for _, method := range []string{"GET", "GET", "POST"} {
+		req, _ := http.NewRequest(method, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	m, ok := expvar.Get("httpcache_test_stats").(*expvar.Map)
+	if !ok {
+		t.Fatal("stats weren't published")
+	}
+	for name, want := range map[string]string{"hits": "1", "misses": "1", "bypassed": "1", "stored_entries": "1", "upstream_requests": "2"} {
+		if got := m.Get(name); got == nil || got.String() != want {
+			t.Errorf("got %v %v, want %v", name, got, want)
+		}
+	}
+
+	// Another client with the same name shares the counters instead of failing to publish them
+	other := &CachedClient{Cache: NewMemoryCache(), Options: CacheOptions{ExpvarName: "httpcache_test_stats"}}
+	other.emit(Event{Type: EventEvict})
+	if got := m.Get("evictions").String(); got != "3" {
+		t.Fatalf("got %v evictions, want the counters shared", got)
+	}
+}
+
+func TestExpvarRevalidationQueue(t *testing.T) {
+	client := &CachedClient{
+		Cache: NewMemoryCache(),
+		Options: CacheOptions{
+			ExpvarName:           "httpcache_test_revalidation_queue",
+			StaleWhileRevalidate: &RevalidationOptions{},
+		},
+	}
+	client.revalidator()
+	m, ok := expvar.Get("httpcache_test_revalidation_queue").(*expvar.Map)
+	if !ok {
+		t.Fatal("stats weren't published")
+	}
+	if got := m.Get("revalidation_queue"); got == nil || got.String() != "[]" {
+		t.Fatalf("got revalidation_queue %v, want an empty queue", got)
+	}
+}
@@ -0,0 +1,128 @@
+package httpcache
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FingerprintRule matches the URLs of fingerprinted assets, whose URL embeds a hash of their content
+// (such as /static/app.3f2a9c1e.js). Since their content can't change without their URL changing
+// too, matching responses are cached forever and served without revalidation, whatever their
+// Cache-Control says.
+//
+// Entries are content-addressed: they are stored under their host, fingerprint and file name rather
+// than their full URL, so the same asset fetched from different paths of a host is stored only once.
+// Responses that couldn't be stored or shared otherwise, such as no-store ones, aren't stored.
+type FingerprintRule struct {
+	// Pattern is matched against the request URL. Its submatch named "hash", or its first submatch
+	// if there is none, captures the fingerprint.
+	Pattern *regexp.Regexp
+}
+
+// match returns the fingerprint captured from rawURL and true if the rule matches it
+func (r FingerprintRule) match(rawURL string) (string, bool) {
+	m := r.Pattern.FindStringSubmatch(rawURL)
+	if m == nil {
+		return "", false
+	}
+	group := 1
+	for i, name := range r.Pattern.SubexpNames() {
+		if name == "hash" {
+			group = i
+		}
+	}
+	if group >= len(m) || m[group] == "" {
+		return "", false
+	}
+	return m[group], true
+}
+
+// fingerprintKey returns the content-addressed key of req if it matches one of the configured
+// FingerprintRules, scoped to its host and to the same prefixes as the keys of other requests
+func (cc *CachedClient) fingerprintKey(req *http.Request) (string, bool) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return "", false
+	}
+	for _, rule := range cc.Options.FingerprintRules {
+		if fp, ok := rule.match(req.URL.String()); ok {
+			name := strings.Replace(path.Base(req.URL.Path), fp, "", 1)
+			return cc.generationPrefix() + cc.partitionPrefix(req) + cc.credentialsPrefix(req) + cc.headersPrefix(req) +
+				"cas:" + fp + " " + req.URL.Host + " " + name, true
+		}
+	}
+	return "", false
+}
+
+// doImmutable serves req, a request for a fingerprinted asset, from the entry stored under key if
+// any. Otherwise the asset is fetched and stored without expiration once its body is read.
+func (cc *CachedClient) doImmutable(req *http.Request, key string) (*http.Response, error) {
+	started := time.Now()
+	if !cc.Options.WriteOnly {
+		cachedResp, err := cachedResponse(cc.backend(req.Context()), key, req)
+		if err == nil {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) fingerprinted asset found for key %v. serving as immutable", req, key))
+			if cc.Options.MarkCachedResponses {
+				cachedResp.Header.Set(XFromCache, "1")
+			}
+			e := Event{Type: EventDecision, Key: key, Decision: DecisionHit}
+			cc.emit(e)
+			cc.hook(req, e)
+			cc.countServed(cachedResp, false)
+			cc.setCacheStatus(cachedResp, cacheStatus{hit: true})
+			return cachedResp, nil
+		}
+	}
+	e := Event{Type: EventDecision, Key: key, Decision: DecisionMiss}
+	cc.emit(e)
+	cc.hook(req, e)
+	if onlyIfCached(req) {
+		resp, err := cc.onlyIfCachedMiss(req)
+		if err == nil {
+			cc.setCacheStatus(resp, cacheStatus{})
+		}
+		return resp, err
+	}
+
+	resp, err := cc.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	status := cacheStatus{fwd: fwdURIMiss, fwdStatus: resp.StatusCode}
+	if cc.Options.WriteOnly {
+		status.fwd = fwdBypass
+	}
+	respCacheControl := parseCacheControl(resp.Header)
+	if resp.StatusCode != http.StatusOK || !canStore(parseCacheControl(req.Header), respCacheControl) || !cc.canShare(req, respCacheControl) {
+		cc.setCacheStatus(resp, status)
+		return resp, nil
+	}
+	status.stored = true
+	cc.setCacheStatus(resp, status)
+	resp.Body = &cachingReadCloser{
+		R: resp.Body,
+		OnEOF: func(r io.Reader) {
+			if cc.nearDeadline(req.Context()) {
+				cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) request deadline too close. skipping insert for key %v", req, key))
+				return
+			}
+			resp := *cc.storedResponse(resp)
+			resp.Body = ioutil.NopCloser(r)
+			respBytes, err := httputil.DumpResponse(&resp, true)
+			if err != nil {
+				return
+			}
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) insert fingerprinted asset for key %v", req, key))
+			if cc.storeEntryTTL(req.Context(), key, respBytes, started, 0) {
+				cc.hook(req, Event{Type: EventStore, Key: key, Size: len(respBytes)})
+			}
+		},
+	}
+	return resp, nil
+}
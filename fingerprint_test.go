@@ -0,0 +1,169 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFingerprintRuleMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		url     string
+		want    string
+		ok      bool
+	}{
+		{`\.([0-9a-f]{8})\.js$`, "http://example.com/static/app.3f2a9c1e.js", "3f2a9c1e", true},
+		{`/(v\d+)/.*\.(?P<hash>[0-9a-f]{8})\.css$`, "http://example.com/v2/site.0badc0de.css", "0badc0de", true},
+		{`\.([0-9a-f]{8})\.js$`, "http://example.com/static/app.js", "", false},
+		{`\.([0-9a-f]{8})?\.js$`, "http://example.com/static/app..js", "", false},
+	} {
+		fp, ok := FingerprintRule{Pattern: regexp.MustCompile(tc.pattern)}.match(tc.url)
+		if fp != tc.want || ok != tc.ok {
+			t.Fatalf("%s: got %q, %v, want %q, %v", tc.url, fp, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestFingerprintedAssets(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		// Fingerprinted assets are commonly served without any caching headers
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte("console.log(1)"))
+	}))
+	defer server.Close()
+	mirror := httptest.NewServer(server.Config.Handler)
+	defer mirror.Close()
+
+	cache := NewMemoryCache()
+	client := &CachedClient{
+		Cache:     cache,
+		Transport: &http.Transport{},
+		Options: CacheOptions{
+			MarkCachedResponses: true,
+			FingerprintRules:    []FingerprintRule{{Pattern: regexp.MustCompile(`\.([0-9a-f]{8})\.js$`)}},
+		},
+	}
+
+	for i, u := range []string{
+		server.URL + "/static/app.3f2a9c1e.js",
+		server.URL + "/static/app.3f2a9c1e.js",
+		server.URL + "/assets/app.3f2a9c1e.js",
+		mirror.URL + "/assets/app.3f2a9c1e.js",
+	} {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "console.log(1)" {
+			t.Fatalf("request %d: got body %q", i, body)
+		}
+		// The mirror is another host, whose entries are kept apart
+		if cached := resp.Header.Get(XFromCache) == "1"; cached != (i == 1 || i == 2) {
+			t.Fatalf("request %d: got cached %v, want %v", i, cached, i == 1 || i == 2)
+		}
+	}
+	if hits != 2 {
+		t.Fatalf("got %d origin requests, want 2", hits)
+	}
+	if keys := cache.Keys(); len(keys) != 2 {
+		t.Fatalf("got keys %v, want the content-addressed keys of both hosts only", keys)
+	}
+}
+
+func TestFingerprintedAssetsOnlyIfCached(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("console.log(1)"))
+	}))
+	defer server.Close()
+
+	for _, asError := range []bool{false, true} {
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+			Options: CacheOptions{
+				OnlyIfCachedError: asError,
+				FingerprintRules:  []FingerprintRule{{Pattern: regexp.MustCompile(`\.([0-9a-f]{8})\.js$`)}},
+			}}
+		req, _ := http.NewRequest("GET", server.URL+"/static/app.3f2a9c1e.js", nil)
+		req.Header.Set("Cache-Control", "only-if-cached")
+		resp, err := client.Do(req)
+		if asError {
+			if err != ErrNoCachedEntry {
+				t.Fatalf("got %v, %v, want ErrNoCachedEntry", resp, err)
+			}
+		} else if err != nil || resp.StatusCode != http.StatusGatewayTimeout {
+			t.Fatalf("got %v, %v, want a 504 response", resp, err)
+		}
+	}
+	if hits != 0 {
+		t.Fatalf("got %d origin requests, want none", hits)
+	}
+}
+
+func TestFingerprintedAssetsShared(t *testing.T) {
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store, private")
+		w.Write([]byte("alert(1)"))
+	}))
+	defer private.Close()
+	var hits int32
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("console.log(1)"))
+	}))
+	defer public.Close()
+
+	cache := NewMemoryCache()
+	client := &CachedClient{
+		Cache:     cache,
+		Transport: &http.Transport{},
+		Options: CacheOptions{
+			SharedCache:      true,
+			FingerprintRules: []FingerprintRule{{Pattern: regexp.MustCompile(`\.([0-9a-f]{8})\.js$`)}},
+		},
+	}
+	for _, u := range []string{private.URL, public.URL} {
+		req, _ := http.NewRequest("GET", u+"/app.deadbeef.js", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if u == public.URL && string(body) != "console.log(1)" {
+			t.Fatalf("got body %q from another host", body)
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("got %d origin requests, want 1", hits)
+	}
+	if keys := cache.Keys(); len(keys) != 1 || !strings.Contains(keys[0], public.Listener.Addr().String()) {
+		t.Fatalf("got keys %v, want the entry of the public host only", keys)
+	}
+}
+
+func TestFingerprintedAssetsGeneration(t *testing.T) {
+	client := &CachedClient{
+		Options: CacheOptions{
+			Generation:       "2",
+			FingerprintRules: []FingerprintRule{{Pattern: regexp.MustCompile(`\.([0-9a-f]{8})\.js$`)}},
+		},
+	}
+	req, _ := http.NewRequest("GET", "http://example.com/static/app.3f2a9c1e.js", nil)
+	if key, _ := client.fingerprintKey(req); key != "generation:2 cas:3f2a9c1e example.com app..js" {
+		t.Fatalf("got key %q, want it scoped to the generation", key)
+	}
+}
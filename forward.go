@@ -0,0 +1,117 @@
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DirectivePolicy decides which Cache-Control directives of a request are forwarded to the origin.
+// It is called with the lowercased name and the unquoted value of every directive, and returns
+// false for the ones to remove.
+type DirectivePolicy func(name, value string) bool
+
+// cacheOnlyDirectives are the request directives that only concern this cache
+var cacheOnlyDirectives = map[string]bool{
+	"max-age":        true,
+	"max-stale":      true,
+	"min-fresh":      true,
+	"only-if-cached": true,
+}
+
+// StripCacheOnlyDirectives is a DirectivePolicy removing the directives that only bound what this
+// cache may serve (max-age, max-stale, min-fresh and only-if-cached), which third-party APIs could
+// misinterpret
+func StripCacheOnlyDirectives(name, value string) bool {
+	return !cacheOnlyDirectives[name]
+}
+
+// StripAllDirectives is a DirectivePolicy removing every request directive, including no-cache used
+// to force a refresh of the cached entry
+func StripAllDirectives(name, value string) bool {
+	return false
+}
+
+// directivePolicy returns the policy applied to forwarded requests, if any
+func (cc *CachedClient) directivePolicy() DirectivePolicy {
+	if cc.Options.ForwardDirectives != nil {
+		return cc.Options.ForwardDirectives
+	}
+	if cc.Options.StripRequestDirectives {
+		return StripCacheOnlyDirectives
+	}
+	return nil
+}
+
+// roundTrip forwards req to the origin through the client Transport, rewriting its directives first.
+// The internal headers the origin may have sent are removed from the response, so that only the
+// client records entry metadata, and the times of the exchange are recorded along with it, to
+// compute its age once stored.
+func (cc *CachedClient) roundTrip(req *http.Request) (*http.Response, error) {
+	if policy := cc.directivePolicy(); policy != nil {
+		req = rewriteDirectives(req, policy)
+	}
+	start := time.Now()
+	resp, err := cc.Transport.RoundTrip(req)
+	if err == nil {
+		StripInternalHeaders(resp.Header)
+		withExchange(resp, req, start, time.Now())
+	}
+	if !cc.observed() {
+		return resp, err
+	}
+	e := Event{Type: EventUpstream, Key: cc.cacheKey(req), Latency: time.Since(start)}
+	if err == nil {
+		e.Status = resp.StatusCode
+	}
+	cc.emit(e)
+	return resp, err
+}
+
+// StripInternalHeaders removes from h the headers using the prefix of the ones the client records
+// in stored responses, such as their assigned lifetime or body hash
+func StripInternalHeaders(h http.Header) {
+	for name := range h {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), controlHeaderPrefix) {
+			delete(h, name)
+		}
+	}
+}
+
+// rewriteDirectives returns req, or a copy of it if needed, without the Cache-Control directives
+// rejected by policy
+func rewriteDirectives(req *http.Request, policy DirectivePolicy) *http.Request {
+	lines := req.Header[http.CanonicalHeaderKey("Cache-Control")]
+	if len(lines) == 0 {
+		return req
+	}
+	var kept []string
+	stripped := false
+	for _, line := range lines {
+		var parts []string
+		for _, part := range splitDirectives(line) {
+			d, ok := parseDirective(part)
+			if !ok {
+				continue
+			}
+			if !policy(d.name, d.value) {
+				stripped = true
+				continue
+			}
+			parts = append(parts, strings.TrimSpace(part))
+		}
+		if len(parts) > 0 {
+			kept = append(kept, strings.Join(parts, ", "))
+		}
+	}
+	if !stripped {
+		return req
+	}
+	req = cloneRequest(req)
+	if len(kept) == 0 {
+		req.Header.Del("Cache-Control")
+	} else {
+		req.Header[http.CanonicalHeaderKey("Cache-Control")] = kept
+	}
+	return req
+}
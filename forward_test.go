@@ -0,0 +1,52 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRewriteDirectives(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy DirectivePolicy
+		header []string
+		want   []string
+	}{
+		{"cache only", StripCacheOnlyDirectives, []string{`max-age=60, no-transform, ext="a, b"`, "only-if-cached"}, []string{`no-transform, ext="a, b"`}},
+		{"nothing to strip", StripCacheOnlyDirectives, []string{"no-cache"}, []string{"no-cache"}},
+		{"all", StripAllDirectives, []string{"no-cache, max-stale"}, nil},
+		{"custom", func(name, value string) bool { return name != "no-cache" }, []string{"No-Cache, min-fresh=10"}, []string{"min-fresh=10"}},
+	} {
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header["Cache-Control"] = tc.header
+		rewritten := rewriteDirectives(req, tc.policy)
+		got := rewritten.Header["Cache-Control"]
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+			}
+		}
+		if len(req.Header["Cache-Control"]) != len(tc.header) || req.Header["Cache-Control"][0] != tc.header[0] {
+			t.Fatalf("%s: got original header %q, want it unchanged", tc.name, req.Header["Cache-Control"])
+		}
+	}
+}
+
+func TestForwardDirectivesOverride(t *testing.T) {
+	client := &CachedClient{Options: CacheOptions{StripRequestDirectives: true}}
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("Cache-Control", "no-cache, max-age=0")
+	if got := rewriteDirectives(req, client.directivePolicy()).Header.Get("Cache-Control"); got != "no-cache" {
+		t.Fatalf("got %q, want only no-cache forwarded", got)
+	}
+	client.Options.ForwardDirectives = StripAllDirectives
+	if got := rewriteDirectives(req, client.directivePolicy()).Header.Get("Cache-Control"); got != "" {
+		t.Fatalf("got %q, want no directive forwarded", got)
+	}
+}
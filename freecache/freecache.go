@@ -0,0 +1,60 @@
+// Package freecache provides an implementation of httpcache.Cache backed by freecache, which keeps
+// entries in a few large preallocated segments instead of individual heap objects, so caching
+// hundreds of thousands of responses adds almost no GC overhead.
+//
+// It is a separate module so that the main one stays free of dependencies.
+package freecache
+
+import (
+	"github.com/coocood/freecache"
+)
+
+// Cache is an implementation of httpcache.Cache backed by a freecache.Cache. When full, the oldest
+// entries are evicted. Entries larger than 1/1024 of the cache size are never stored.
+//
+// Cache also implements httpcache.KeyLister.
+type Cache struct {
+	cache *freecache.Cache
+}
+
+// New returns a new Cache preallocating size bytes (at least 512KB)
+func New(size int) *Cache {
+	return NewWithCache(freecache.NewCache(size))
+}
+
+// NewWithCache returns a new Cache using the already created freecache.Cache cache
+func NewWithCache(cache *freecache.Cache) *Cache {
+	return &Cache{cache: cache}
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *Cache) Get(key string) (resp []byte, ok bool) {
+	resp, err := c.cache.Get([]byte(key))
+	if err != nil {
+		return nil, false
+	}
+	return resp, true
+}
+
+// Set saves response resp to the cache with key, expiring after ttl seconds if positive
+func (c *Cache) Set(key string, resp []byte, ttl int) {
+	if ttl < 0 {
+		ttl = 0
+	}
+	c.cache.Set([]byte(key), resp, ttl)
+}
+
+// Delete removes key from the cache
+func (c *Cache) Delete(key string) {
+	c.cache.Del([]byte(key))
+}
+
+// Keys returns the keys of all the unexpired entries in the cache
+func (c *Cache) Keys() []string {
+	var keys []string
+	it := c.cache.NewIterator()
+	for e := it.Next(); e != nil; e = it.Next() {
+		keys = append(keys, string(e.Key))
+	}
+	return keys
+}
@@ -0,0 +1,21 @@
+package freecache
+
+import (
+	"testing"
+
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+func TestFreecache(t *testing.T) {
+	test.Cache(t, New(1<<20))
+}
+
+func TestKeys(t *testing.T) {
+	c := New(1 << 20)
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 60)
+	c.Delete("a")
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "b" {
+		t.Fatalf("got keys %v, want [b]", keys)
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/lggomez/httpcache/v2/freecache
+
+go 1.17
+
+require (
+	github.com/coocood/freecache v1.2.4
+	github.com/lggomez/httpcache/v2 v2.0.0
+)
+
+require github.com/cespare/xxhash/v2 v2.1.2 // indirect
+
+replace github.com/lggomez/httpcache/v2 => ../
@@ -0,0 +1,127 @@
+// Package gcscache provides an implementation of httpcache.CacheV2 that stores responses as objects
+// of a Google Cloud Storage bucket, through the GCS XML API.
+//
+// Requests are sent with the given http.Client, which is responsible for authentication (for
+// instance, one returned by golang.org/x/oauth2/google.DefaultClient).
+package gcscache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+)
+
+// DefaultEndpoint is the GCS XML API endpoint
+const DefaultEndpoint = "https://storage.googleapis.com"
+
+// expiresHeader holds the expiration time of an object, in unix seconds
+const expiresHeader = "X-Goog-Meta-Httpcache-Expires"
+
+// now returns the current time, and is replaced in tests
+var now = time.Now
+
+// Cache is an implementation of httpcache.CacheV2 storing every entry as an object named after the
+// hash of its key, below a prefix of a bucket. The expiration of entries with a TTL is stored in
+// the object metadata and checked on every read. Expired objects aren't deleted: configure an
+// object lifecycle rule on the bucket to remove them.
+//
+// Use httpcache.AdaptCacheV2 to use it where a httpcache.Cache is expected.
+type Cache struct {
+	client *http.Client
+	bucket string
+	prefix string
+
+	// Endpoint of the XML API, DefaultEndpoint unless changed
+	Endpoint string
+}
+
+// New returns a new Cache storing objects in bucket, with names starting with prefix
+func New(client *http.Client, bucket, prefix string) *Cache {
+	return &Cache{client: client, bucket: bucket, prefix: prefix, Endpoint: DefaultEndpoint}
+}
+
+// Get returns the []byte representation of the response stored with key, or httpcache.ErrCacheMiss
+// if there is none
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.do(ctx, "GET", key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, httpcache.ErrCacheMiss
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("get", key, resp)
+	}
+	if expires := resp.Header.Get(expiresHeader); expires != "" {
+		secs, err := strconv.ParseInt(expires, 10, 64)
+		if err == nil && !now().Before(time.Unix(secs, 0)) {
+			return nil, httpcache.ErrCacheMiss
+		}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Set stores response resp with key, expiring after ttl seconds if positive
+func (c *Cache) Set(ctx context.Context, key string, resp []byte, ttl int) error {
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	if ttl > 0 {
+		header.Set(expiresHeader, strconv.FormatInt(now().Add(time.Duration(ttl)*time.Second).Unix(), 10))
+	}
+	r, err := c.do(ctx, "PUT", key, header, resp)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return statusError("set", key, r)
+	}
+	return nil
+}
+
+// Delete removes key from the bucket
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, "DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return statusError("delete", key, resp)
+	}
+	return nil
+}
+
+// object returns the object name of key
+func (c *Cache) object(key string) string {
+	return c.prefix + httpcache.HashString(nil, key)
+}
+
+func (c *Cache) do(ctx context.Context, method, key string, header http.Header, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.Endpoint+"/"+c.bucket+"/"+c.object(key), r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return c.client.Do(req.WithContext(ctx))
+}
+
+func statusError(op, key string, resp *http.Response) error {
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("gcscache: %s %q: %s: %s", op, key, resp.Status, bytes.TrimSpace(msg))
+}
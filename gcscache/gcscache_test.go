@@ -0,0 +1,106 @@
+package gcscache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+type object struct {
+	data    []byte
+	expires string
+}
+
+// fakeGCS serves the subset of the XML API used by Cache
+func fakeGCS(t *testing.T) (*httptest.Server, map[string]object) {
+	var mu sync.Mutex
+	objects := map[string]object{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		switch r.Method {
+		case "GET":
+			o, ok := objects[name]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			if o.expires != "" {
+				w.Header().Set(expiresHeader, o.expires)
+			}
+			w.Write(o.data)
+		case "PUT":
+			data, _ := ioutil.ReadAll(r.Body)
+			objects[name] = object{data: data, expires: r.Header.Get(expiresHeader)}
+		case "DELETE":
+			if _, ok := objects[name]; !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			delete(objects, name)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	return server, objects
+}
+
+func newCache(server *httptest.Server) *Cache {
+	c := New(server.Client(), "bucket", "responses/")
+	c.Endpoint = server.URL
+	return c
+}
+
+func TestGCSCache(t *testing.T) {
+	server, objects := fakeGCS(t)
+	defer server.Close()
+	c := newCache(server)
+	test.Cache(t, httpcache.AdaptCacheV2(c))
+
+	c.Set(context.Background(), "key", []byte("value"), 0)
+	if _, ok := objects["bucket/responses/"+httpcache.HashString(nil, "key")]; !ok {
+		t.Fatalf("got objects %v, want the entry below the prefix", objects)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	server, _ := fakeGCS(t)
+	defer server.Close()
+	defer func() { now = time.Now }()
+	ctx := context.Background()
+	c := newCache(server)
+
+	if err := c.Set(ctx, "expiring", []byte("1"), 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "expiring"); err != nil {
+		t.Fatalf("got error %v before the entry TTL", err)
+	}
+	now = func() time.Time { return time.Now().Add(time.Minute) }
+	if _, err := c.Get(ctx, "expiring"); err != httpcache.ErrCacheMiss {
+		t.Fatalf("got error %v after the entry TTL, want ErrCacheMiss", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer server.Close()
+	c := newCache(server)
+
+	if _, err := c.Get(context.Background(), "key"); err == nil || err == httpcache.ErrCacheMiss {
+		t.Fatalf("got error %v, want a backend error", err)
+	}
+	if err := c.Set(context.Background(), "key", []byte("value"), 0); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("got error %v, want the GCS error", err)
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/lggomez/httpcache/v2/groupcache
+
+go 1.17
+
+require (
+	github.com/lggomez/httpcache/v2 v2.0.0
+	github.com/mailgun/groupcache/v2 v2.5.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/segmentio/fasthash v1.0.3 // indirect
+	github.com/sirupsen/logrus v1.9.0 // indirect
+	golang.org/x/sys v0.0.0-20220919091848-fb04ddd9f9c8 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
+
+replace github.com/lggomez/httpcache/v2 => ../
@@ -0,0 +1,70 @@
+// Package groupcache provides an implementation of httpcache.CacheV2 backed by groupcache, so a
+// fleet of identical clients can share their cached responses peer-to-peer without a central
+// cache server. Every key is owned by one of the peers, which stores it on behalf of the others.
+//
+// Peers are configured through groupcache itself, usually with groupcache.NewHTTPPool.
+//
+// It is a separate module so that the main one stays free of dependencies.
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/mailgun/groupcache/v2"
+)
+
+// errNotCached is returned by the group getter, since responses can only be stored through Set
+var errNotCached = errors.New("groupcache: response not cached")
+
+// Cache is an implementation of httpcache.CacheV2 backed by a groupcache.Group. Use
+// httpcache.AdaptCacheV2 to use it where a httpcache.Cache is expected.
+type Cache struct {
+	group *groupcache.Group
+}
+
+// New returns a new Cache backed by a new group called name, keeping up to cacheBytes of the
+// entries owned by this peer in memory. The name must be the same on every peer.
+func New(name string, cacheBytes int64) *Cache {
+	getter := groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		return errNotCached
+	})
+	return NewWithGroup(groupcache.NewGroup(name, cacheBytes, getter))
+}
+
+// NewWithGroup returns a new Cache using the already created group, whose getter must fail for
+// keys that weren't stored through Set
+func NewWithGroup(group *groupcache.Group) *Cache {
+	return &Cache{group: group}
+}
+
+// Get returns the []byte representation of the response stored with key, or httpcache.ErrCacheMiss
+// if there is none
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	var resp []byte
+	if err := c.group.Get(ctx, key, groupcache.AllocatingByteSliceSink(&resp)); err != nil {
+		// Errors of remote peers only carry their message
+		if strings.Contains(err.Error(), errNotCached.Error()) {
+			return nil, httpcache.ErrCacheMiss
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Set stores response resp with key on the peer owning it, expiring after ttl seconds if positive
+func (c *Cache) Set(ctx context.Context, key string, resp []byte, ttl int) error {
+	var expire time.Time
+	if ttl > 0 {
+		expire = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+	return c.group.Set(ctx, key, resp, expire, false)
+}
+
+// Delete removes key from every peer
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	return c.group.Remove(ctx, key)
+}
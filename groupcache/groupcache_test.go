@@ -0,0 +1,26 @@
+package groupcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+func TestGroupcache(t *testing.T) {
+	test.Cache(t, httpcache.AdaptCacheV2(New("httpcache-test", 1<<20)))
+}
+
+func TestCacheMiss(t *testing.T) {
+	c := New("httpcache-test-miss", 1<<20)
+	if _, err := c.Get(context.Background(), "missing"); err != httpcache.ErrCacheMiss {
+		t.Fatalf("got error %v, want ErrCacheMiss", err)
+	}
+	if err := c.Set(context.Background(), "key", []byte("value"), 60); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := c.Get(context.Background(), "key"); err != nil || string(val) != "value" {
+		t.Fatalf("got %q, %v, want value, nil", val, err)
+	}
+}
@@ -0,0 +1,60 @@
+package httpcache
+
+// HashedKeyCache is a Cache wrapper replacing keys with their hash before delegating to the
+// wrapped cache, for backends limiting the length or the characters of keys (such as memcached
+// and its 250 bytes, or file names). A readable prefix of the original key can be kept in front
+// of the hash to ease debugging.
+//
+// Since hashes can't be reversed, HashedKeyCache doesn't implement KeyLister.
+type HashedKeyCache struct {
+	inner  Cache
+	prefix int
+
+	// Hasher used for keys. Defaults to SHA256.
+	Hasher Hasher
+}
+
+// NewHashedKeyCache returns a new HashedKeyCache storing entries in inner, under the hash of their
+// key preceded by its first readablePrefix characters, if positive. Characters of the prefix other
+// than ASCII letters, digits and ".:/_-" are replaced with underscores.
+func NewHashedKeyCache(inner Cache, readablePrefix int) *HashedKeyCache {
+	return &HashedKeyCache{inner: inner, prefix: readablePrefix}
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *HashedKeyCache) Get(key string) (resp []byte, ok bool) {
+	return c.inner.Get(c.hashKey(key))
+}
+
+// Set saves response resp to the cache with key
+func (c *HashedKeyCache) Set(key string, resp []byte, ttl int) {
+	c.inner.Set(c.hashKey(key), resp, ttl)
+}
+
+// Delete removes key from the cache
+func (c *HashedKeyCache) Delete(key string) {
+	c.inner.Delete(c.hashKey(key))
+}
+
+// hashKey returns the key under which the entry of key is stored in the wrapped cache
+func (c *HashedKeyCache) hashKey(key string) string {
+	hash := HashString(c.Hasher, key)
+	if c.prefix <= 0 {
+		return hash
+	}
+	prefix := []byte(key)
+	if len(prefix) > c.prefix {
+		prefix = prefix[:c.prefix]
+	}
+	for i, b := range prefix {
+		if !isReadableKeyByte(b) {
+			prefix[i] = '_'
+		}
+	}
+	return string(prefix) + "-" + hash
+}
+
+func isReadableKeyByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '.' || b == ':' || b == '/' || b == '_' || b == '-'
+}
@@ -0,0 +1,35 @@
+package httpcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashedKeyCache(t *testing.T) {
+	inner := NewMemoryCache()
+	key := "GET http://example.com/items?q=" + strings.Repeat("x", 300)
+	for _, tc := range []struct {
+		prefix int
+		want   string
+	}{
+		{0, HashString(nil, key)},
+		{22, "GET_http://example.com-" + HashString(nil, key)},
+		{1000, ""},
+	} {
+		c := NewHashedKeyCache(inner, tc.prefix)
+		if tc.want != "" && c.hashKey(key) != tc.want {
+			t.Fatalf("prefix %d: got key %q, want %q", tc.prefix, c.hashKey(key), tc.want)
+		}
+		c.Set(key, []byte("value"), 0)
+		if got, ok := c.Get(key); !ok || string(got) != "value" {
+			t.Fatalf("prefix %d: got %q (found: %v), want the stored value", tc.prefix, got, ok)
+		}
+		if _, ok := inner.Get(key); ok {
+			t.Fatalf("prefix %d: entry stored under the original key", tc.prefix)
+		}
+		c.Delete(key)
+		if keys := inner.Keys(); len(keys) != 0 {
+			t.Fatalf("prefix %d: got keys %v left after Delete", tc.prefix, keys)
+		}
+	}
+}
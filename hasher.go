@@ -0,0 +1,48 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+)
+
+// A Hasher provides the hash function used to derive digests from cache keys and response
+// bodies, such as storage file names or content-addressed keys. Cheaper non-cryptographic hashes
+// trade collision resistance for throughput, so they should only be used with trusted origins.
+type Hasher interface {
+	// New returns a new hash.Hash computing the digest
+	New() hash.Hash
+}
+
+// HasherFunc is an adapter to use a hash.Hash constructor, such as sha256.New, as a Hasher
+type HasherFunc func() hash.Hash
+
+// New calls f
+func (f HasherFunc) New() hash.Hash {
+	return f()
+}
+
+// SHA256 is the default Hasher
+var SHA256 Hasher = HasherFunc(sha256.New)
+
+// HashString returns the hex encoded digest of s computed with h, or with SHA256 if h is nil
+func HashString(h Hasher, s string) string {
+	return hashBytes(h, []byte(s))
+}
+
+func hashBytes(h Hasher, b []byte) string {
+	if h == nil {
+		h = SHA256
+	}
+	d := h.New()
+	d.Write(b)
+	return hex.EncodeToString(d.Sum(nil))
+}
+
+// hasher returns the Hasher configured for the client, defaulting to SHA256
+func (cc *CachedClient) hasher() Hasher {
+	if cc.Options.Hasher != nil {
+		return cc.Options.Hasher
+	}
+	return SHA256
+}
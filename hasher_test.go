@@ -0,0 +1,26 @@
+package httpcache
+
+import (
+	"hash"
+	"hash/fnv"
+	"testing"
+)
+
+func TestHashString(t *testing.T) {
+	// echo -n key | sha256sum
+	want := "2c70e12b7a0646f92279f427c7b38e7334d8e5389cff167a1dc30e73f826b683"
+	if got := HashString(nil, "key"); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := HashString(SHA256, "key"); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	fnv64 := HasherFunc(func() hash.Hash { return fnv.New64a() })
+	if got := HashString(fnv64, "key"); len(got) != 16 {
+		t.Fatalf("got %q, want a 64-bit digest", got)
+	}
+	if HashString((&CachedClient{}).hasher(), "key") != want {
+		t.Fatal("client doesn't default to SHA256")
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/lggomez/httpcache/v2/hashers
+
+go 1.12
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/lggomez/httpcache/v2 v2.0.0
+	github.com/zeebo/blake3 v0.2.4
+)
+
+replace github.com/lggomez/httpcache/v2 => ../
@@ -0,0 +1,17 @@
+// Package hashers provides httpcache.Hasher implementations backed by third party hash functions.
+// It is a separate module so that the main one stays free of dependencies.
+package hashers
+
+import (
+	"hash"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/lggomez/httpcache/v2"
+	"github.com/zeebo/blake3"
+)
+
+// XXHash is a Hasher using the 64-bit xxHash, a fast non-cryptographic hash
+var XXHash httpcache.Hasher = httpcache.HasherFunc(func() hash.Hash { return xxhash.New() })
+
+// BLAKE3 is a Hasher using the 256-bit BLAKE3 cryptographic hash, usually faster than SHA-256
+var BLAKE3 httpcache.Hasher = httpcache.HasherFunc(func() hash.Hash { return blake3.New() })
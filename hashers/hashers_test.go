@@ -0,0 +1,28 @@
+package hashers
+
+import (
+	"testing"
+
+	"github.com/lggomez/httpcache/v2"
+)
+
+func TestHashers(t *testing.T) {
+	for name, tc := range map[string]struct {
+		hasher httpcache.Hasher
+		size   int
+	}{
+		"xxhash": {XXHash, 16},
+		"blake3": {BLAKE3, 64},
+	} {
+		a := httpcache.HashString(tc.hasher, "http://example.com/a")
+		if len(a) != tc.size {
+			t.Fatalf("%s: got digest %q, want %d hex characters", name, a, tc.size)
+		}
+		if a != httpcache.HashString(tc.hasher, "http://example.com/a") {
+			t.Fatalf("%s: digests of the same key differ", name)
+		}
+		if a == httpcache.HashString(tc.hasher, "http://example.com/b") {
+			t.Fatalf("%s: digests of different keys are equal", name)
+		}
+	}
+}
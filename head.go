@@ -0,0 +1,164 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// doHead does the HEAD request req. HEAD responses aren't stored on their own: a fresh GET entry
+// for the same resource answers the request, and the HEAD responses received from the origin
+// update that entry or invalidate it, as described in RFC 9111 section 4.3.5.
+func (cc *CachedClient) doHead(req *http.Request, started time.Time) (*http.Response, error) {
+	key := cc.cacheKey(asGet(req))
+	var status cacheStatus
+	var d cacheDecision
+	if cc.Options.WriteOnly {
+		status.fwd = fwdBypass
+	} else {
+		d = cc.decideWithin(req, key)
+		if decisionOf(d) == DecisionHit {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) serving head request from stored entry for key %v", req, key),
+				"key", key, "outcome", DecisionHit.String())
+			return cc.serveHit(req, key, withoutBody(d.resp), d.lookup), nil
+		}
+		if d.resp != nil {
+			// Kept without its body, in case the origin can't be reached (see Options.OfflineFallback)
+			d.resp = withoutBody(d.resp)
+		}
+		switch {
+		case d.resp == nil || d.err != nil:
+			status.fwd = fwdURIMiss
+		case !d.varyMatches:
+			status.fwd = fwdVaryMiss
+		case d.freshness == transparent:
+			status.fwd = fwdRequest
+		default:
+			status.fwd = fwdStale
+		}
+		e := Event{Type: EventDecision, Key: key, Decision: decisionOf(d), Latency: d.lookup}
+		cc.emit(e)
+		cc.hook(req, e)
+	}
+	if onlyIfCached(req) {
+		resp, err := cc.onlyIfCachedMiss(req)
+		if err == nil {
+			cc.setCacheStatus(resp, cacheStatus{})
+		}
+		return resp, err
+	}
+
+	cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) head request. executing remote request", req))
+	resp, err := cc.roundTrip(req)
+	if err != nil {
+		if d.resp != nil && d.err == nil && cc.offlineFallback(err, d) {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) unreachable origin in offline fallback mode. using stored entry", req))
+			if cc.Options.MarkCachedResponses {
+				d.resp.Header.Set(XFromCache, "1")
+			}
+			cc.labelStale(key, d.resp, true)
+			atomic.AddInt64(&cc.counters().staleIfError, 1)
+			cc.countServed(d.resp, true)
+			status.hit = true
+			cc.setCacheStatus(d.resp, cc.withTTL(status, req, d.resp))
+			return d.resp, nil
+		}
+		return nil, err
+	}
+	status.fwdStatus = resp.StatusCode
+	if resp.StatusCode == http.StatusOK {
+		status.stored = cc.updateFromHead(req, key, resp, started)
+	}
+	cc.setCacheStatus(resp, status)
+	return resp, nil
+}
+
+// updateFromHead updates the headers of the GET entry stored under key with those of resp, a 200
+// response to the HEAD request req, if they describe the same representation, and evicts the entry
+// otherwise. It returns true if the entry was updated.
+func (cc *CachedClient) updateFromHead(req *http.Request, key string, resp *http.Response, started time.Time) bool {
+	ctx := req.Context()
+	stored, err := cachedResponse(cc.backend(ctx), key, asGet(req))
+	if err != nil {
+		return false
+	}
+	defer stored.Body.Close()
+	if !cc.varyMatches(stored, req) {
+		return false
+	}
+	if !sameRepresentation(stored.Header, resp.Header) {
+		cc.log(ctx, fmt.Sprintf("[httpcache](%p) evicting entry (reason: head response validators mismatch) for key %v", req, key),
+			"key", key, "outcome", "evict")
+		cc.evictEntry(ctx, key)
+		cc.hook(req, Event{Type: EventEvict, Key: key})
+		return false
+	}
+	if !canStore(parseCacheControl(req.Header), parseCacheControl(resp.Header)) || !cc.canShare(req, parseCacheControl(resp.Header)) {
+		return false
+	}
+
+	for _, header := range getEndToEndHeaders(resp.Header) {
+		stored.Header[header] = resp.Header[header]
+	}
+	body, err := ioutil.ReadAll(stored.Body)
+	if err != nil {
+		return false
+	}
+	stored.Body = ioutil.NopCloser(bytes.NewReader(body))
+	entry := cc.storedResponse(stored)
+	stampExchange(entry, resp)
+	respBytes, err := httputil.DumpResponse(entry, true)
+	if err != nil {
+		return false
+	}
+	cc.log(ctx, fmt.Sprintf("[httpcache](%p) update entry (source: head response) for key %v", req, key),
+		"key", key, "outcome", "store")
+	if !cc.storeEntry(ctx, key, respBytes, started) {
+		return false
+	}
+	cc.hook(req, Event{Type: EventStore, Key: key, Size: len(respBytes)})
+	return true
+}
+
+// sameRepresentation returns true if a HEAD response with the headers head describes the same
+// representation as a stored response with the headers stored: their ETag and Last-Modified
+// validators, and their Content-Length when both have one, must be equal
+func sameRepresentation(stored, head http.Header) bool {
+	storedETag := stored.Get("ETag")
+	if stored.Get(syntheticETagHeader) != "" {
+		storedETag = ""
+	}
+	if storedETag != head.Get("ETag") || stored.Get("Last-Modified") != head.Get("Last-Modified") {
+		return false
+	}
+	storedLength, headLength := stored.Get("Content-Length"), head.Get("Content-Length")
+	return storedLength == "" || headLength == "" || storedLength == headLength
+}
+
+// withoutBody strips the body of resp, a stored response answering a HEAD request, keeping its
+// Content-Length. Entries read for a HEAD request already have no body, but streaming backends
+// still hold a reader open until it is closed.
+func withoutBody(resp *http.Response) *http.Response {
+	if resp.Body != http.NoBody {
+		resp.Body.Close()
+		resp.Body = http.NoBody
+	}
+	if resp.ContentLength < 0 {
+		if length, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+			resp.ContentLength = length
+		}
+	}
+	return resp
+}
+
+// asGet returns a copy of req with the GET method, to look up the GET entry of its target
+func asGet(req *http.Request) *http.Request {
+	get := cloneRequest(req)
+	get.Method = http.MethodGet
+	return get
+}
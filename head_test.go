@@ -0,0 +1,142 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeadUpdatesGetEntry(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	etag := `"v1"`
+	methods := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods[r.Method]++
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Header().Set("ETag", etag)
+		w.Header().Set("X-Method", r.Method)
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	cache := NewMemoryCache()
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{MarkCachedResponses: true}}
+	do := func(method string, elapsed time.Duration) *http.Response {
+		clock = &fakeClock{elapsed: elapsed}
+		req, _ := http.NewRequest(method, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	do("GET", 0)
+	if resp := do("HEAD", 5*time.Second); resp.Header.Get(XFromCache) == "" || methods["HEAD"] != 0 {
+		t.Fatalf("got %d HEAD requests, want the HEAD request answered by the fresh GET entry", methods["HEAD"])
+	}
+
+	// A matching HEAD response refreshes the stale entry
+	do("HEAD", 20*time.Second)
+	if resp := do("GET", 5*time.Second); resp.Header.Get("X-Method") != "HEAD" || methods["GET"] != 1 {
+		t.Fatalf("got %d GET requests, want the entry refreshed by the HEAD response", methods["GET"])
+	}
+
+	// A HEAD response for another representation evicts it
+	etag = `"v2"`
+	do("HEAD", 20*time.Second)
+	if keys := cache.Keys(); len(keys) != 0 {
+		t.Fatalf("got keys %v, want the entry evicted and no HEAD entry", keys)
+	}
+	if resp := do("GET", 0); resp.Header.Get(XFromCache) != "" || methods["GET"] != 2 {
+		t.Fatalf("got %d GET requests, want the new representation fetched", methods["GET"])
+	}
+}
+
+func TestHeadFromCacheHasNoBody(t *testing.T) {
+	resetTest()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	for _, cache := range []Cache{NewMemoryCache(), &memoryStreamingCache{MemoryCache: NewMemoryCache()}} {
+		client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{MarkCachedResponses: true}}
+		for _, method := range []string{"GET", "HEAD"} {
+			req, _ := http.NewRequest(method, server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if method == "HEAD" && (resp.Header.Get(XFromCache) == "" || len(body) != 0 ||
+				resp.ContentLength != 4 || resp.Header.Get("Content-Length") != "4") {
+				t.Fatalf("got body %q and length %d from the cache, want no body and length 4", body, resp.ContentLength)
+			}
+		}
+	}
+}
+
+func TestHeadOnlyIfCached(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	for _, asError := range []bool{false, true} {
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+			Options: CacheOptions{OnlyIfCachedError: asError}}
+		req, _ := http.NewRequest("HEAD", server.URL, nil)
+		req.Header.Set("Cache-Control", "only-if-cached")
+		resp, err := client.Do(req)
+		if asError {
+			if err != ErrNoCachedEntry {
+				t.Fatalf("got %v, %v, want ErrNoCachedEntry", resp, err)
+			}
+		} else if err != nil || resp.StatusCode != http.StatusGatewayTimeout {
+			t.Fatalf("got %v, %v, want a 504 response", resp, err)
+		}
+	}
+	if requests != 0 {
+		t.Fatalf("got %d requests to the origin, want none", requests)
+	}
+}
+
+func TestHeadOfflineFallback(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Write([]byte("body"))
+	}))
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+		Options: CacheOptions{MarkCachedResponses: true, OfflineFallback: true}}
+
+	clock = &fakeClock{}
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	server.Close()
+
+	clock = &fakeClock{elapsed: time.Hour}
+	req, _ = http.NewRequest("HEAD", server.URL, nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("got error %v, want the stale entry", err)
+	}
+	if resp.Header.Get(XFromCache) != "1" || resp.Body != http.NoBody {
+		t.Fatal("unreachable origin didn't make the HEAD request fall back to the stored entry without its body")
+	}
+}
@@ -0,0 +1,54 @@
+package httpcache
+
+import (
+	"net/http"
+	"time"
+)
+
+// A Hook is a callback receiving a request handled by a CachedClient along with the Event
+// describing the stage it reached. e.Key is the cache key of the request.
+type Hook func(req *http.Request, e Event)
+
+// Hooks are callbacks fired by CachedClient.Do at each stage of the handling of a request, for
+// custom metrics, audit logging or cache analytics. Unlike an Observer, they receive the request
+// being handled. They are called synchronously, so they must be fast and safe for concurrent use.
+// Any of them may be nil.
+type Hooks struct {
+	// OnHit is called when a fresh entry is served without contacting the origin
+	OnHit Hook
+	// OnMiss is called when no usable entry was found, before the request is sent to the origin
+	OnMiss Hook
+	// OnStore is called when the response to the request is stored. For streamed entries, it is
+	// called once the entry is being written, with a Size of -1.
+	OnStore Hook
+	// OnEvict is called when the entry for the request is removed because it can't be used anymore
+	OnEvict Hook
+	// OnRevalidate is called when a stale entry was revalidated with the origin. The Status of e is
+	// 304 if the entry was kept, and 0 if the origin request failed.
+	OnRevalidate Hook
+}
+
+// hook calls the hook of the client matching e, if any, with req
+func (cc *CachedClient) hook(req *http.Request, e Event) {
+	hooks := cc.owner().Options.Hooks
+	var h Hook
+	switch e.Type {
+	case EventDecision:
+		switch e.Decision {
+		case DecisionHit:
+			h = hooks.OnHit
+		case DecisionMiss:
+			h = hooks.OnMiss
+		}
+	case EventStore:
+		h = hooks.OnStore
+	case EventEvict:
+		h = hooks.OnEvict
+	case EventRevalidate:
+		h = hooks.OnRevalidate
+	}
+	if h != nil {
+		e.Time = time.Now()
+		h(req, e)
+	}
+}
@@ -0,0 +1,79 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Header().Set("Etag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	var got []string
+	var client *CachedClient
+	record := func(name string) Hook {
+		return func(req *http.Request, e Event) {
+			if e.Key != client.cacheKey(req) || e.Time.IsZero() {
+				t.Fatalf("%s: got key %q, want %q", name, e.Key, client.cacheKey(req))
+			}
+			got = append(got, name)
+		}
+	}
+	var revalidated int
+	client = &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}, Options: CacheOptions{
+		Hooks: Hooks{
+			OnHit:   record("hit"),
+			OnMiss:  record("miss"),
+			OnStore: record("store"),
+			OnEvict: record("evict"),
+			OnRevalidate: func(req *http.Request, e Event) {
+				revalidated = e.Status
+				record("revalidate")(req, e)
+			},
+		},
+	}}
+	do := func(method string) {
+		req, _ := http.NewRequest(method, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	do("GET")
+	do("GET")
+	clock = &fakeClock{elapsed: 20 * time.Second}
+	do("GET")
+	want := []string{"miss", "store", "hit", "revalidate", "store"}
+	if len(got) != len(want) {
+		t.Fatalf("got hooks %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got hooks %v, want %v", got, want)
+		}
+	}
+	if revalidated != http.StatusNotModified {
+		t.Fatalf("got revalidation status %d, want 304", revalidated)
+	}
+
+	got = nil
+	do("DELETE")
+	if len(got) != 2 || got[0] != "evict" || got[1] != "evict" {
+		t.Fatalf("got hooks %v, want the bypassing request evicted", got)
+	}
+}
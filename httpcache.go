@@ -1,22 +1,26 @@
 // Package httpcache provides a http.RoundTripper wrapper implementation that works as a
 // mostly RFC-compliant cached client for http responses.
 //
-// It is only suitable for use as a 'private' cache (i.e. for a web-browser or an API-client
-// and not for a shared proxy).
+// It works as a 'private' cache (i.e. for a web-browser or an API-client) by default, and as a
+// shared one (i.e. for a proxy or an API gateway) with CacheOptions.SharedCache.
 //
 package httpcache
 
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -64,19 +68,46 @@ func (f entryFreshness) String() string {
 
 // cacheKey returns the cache key for req.
 func cacheKey(req *http.Request) string {
-	if req.Method == http.MethodGet {
-		return req.URL.String()
+	return methodKey(req.Method, req.URL)
+}
+
+func methodKey(method string, u *url.URL) string {
+	if method == http.MethodGet {
+		return u.String()
 	} else {
-		return req.Method + " " + req.URL.String()
+		return method + " " + u.String()
+	}
+}
+
+// cacheKey returns the cache key the client uses for req, scoped to its Generation and partition if set
+func (cc *CachedClient) cacheKey(req *http.Request) string {
+	return cc.generationPrefix() + cc.partitionPrefix(req) + cc.credentialsPrefix(req) + cc.headersPrefix(req) +
+		methodKey(req.Method, serviceURL(cc.rewriteURL(req.URL), cc.service(req)))
+}
+
+// generationPrefix returns the prefix scoping cache keys to the Generation of the client, if set
+func (cc *CachedClient) generationPrefix() string {
+	if cc.Options.Generation == "" {
+		return ""
 	}
+	return "generation:" + cc.Options.Generation + " "
 }
 
 // CachedResponse returns the cached http.Response for req if present, and nil
 // otherwise.
 func CachedResponse(c Cache, req *http.Request) (resp *http.Response, err error) {
-	cachedVal, ok := c.Get(cacheKey(req))
-	if !ok {
-		return
+	resp, err = cachedResponse(AdaptCache(c), cacheKey(req), req)
+	if err == ErrCacheMiss {
+		return nil, nil
+	}
+	return resp, err
+}
+
+// cachedResponse returns the http.Response stored in c under key, or ErrCacheMiss if there is none
+func cachedResponse(c CacheV2, key string, req *http.Request) (resp *http.Response, err error) {
+	cachedVal, err := c.Get(req.Context(), key)
+	if err != nil {
+		return nil, err
 	}
 
 	b := bytes.NewBuffer(cachedVal)
@@ -84,23 +115,39 @@ func CachedResponse(c Cache, req *http.Request) (resp *http.Response, err error)
 }
 
 // MemoryCache is an implementation of Cache that stores responses in an in-memory map.
+//
+// Entries set with a positive TTL (in seconds) expire once it elapses: they are reported as
+// missing from then on, and are removed by the janitor if one is running (see StartJanitor).
 type MemoryCache struct {
 	mu    sync.RWMutex
-	items map[string][]byte
+	items map[string]memoryItem
+}
+
+type memoryItem struct {
+	value  []byte
+	stored time.Time
+	ttl    time.Duration
+}
+
+func (i memoryItem) expired() bool {
+	return i.ttl > 0 && clock.since(i.stored) >= i.ttl
 }
 
 // Get returns the []byte representation of the response and true if present, false if not
 func (mc *MemoryCache) Get(key string) (resp []byte, ok bool) {
 	mc.mu.RLock()
-	resp, ok = mc.items[key]
+	item, ok := mc.items[key]
 	mc.mu.RUnlock()
-	return resp, ok
+	if !ok || item.expired() {
+		return nil, false
+	}
+	return item.value, true
 }
 
-// Set saves response resp to the cache with key
+// Set saves response resp to the cache with key, expiring after ttl seconds if positive
 func (mc *MemoryCache) Set(key string, resp []byte, ttl int) {
 	mc.mu.Lock()
-	mc.items[key] = resp
+	mc.items[key] = memoryItem{value: resp, stored: time.Now(), ttl: time.Duration(ttl) * time.Second}
 	mc.mu.Unlock()
 }
 
@@ -111,17 +158,239 @@ func (mc *MemoryCache) Delete(key string) {
 	mc.mu.Unlock()
 }
 
+// Keys returns the keys of all the unexpired entries in the cache
+func (mc *MemoryCache) Keys() []string {
+	mc.mu.RLock()
+	keys := make([]string, 0, len(mc.items))
+	for key, item := range mc.items {
+		if !item.expired() {
+			keys = append(keys, key)
+		}
+	}
+	mc.mu.RUnlock()
+	return keys
+}
+
+// DeleteExpired removes all the expired entries from the cache
+func (mc *MemoryCache) DeleteExpired() {
+	mc.mu.Lock()
+	for key, item := range mc.items {
+		if item.expired() {
+			delete(mc.items, key)
+		}
+	}
+	mc.mu.Unlock()
+}
+
+// StartJanitor runs DeleteExpired every interval in a background goroutine until the returned
+// stop function is called
+func (mc *MemoryCache) StartJanitor(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				mc.DeleteExpired()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
 // NewMemoryCache returns a new Cache that will store items in an in-memory map
 func NewMemoryCache() *MemoryCache {
-	c := &MemoryCache{items: map[string][]byte{}}
+	c := &MemoryCache{items: map[string]memoryItem{}}
 	return c
 }
 
 type CacheOptions struct {
+	// TTL, in seconds, passed to the Cache when storing entries. Zero means no expiration.
 	TTL int
 	// If true, responses returned from the cache will be given an extra header, X-From-Cache
 	MarkCachedResponses bool
-	Debug               bool
+	// If true, the client logs how it handles every request, to Logger if set and to the standard
+	// error otherwise. See WithDebug to log a single request.
+	Debug bool
+	// If set, receives the debug messages of the client. See Logger.
+	Logger Logger
+	// If true, responses are always fetched from the origin and stored, but never served from the cache.
+	// This is meant for background warmers feeding a cache that is read by other clients.
+	WriteOnly bool
+	// If set, resources linked from stored responses through Link headers are prefetched in the background
+	Prefetch *PrefetchOptions
+	// If positive, the freshness lifetime of permanent redirects (301 and 308 responses) stored without
+	// explicit expiration time, so that they are served from the cache. Zero means they are always
+	// revalidated.
+	PermanentRedirectLifetime time.Duration
+	// If set, only the responses with these status codes are stored, such as
+	// HeuristicallyCacheableStatusCodes. Responses whose status code isn't heuristically cacheable
+	// must also have an explicit expiration time. If nil, responses are stored whatever their status.
+	CacheableStatusCodes []int
+	// If positive, 404, 410, 429 and 5xx responses are stored, whatever CacheableStatusCodes, and
+	// served from the cache for this long regardless of their own expiration time, so that hot
+	// failing resources don't hammer the origin. Their directives forbidding storage or reuse, such
+	// as no-store or no-cache, still apply. Rounded down to the second.
+	ErrorTTL time.Duration
+	// The freshness lifetimes of the responses stored without explicit expiration time, per status
+	// code, overriding PermanentRedirectLifetime. Only heuristically cacheable status codes apply.
+	HeuristicLifetimes map[int]time.Duration
+	// If positive, fresh entries whose resource announced a Sunset closer than this window are revalidated
+	SunsetRefreshWindow time.Duration
+	// If true, concurrent identical GET requests missing the cache are coalesced into a single upstream
+	// request whose response is shared. Coalesced response bodies are buffered in memory.
+	CoalesceRequests bool
+	// If set, OnSunset is called whenever a stored response announces a Sunset or a Deprecation
+	OnSunset func(req *http.Request, meta EntryMetadata)
+	// If positive, bounds the time spent retrieving the cached entry of a request and evaluating its
+	// freshness. Past it, the entry is ignored and the request goes to the origin.
+	DecisionTimeout time.Duration
+	// If set, Generation is mixed into every cache key, so that changing it (usually on application
+	// releases) invalidates all the entries stored under previous generations at once. Entries from
+	// other generations are left in the backend until they expire or are evicted.
+	Generation string
+	// If set, a share of the requests is handled with the canary options instead. See CanaryOptions.
+	Canary *CanaryOptions
+	// Hasher used for the digests the client derives from keys and response bodies. Defaults to SHA256.
+	Hasher Hasher
+	// If positive, responses aren't stored when the request context is due to expire within this margin
+	// once they are received, so serializing a large body never pushes a request past its deadline
+	StoreDeadlineMargin time.Duration
+	// If set, Partition returns the cache partition of a request, such as the calling service or user
+	// agent class. Entries are only shared between requests of the same partition, like in browser
+	// partitioned caches, so callers can't learn about each other through cache timings. If not set,
+	// the partition is taken from the request context (see WithPartition).
+	Partition func(req *http.Request) string
+	// If set, the POST requests for which CachePOST returns true, such as idempotent search or
+	// GraphQL queries, are cached like GET requests, keyed by their URL and the hash of their body.
+	// Request bodies are buffered in memory unless they can be obtained again through GetBody.
+	CachePOST func(req *http.Request) bool
+	// If true, the entry stored for a GET request is served whenever the origin can't be reached
+	// (DNS resolution, connection and timeout errors), however stale, even without stale-if-error
+	// directive or in spite of must-revalidate. It is labeled like other stale responses served
+	// because of a disconnection (see DisableStaleWarnings and StaleLabels). Meant for clients that
+	// must degrade gracefully when offline.
+	OfflineFallback bool
+	// If true, the X-Httpcache-* request headers steer the cache for a single request, for
+	// frameworks that can add headers to requests but not contexts. See BypassHeader, TTLHeader and
+	// DebugHeader. They are stripped from the requests forwarded to the origin. As they bypass the
+	// policy of the client, this should only be set if requests come from trusted callers.
+	ControlHeaders bool
+	// If true, requests with the only-if-cached directive that can't be answered from the cache fail
+	// with ErrNoCachedEntry instead of receiving a synthetic 504 Gateway Timeout response
+	OnlyIfCachedError bool
+	// If set, stale JSON responses served from the cache are labeled with these headers
+	StaleLabels *StaleLabels
+	// If set, the values of the request headers listed in the Vary header of responses are
+	// normalized before being compared, such as with NormalizeAcceptHeaders
+	NormalizeVary VaryNormalizer
+	// If true, the partial (206) responses to Range requests are stored, assembled per resource as
+	// long as the ranges received for a same representation are contiguous, so that the ranges
+	// already fetched are served from the cache. Once complete, the assembled representation is
+	// stored as a full response.
+	StorePartial bool
+	// If true, the variants of responses with a Vary header are stored side by side, keyed by the
+	// values of the request headers they vary on, so that requests alternating between them (such
+	// as with different Accept headers) don't evict each other's entry. Variants aren't kept by
+	// StreamingCache backends.
+	StoreVariants bool
+	// If true, responses with Vary: * aren't stored. Otherwise they are, and validated with the
+	// origin before each reuse.
+	NoStoreVaryStar bool
+	// If true, stale responses served from the cache aren't given the "110 Response is Stale"
+	// Warning header, nor the "112 Disconnected Operation" one when the origin couldn't be reached
+	DisableStaleWarnings bool
+	// If true, the client follows the semantics of a shared cache, for use in a proxy or an API
+	// gateway serving several users: s-maxage overrides max-age, private responses aren't stored,
+	// responses to requests with an Authorization header are only stored if public, must-revalidate
+	// or s-maxage allow it (see Authorization), and proxy-revalidate is honored
+	SharedCache bool
+	// Authorization decides how the responses to requests carrying an Authorization header are
+	// cached. See AuthorizationPolicy.
+	Authorization AuthorizationPolicy
+	// Requests matching any of these rules are treated as fingerprinted assets. See FingerprintRule.
+	FingerprintRules []FingerprintRule
+	// If positive, the maximum number of requests of a BatchGet running at once against the same host
+	MaxBatchPerHost int
+	// Per-route policy overrides, which can be replaced at runtime with SetRules. See RouteRule.
+	Rules []RouteRule
+	// If set, Seed is consulted on cache misses before going to the origin. See SeedSource.
+	Seed SeedSource
+	// Rewriters applied in order to the URL of requests before it becomes part of their cache key, so
+	// that equivalent requests share an entry. See NormalizeHost, SortQuery, DropQueryParams,
+	// StripFragment and TimeBucket.
+	KeyRewriters []KeyRewriter
+	// The request headers whose values are part of the cache key of requests, such as Accept or a
+	// tenant header, so that their responses are kept apart even if the origin omits them from
+	// Vary. Values are normalized with NormalizeVary if set.
+	KeyHeaders []string
+	// If set, the request Cache-Control directives that only concern this cache (max-age, max-stale,
+	// min-fresh and only-if-cached) are removed from the requests forwarded to the origin, for APIs
+	// that would misinterpret them. See also WithMaxAcceptableAge.
+	StripRequestDirectives bool
+	// If set, ForwardDirectives decides which request Cache-Control directives are forwarded to the
+	// origin, overriding StripRequestDirectives. See DirectivePolicy.
+	ForwardDirectives DirectivePolicy
+	// If set, Transform is called with the responses received from the origin before they are stored
+	// and returned. It may modify them (their headers, or their body, keeping Content-Length
+	// consistent) and returns true if it did.
+	Transform func(req *http.Request, resp *http.Response) bool
+	// How the responses modified by Transform are flagged. See TransformMark.
+	MarkTransformed TransformMark
+	// If set, a hash of the body of stored responses is kept along with them. It is available
+	// through EntryMetadata, and used by DoIfChanged.
+	HashBodies bool
+	// If set along with HashBodies, responses served from the cache carry their body hash in this header
+	BodyHashHeader string
+	// If set, responses stored without ETag nor Last-Modified get a weak ETag synthesized from their
+	// body hash. Once stale, such entries are refreshed with a HEAD request first, and kept without
+	// downloading the body again if its Content-Length is unchanged.
+	SynthesizeValidators bool
+	// If set, stale GET responses still within their stale-while-revalidate window (RFC 5861) are
+	// served right away while being revalidated in the background. See RevalidationOptions.
+	StaleWhileRevalidate *RevalidationOptions
+	// If set, Service returns the logical service a request is addressed to, such as the name of an
+	// API served by several mirror hosts (see ServiceHosts). Requests to the same service share their
+	// entries whatever their scheme and host, and are invalidated together. The service carried by the
+	// request context, if any, takes precedence (see WithService).
+	Service func(req *http.Request) string
+	// Capacity of the channel returned by CachedClient.Events. Defaults to DefaultEventBuffer.
+	EventBuffer int
+	// If set, Observer receives every event of the client. See Observer.
+	Observer Observer
+	// Callbacks fired at each stage of the handling of a request. See Hooks.
+	Hooks Hooks
+	// If set, responses are given a Cache-Status header (RFC 9211) in which the client identifies
+	// itself with this name, telling whether they were served from the cache (hit, with their ttl)
+	// or why they were forwarded to the origin (fwd, with the fwd-status of the origin response).
+	// It complements MarkCachedResponses.
+	CacheStatus string
+	// If set, the client publishes live counters (hits, misses, stale, bypassed, revalidations,
+	// revalidations_not_modified, stale_served, stored_entries, stored_bytes, evictions and
+	// upstream_requests) as an expvar.Map with this name, so they are served on /debug/vars. Clients
+	// configured with the same name add up their counters.
+	ExpvarName string
+	// If set, successful responses to unsafe methods (such as POST, PUT or DELETE) invalidate the GET
+	// and HEAD entries of their target URI, as required by RFC 9111 section 4.4
+	InvalidateOnUnsafeMethods bool
+	// How long invalidated keys are remembered, so the responses fetched by requests started before the
+	// invalidation aren't stored once they complete. It should exceed the duration of the slowest
+	// requests. Defaults to DefaultTombstoneTTL.
+	TombstoneTTL time.Duration
+	// If positive, the keys found missing from the cache or whose response couldn't be stored are
+	// remembered in process for this long, and their lookups are skipped meanwhile. Storing an entry
+	// for a key forgets it right away, but entries stored by other processes sharing the backend are
+	// only seen once the key is forgotten.
+	NegativeLookupTTL time.Duration
+	// Maximum number of keys remembered through NegativeLookupTTL. Defaults to DefaultMaxNegativeLookups.
+	MaxNegativeLookups int
 }
 
 type ClientOptions struct {
@@ -133,7 +402,25 @@ type ClientOptions struct {
 type CachedClient struct {
 	Transport http.RoundTripper
 	Cache     Cache
-	Options   CacheOptions
+	// CacheV2, if set, is used to store and retrieve responses instead of Cache
+	CacheV2 CacheV2
+	Options CacheOptions
+
+	mu          sync.Mutex
+	derived     map[string]bool // keys of the responses GetOrFetch stored derived artifacts for
+	prefetchSem chan struct{}
+	flights     flightGroup
+	arms        [2]*canaryArm    // control and canary clients, when Options.Canary is set
+	parent      *CachedClient    // client that created this one as a canary arm
+	rules       atomic.Value     // []RouteRule set through SetRules
+	reval       *revalidator     // background revalidations, when Options.StaleWhileRevalidate is set
+	events      atomic.Value     // chan Event, once requested through Events
+	misses      *negativeLookups // known misses, when Options.NegativeLookupTTL is set
+	keyLocks    keyLocks         // serializes the writes and invalidations of each key
+	tombs       tombstones       // recently invalidated keys
+	vars        atomic.Value     // *expvar.Map, when Options.ExpvarName is set
+	stats       *clientStats     // counters behind Stats, created once through statsOnce
+	statsOnce   sync.Once
 }
 
 // NewCachedClient returns a new Transport with the
@@ -142,6 +429,11 @@ func NewCachedClient(client *http.Client, c Cache, options CacheOptions) Doer {
 	return &CachedClient{Cache: c, Transport: client.Transport, Options: options}
 }
 
+// NewCachedClientV2 returns a new Transport with the provided context-aware CacheV2 implementation
+func NewCachedClientV2(client *http.Client, c CacheV2, options CacheOptions) Doer {
+	return &CachedClient{CacheV2: c, Transport: client.Transport, Options: options}
+}
+
 // NewMemoryCachedClient returns a new Transport using the in-memory map cache implementation
 func NewMapCachedClient(client *http.Client) Doer {
 	c := NewMemoryCache()
@@ -149,24 +441,125 @@ func NewMapCachedClient(client *http.Client) Doer {
 	return cc
 }
 
-func (cc *CachedClient) log(message string) {
-	if cc.Options.Debug {
-		println(message)
+// log writes message to the client Logger, or prints it if none is set, when Debug is enabled or
+// ctx was returned by WithDebug. keyvals are alternating field names and values describing the message.
+func (cc *CachedClient) log(ctx context.Context, message string, keyvals ...interface{}) {
+	if !cc.Options.Debug && !debugFromContext(ctx) {
+		return
+	}
+	if cc.Options.Logger != nil {
+		cc.Options.Logger.Debug(strings.TrimSpace(message), keyvals...)
+		return
+	}
+	println(message)
+}
+
+// backend returns the CacheV2 used by the client for ctx, adapting Cache if CacheV2 isn't set. If
+// ctx carries a scratch cache (see WithScratchCache), it is layered over the client cache.
+func (cc *CachedClient) backend(ctx context.Context) CacheV2 {
+	main := cc.CacheV2
+	if main == nil {
+		main = AdaptCache(cc.Cache)
+	}
+	if l := scratchLayerFromContext(ctx); l != nil {
+		return l.over(main)
+	}
+	return main
+}
+
+// storeEntry saves respBytes under key, purging the artifacts derived from the previous entry. The
+// entry isn't stored if key was invalidated since started, when the request fetching it started.
+// It returns true if the entry was stored.
+func (cc *CachedClient) storeEntry(ctx context.Context, key string, respBytes []byte, started time.Time) bool {
+	return cc.storeEntryTTL(ctx, key, respBytes, started, cc.entryTTL(ctx, key))
+}
+
+// storeEntryTTL works like storeEntry, storing the entry with the given backend TTL
+func (cc *CachedClient) storeEntryTTL(ctx context.Context, key string, respBytes []byte, started time.Time, ttl int) bool {
+	unlock := cc.owner().keyLocks.lock(key)
+	defer unlock()
+	if cc.buriedSince(key, started) {
+		cc.log(ctx, fmt.Sprintf("[httpcache] entry invalidated while being fetched. skipping insert for key %v", key))
+		return false
+	}
+	cc.purgeDerived(ctx, key)
+	err := cc.backend(ctx).Set(ctx, key, respBytes, ttl)
+	cc.negativeLookups(ctx).forget(key)
+	if err != nil {
+		cc.log(ctx, fmt.Sprintf("[httpcache] cache backend error on set for key %v (%v)", key, err))
+		return false
+	}
+	cc.emit(Event{Type: EventStore, Key: key, Size: len(respBytes)})
+	return true
+}
+
+// nearDeadline returns true if ctx is done or its deadline falls within the StoreDeadlineMargin
+func (cc *CachedClient) nearDeadline(ctx context.Context) bool {
+	if cc.Options.StoreDeadlineMargin <= 0 {
+		return false
+	}
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < cc.Options.StoreDeadlineMargin
+}
+
+// evictEntry removes the entry stored under key along with its derived artifacts
+func (cc *CachedClient) evictEntry(ctx context.Context, key string) {
+	misses := cc.negativeLookups(ctx)
+	seq := misses.lookupSeq()
+	if err := cc.backend(ctx).Delete(ctx, key); err != nil {
+		cc.log(ctx, fmt.Sprintf("[httpcache] cache backend error on delete for key %v (%v)", key, err))
+	} else {
+		misses.rememberMiss(key, seq)
+	}
+	cc.purgeDerived(ctx, key)
+	cc.purgeVariants(ctx, key)
+	cc.purgePartial(ctx, key)
+	cc.emit(Event{Type: EventEvict, Key: key})
+}
+
+// setVaried records in resp, a response to req about to be stored, the values of the request headers
+// listed in its Vary header
+func setVaried(resp *http.Response, req *http.Request) {
+	for _, varyKey := range headerAllCommaSepValues(resp.Header, "vary") {
+		varyKey = http.CanonicalHeaderKey(varyKey)
+		fakeHeader := "X-Varied-" + varyKey
+		reqValue := req.Header.Get(varyKey)
+		if reqValue != "" {
+			resp.Header.Set(fakeHeader, reqValue)
+		}
 	}
 }
 
 // varyMatches will return false unless all of the cached values for the headers listed in Vary
-// match the new request
-func varyMatches(cachedResp *http.Response, req *http.Request) bool {
+// match the new request, once normalized if configured. Vary: * is skipped, as no request matches
+// it: entries carrying it are always revalidated instead (see varyStar).
+func (cc *CachedClient) varyMatches(cachedResp *http.Response, req *http.Request) bool {
 	for _, header := range headerAllCommaSepValues(cachedResp.Header, "vary") {
 		header = http.CanonicalHeaderKey(header)
-		if header != "" && req.Header.Get(header) != cachedResp.Header.Get("X-Varied-"+header) {
+		if header == "*" {
+			continue
+		}
+		if header != "" && cc.varyValue(header, req.Header.Get(header)) != cc.varyValue(header, cachedResp.Header.Get("X-Varied-"+header)) {
 			return false
 		}
 	}
 	return true
 }
 
+// varyStar returns true if respHeaders list * in Vary, meaning the response varies on things
+// other than request headers, and can't be reused without being validated with the origin
+func varyStar(respHeaders http.Header) bool {
+	for _, header := range headerAllCommaSepValues(respHeaders, "vary") {
+		if header == "*" {
+			return true
+		}
+	}
+	return false
+}
+
 // RoundTrip takes a Request and returns a Response
 //
 // If there is a fresh Response already in cache, then it will be returned without connecting to
@@ -176,22 +569,74 @@ func varyMatches(cachedResp *http.Response, req *http.Request) bool {
 // to give the server a chance to respond with NotModified. If this happens, then the cached Response
 // will be returned.
 func (cc *CachedClient) Do(req *http.Request) (resp *http.Response, err error) {
-	cacheKey := cacheKey(req)
-	cacheable := (req.Method == "GET" || req.Method == "HEAD") && req.Header.Get("range") == ""
+	req, bypass := cc.applyControlHeaders(req)
+	if bypass {
+		return cc.doBypass(req)
+	}
+	if arm := cc.canaryArm(req); arm != nil {
+		return arm.client.Do(req)
+	}
+	if key, ok := cc.fingerprintKey(req); ok {
+		return cc.doImmutable(req, key)
+	}
+
+	started := time.Now()
+	cacheKey := cc.cacheKey(req)
+	if req.Method == "GET" && req.Header.Get("range") != "" {
+		return cc.doRange(req, cacheKey, started)
+	}
+	if req.Method == "HEAD" {
+		return cc.doHead(req, started)
+	}
+	cacheable := req.Method == "GET" && req.Header.Get("range") == ""
+	if cc.cachesPost(req) {
+		if cacheKey, req, err = cc.postKey(req, cacheKey); err != nil {
+			return nil, err
+		}
+		cacheable = true
+	}
 	var cachedResp *http.Response
+	var decision cacheDecision
+	var probe bool
+	var status cacheStatus
+	defer func() {
+		// Release the body of a cached entry that isn't being returned
+		if cachedResp != nil && cachedResp != resp {
+			cachedResp.Body.Close()
+		}
+	}()
 
 	// Cached response retrieval
-	if cacheable {
-		cachedResp, err = CachedResponse(cc.Cache, req)
-		cc.log(fmt.Sprintf("\n[httpcache](%p) cached get key %v: (err:%v, nil:%v)",
+	if cacheable && cc.Options.WriteOnly {
+		cc.log(req.Context(), fmt.Sprintf("\n[httpcache](%p) write-only mode. skipping cached get for key %v", req, cacheKey),
+			"key", cacheKey, "outcome", DecisionBypass.String())
+		cc.emit(Event{Type: EventDecision, Key: cacheKey, Decision: DecisionBypass})
+		status.fwd = fwdBypass
+	} else if cacheable {
+		decision = cc.decideWithin(req, cacheKey)
+		cachedResp, err = decision.resp, decision.err
+		cc.log(req.Context(), fmt.Sprintf("\n[httpcache](%p) cached get key %v: (err:%v, nil:%v)",
 			req,
 			cacheKey,
 			err,
-			cachedResp == nil))
+			cachedResp == nil),
+			"key", cacheKey, "outcome", decisionOf(decision).String(), "freshness", decision.freshness.String())
+		e := Event{Type: EventDecision, Key: cacheKey, Decision: decisionOf(decision), Latency: decision.lookup}
+		if e.Decision == DecisionHit || e.Decision == DecisionStale {
+			if date, err := responseDate(cachedResp.Header); err == nil {
+				e.Age = currentAge(cachedResp.Header, date)
+			}
+		}
+		cc.emit(e)
+		cc.hook(req, e)
 	} else {
+		cc.emit(Event{Type: EventDecision, Key: cacheKey, Decision: DecisionBypass})
+		status.fwd = fwdBypass
 		// Need to invalidate an existing value
-		cc.log(fmt.Sprintf("\n[httpcache](%p) evicting entry (reason: cacheable == false) for key %v", req, cacheKey))
-		cc.Cache.Delete(cacheKey)
+		cc.log(req.Context(), fmt.Sprintf("\n[httpcache](%p) evicting entry (reason: cacheable == false) for key %v", req, cacheKey),
+			"key", cacheKey, "outcome", DecisionBypass.String())
+		cc.evictEntry(req.Context(), cacheKey)
+		cc.hook(req, Event{Type: EventEvict, Key: cacheKey})
 	}
 
 	// Response/request validation and remote request
@@ -200,12 +645,26 @@ func (cc *CachedClient) Do(req *http.Request) (resp *http.Response, err error) {
 			cachedResp.Header.Set(XFromCache, "1")
 		}
 
-		if varyMatches(cachedResp, req) {
+		if decision.varyMatches {
 			// Can only use cached value if the new request doesn't Vary significantly
-			freshness := cc.getFreshness(req, cachedResp.Header)
-			cc.log(fmt.Sprintf("[httpcache](%p) varyMatches: true, freshness: %s, processing result", req, freshness))
+			freshness := decision.freshness
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) varyMatches: true, freshness: %s, processing result", req, freshness),
+				"key", cacheKey, "freshness", freshness.String())
 
 			if freshness == fresh {
+				if cc.expired(cachedResp.Header) {
+					// Accepted past its lifetime, as allowed by the request
+					cc.labelStale(cacheKey, cachedResp, false)
+				}
+				cc.countServed(cachedResp, false)
+				cc.setCacheStatus(cachedResp, cc.withTTL(cacheStatus{hit: true}, req, cachedResp))
+				return cachedResp, nil
+			}
+
+			if freshness == stale && !decision.revalidateBy.IsZero() && cc.revalidateInBackground(req, cacheKey, decision.revalidateBy) {
+				cc.labelStale(cacheKey, cachedResp, false)
+				cc.countServed(cachedResp, false)
+				cc.setCacheStatus(cachedResp, cc.withTTL(cacheStatus{hit: true}, req, cachedResp))
 				return cachedResp, nil
 			}
 
@@ -213,114 +672,226 @@ func (cc *CachedClient) Do(req *http.Request) (resp *http.Response, err error) {
 				var req2 *http.Request
 				// Add validators if caller hasn't already done so
 				etag := cachedResp.Header.Get("etag")
-				if etag != "" && req.Header.Get("etag") == "" {
-					req2 = cloneRequest(req)
-					cc.log(fmt.Sprintf("[httpcache](%p) setting request if-none-match to %s from cached etag", req, etag))
-					req2.Header.Set("if-none-match", etag)
+				if cachedResp.Header.Get(syntheticETagHeader) != "" {
+					// Synthesized locally, so the origin can't validate it
+					etag = ""
+					probe = req.Method == "GET"
+				}
+				if ifNoneMatch := req.Header.Get("if-none-match"); etag != "" {
+					if merged := mergeIfNoneMatch(ifNoneMatch, etag); merged != ifNoneMatch {
+						req2 = cloneRequest(req)
+						cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) setting request if-none-match to %s from cached etag", req, merged))
+						req2.Header.Set("if-none-match", merged)
+					}
 				}
 				lastModified := cachedResp.Header.Get("last-modified")
 				if lastModified != "" && req.Header.Get("last-modified") == "" {
 					if req2 == nil {
 						req2 = cloneRequest(req)
 					}
-					cc.log(fmt.Sprintf("[httpcache](%p) setting request if-modified-since to %s from cached last-modified", req, lastModified))
+					cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) setting request if-modified-since to %s from cached last-modified", req, lastModified))
 					req2.Header.Set("if-modified-since", lastModified)
 				}
 				if req2 != nil {
-					cc.log(fmt.Sprintf("[httpcache](%p) overriding request with updated validator headers", req))
+					cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) overriding request with updated validator headers", req))
 					req = req2
 				}
 			}
 		}
 
-		cc.log(fmt.Sprintf("[httpcache](%p) cache miss or stale entry. executing remote request", req))
-		resp, err = cc.Transport.RoundTrip(req)
-		if err == nil && req.Method == "GET" && resp.StatusCode == http.StatusNotModified {
+		switch {
+		case !decision.varyMatches:
+			status.fwd = fwdVaryMiss
+		case decision.freshness == transparent:
+			status.fwd = fwdRequest
+		default:
+			status.fwd = fwdStale
+		}
+		if probe {
+			resp = cc.probeUnchanged(req, cachedResp)
+		}
+		if resp == nil {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) cache miss or stale entry. executing remote request", req))
+			resp, err = cc.roundTrip(req)
+		}
+		if err == nil {
+			status.fwdStatus = resp.StatusCode
+		}
+		if decision.varyMatches && decision.freshness == stale {
+			e := Event{Type: EventRevalidate, Key: cacheKey}
+			if err == nil {
+				e.Status = resp.StatusCode
+			}
+			cc.emit(e)
+			cc.hook(req, e)
+		}
+		if err == nil && req.Method == "GET" && resp.StatusCode == http.StatusNotModified && notModifiedApplies(cachedResp, resp) {
 			// Replace the 304 response with the one from cache, but update with some new headers
 			endToEndHeaders := getEndToEndHeaders(resp.Header)
 			for _, header := range endToEndHeaders {
 				cachedResp.Header[header] = resp.Header[header]
 			}
+			stampExchange(cachedResp, resp)
 			resp.Body.Close()
 			resp = cachedResp
-			cc.log(fmt.Sprintf("[httpcache](%p) 304 server response obtained. using local cache response", req))
-		} else if (err != nil || (cachedResp != nil && resp.StatusCode >= 500)) &&
-			req.Method == "GET" && canStaleOnError(cachedResp.Header, req.Header) {
-			// In case of transport failure and stale-if-error activated, returns cached content
-			// when available
+			atomic.AddInt64(&cc.counters().notModified, 1)
+			cc.countServed(cachedResp, true)
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) 304 server response obtained. using local cache response", req))
+		} else if (err != nil || (cachedResp != nil && resp.StatusCode >= 500)) && req.Method == "GET" &&
+			(cc.offlineFallback(err, decision) || (canStaleOnError(cachedResp.Header, req.Header) &&
+				!(cc.mustRevalidate(parseCacheControl(cachedResp.Header)) && cc.expired(cachedResp.Header)))) {
+			// In case of transport failure and stale-if-error activated, or of an unreachable
+			// origin in offline fallback mode, returns cached content when available
 			if resp != nil && resp.Body != nil {
 				resp.Body.Close()
 			}
-			cc.log(fmt.Sprintf("[httpcache](%p) transport/upstream error with stale-if-error. using local cache response", req))
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) transport/upstream error with stale-if-error. using local cache response", req))
+			cc.labelStale(cacheKey, cachedResp, err != nil)
+			atomic.AddInt64(&cc.counters().staleIfError, 1)
+			cc.countServed(cachedResp, true)
+			status.hit = true
+			cc.setCacheStatus(cachedResp, cc.withTTL(status, req, cachedResp))
 			return cachedResp, nil
 		} else {
-			if err != nil || resp.StatusCode != http.StatusOK {
-				cc.log(fmt.Sprintf("[httpcache](%p) evicting entry (reason: request/upstream error) for key %v", req, cacheKey))
-				cc.Cache.Delete(cacheKey)
+			// Permanent redirects replace the entry, once stored below
+			if err != nil || (resp.StatusCode != http.StatusOK && !permanentRedirect(resp.StatusCode)) {
+				cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) evicting entry (reason: request/upstream error) for key %v", req, cacheKey),
+					"key", cacheKey, "outcome", "evict")
+				cc.evictEntry(req.Context(), cacheKey)
+				cc.hook(req, Event{Type: EventEvict, Key: cacheKey})
 			}
 			if err != nil {
-				cc.log(fmt.Sprintf("[httpcache](%p) transport/upstream error. returning nil response (%s)", req, err.Error()))
+				cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) transport/upstream error. returning nil response (%s)", req, err.Error()))
 				return nil, err
 			}
 		}
 	} else {
-		reqCacheControl := parseCacheControl(req.Header)
-		if _, ok := reqCacheControl["only-if-cached"]; ok {
-			cc.log(fmt.Sprintf("[httpcache](%p) non-cacheable or entry error detected with only-if-cached request. returning timeout", req))
-			resp = newGatewayTimeoutResponse(req)
+		if cacheable && !cc.Options.WriteOnly {
+			status.fwd = fwdURIMiss
+		}
+		if seeded := cc.seed(req, cacheable); seeded != nil {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) cache miss. using seed source response", req))
+			resp = seeded
+			status.fwd = ""
+		} else if onlyIfCached(req) {
+			if resp, err = cc.onlyIfCachedMiss(req); err != nil {
+				return nil, err
+			}
+			status.fwd = ""
+		} else if cacheable && req.Method == "GET" && cc.Options.CoalesceRequests {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) cache miss. executing coalesced remote request", req))
+			var shared bool
+			resp, shared, err = cc.flights.do(req.Context(), flightKey(cacheKey, req), func() (*http.Response, error) {
+				return cc.roundTrip(req)
+			})
+			if err != nil {
+				return nil, err
+			}
+			status.fwdStatus = resp.StatusCode
+			if shared {
+				// The caller that executed the request takes care of storing the response, but
+				// every caller gets its own copy of it to transform
+				cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) using response of a concurrent identical request", req))
+				cc.transform(req, resp)
+				status.collapsed = true
+				cc.setCacheStatus(resp, status)
+				return resp, nil
+			}
 		} else {
-			cc.log(fmt.Sprintf("[httpcache](%p) non-cacheable or entry error detected. executing remote request", req))
-			resp, err = cc.Transport.RoundTrip(req)
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) non-cacheable or entry error detected. executing remote request", req))
+			resp, err = cc.roundTrip(req)
 			if err != nil {
 				return nil, err
 			}
+			status.fwdStatus = resp.StatusCode
 		}
 	}
 
-	// Prepare and store response if applicable
-	if cacheable && canStore(parseCacheControl(req.Header), parseCacheControl(resp.Header)) {
-		for _, varyKey := range headerAllCommaSepValues(resp.Header, "vary") {
-			varyKey = http.CanonicalHeaderKey(varyKey)
-			fakeHeader := "X-Varied-" + varyKey
-			reqValue := req.Header.Get(varyKey)
-			if reqValue != "" {
-				resp.Header.Set(fakeHeader, reqValue)
-			}
+	if resp != cachedResp {
+		cc.transform(req, resp)
+	}
+
+	// Prepare and store response if applicable. A 304 only reaches here when it answers the
+	// client's own validators, and has no body to store.
+	if cacheable && resp.StatusCode != http.StatusNotModified && cc.storableStatus(resp) && canStore(parseCacheControl(req.Header), parseCacheControl(resp.Header)) &&
+		cc.canShare(req, parseCacheControl(resp.Header)) && !(cc.Options.NoStoreVaryStar && varyStar(resp.Header)) {
+		setVaried(resp, req)
+		if resp != cachedResp {
+			cc.notifySunset(req, resp)
 		}
 		switch req.Method {
 		case "GET":
+			status.stored = true
+			if resp.StatusCode == http.StatusOK {
+				cc.prefetchLinks(req, resp)
+			}
+			if sc, ok := cc.streamingCache(req.Context()); ok {
+				// Tee the body into the cache while the caller reads it
+				if err := cc.streamEntry(req, sc, cacheKey, resp, started); err != nil {
+					cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) cache backend error on stream set for key %v (%v)", req, cacheKey, err))
+				} else {
+					cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) streaming entry (source: teeReadCloser) for key %v", req, cacheKey))
+					cc.hook(req, Event{Type: EventStore, Key: cacheKey, Size: -1})
+				}
+				break
+			}
 			// Delay caching until EOF is reached.
 			resp.Body = &cachingReadCloser{
 				R: resp.Body,
 				OnEOF: func(r io.Reader) {
-					resp := *resp
+					if cc.nearDeadline(req.Context()) {
+						cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) request deadline too close. skipping insert for key %v", req, cacheKey))
+						return
+					}
+					resp := *cc.entryResponse(req, resp)
 					resp.Body = ioutil.NopCloser(r)
+					cc.hashBody(&resp)
 					respBytes, err := httputil.DumpResponse(&resp, true)
 					if err == nil {
-						cc.log(fmt.Sprintf("[httpcache](%p) insert entry (source: cachingReadCloser.OnEOF) for key %v", req, cacheKey))
-						cc.Cache.Set(cacheKey, respBytes, cc.Options.TTL)
+						cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) insert entry (source: cachingReadCloser.OnEOF) for key %v", req, cacheKey),
+							"key", cacheKey, "outcome", "store")
+						if cc.storeEntry(req.Context(), cacheKey, respBytes, started) {
+							cc.storeVariant(req, cacheKey, resp.Header, respBytes, started)
+							cc.hook(req, Event{Type: EventStore, Key: cacheKey, Size: len(respBytes)})
+						}
 					}
 				},
 			}
 		default:
-			respBytes, err := httputil.DumpResponse(resp, true)
+			if cc.nearDeadline(req.Context()) {
+				cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) request deadline too close. skipping insert for key %v", req, cacheKey))
+				break
+			}
+			stored := cc.entryResponse(req, resp)
+			respBytes, err := httputil.DumpResponse(stored, true)
+			// The body is read by DumpResponse, and replaced with a copy in the stored response only
+			resp.Body = stored.Body
 			if err == nil {
-				cc.log(fmt.Sprintf("[httpcache](%p) insert entry (source: DumpResponse) for key %v", req, cacheKey))
-				cc.Cache.Set(cacheKey, respBytes, cc.Options.TTL)
+				status.stored = true
+				cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) insert entry (source: DumpResponse) for key %v", req, cacheKey),
+					"key", cacheKey, "outcome", "store")
+				if cc.storeEntry(req.Context(), cacheKey, respBytes, started) {
+					cc.storeVariant(req, cacheKey, stored.Header, respBytes, started)
+					cc.hook(req, Event{Type: EventStore, Key: cacheKey, Size: len(respBytes)})
+				}
 			}
 		}
 	} else {
-		cc.log(fmt.Sprintf("[httpcache](%p) evicting entry (reason: (cacheable && canStore) == false) for key %v", req, cacheKey))
-		cc.Cache.Delete(cacheKey)
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) evicting entry (reason: (cacheable && canStore) == false) for key %v", req, cacheKey),
+			"key", cacheKey, "outcome", "evict")
+		cc.evictEntry(req.Context(), cacheKey)
+		cc.hook(req, Event{Type: EventEvict, Key: cacheKey})
+		cc.invalidateTarget(req, resp)
 	}
 
+	cc.setCacheStatus(resp, status)
 	return resp, nil
 }
 
 // ErrNoDateHeader indicates that the HTTP headers contained no Date header.
 var ErrNoDateHeader = errors.New("no Date header")
 
-// Date parses and returns the value of the Date header.
+// Date parses and returns the value of the Date header, in any of the formats allowed by RFC 7231.
 func Date(respHeaders http.Header) (date time.Time, err error) {
 	dateHeader := respHeaders.Get("date")
 	if dateHeader == "" {
@@ -328,7 +899,23 @@ func Date(respHeaders http.Header) (date time.Time, err error) {
 		return
 	}
 
-	return time.Parse(time.RFC1123, dateHeader)
+	return parseHTTPDate(dateHeader)
+}
+
+// httpDateFormats are the formats of HTTP dates: the preferred IMF-fixdate, and the obsolete RFC 850
+// and asctime ones (RFC 7231 section 7.1.1.1), along with RFC 1123 with a numeric zone, used by some
+// origins
+var httpDateFormats = []string{time.RFC1123, time.RFC1123Z, time.RFC850, time.ANSIC}
+
+// parseHTTPDate parses an HTTP date in any of the httpDateFormats
+func parseHTTPDate(value string) (t time.Time, err error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range httpDateFormats {
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return t, err
 }
 
 type realClock struct{}
@@ -350,34 +937,91 @@ var clock timer = &realClock{}
 // stale indicates that the response needs validating before it is returned
 // transparent indicates the response should not be used to fulfil the request
 //
-// Because this is only a private cache, 'public' and 'private' in cache-control aren't
-// signficant. Similarly, smax-age isn't used.
+// Unless the client is a shared cache (see CacheOptions.SharedCache), 'public' and 'private' in
+// cache-control aren't significant, and s-maxage isn't used.
 func (cc *CachedClient) getFreshness(req *http.Request, respHeaders http.Header) (freshness entryFreshness) {
 	reqHeaders := req.Header
 	respCacheControl := parseCacheControl(respHeaders)
 	reqCacheControl := parseCacheControl(reqHeaders)
 	if _, ok := reqCacheControl["no-cache"]; ok {
-		cc.log(fmt.Sprintf("[httpcache](%p) request no-cache header found. returning transparent freshness", req))
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) request no-cache header found. returning transparent freshness", req))
+		return transparent
+	}
+	if pragmaNoCache(reqHeaders) {
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) request pragma no-cache header found. returning transparent freshness", req))
 		return transparent
 	}
-	if _, ok := respCacheControl["no-cache"]; ok {
-		cc.log(fmt.Sprintf("[httpcache](%p) response no-cache header found. returning stale freshness", req))
+	// A no-cache directive listing field names only concerns these fields, which aren't stored
+	if fields, ok := respCacheControl["no-cache"]; ok && len(fieldNames(fields)) == 0 {
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) response no-cache header found. returning stale freshness", req))
 		return stale
 	}
 	if _, ok := reqCacheControl["only-if-cached"]; ok {
-		cc.log(fmt.Sprintf("[httpcache](%p) request only-if-cached header found. returning fresh freshness", req))
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) request only-if-cached header found. returning fresh freshness", req))
 		return fresh
 	}
 
-	date, err := Date(respHeaders)
+	date, err := responseDate(respHeaders)
 	if err != nil {
-		cc.log(fmt.Sprintf("[httpcache](%p) response date get error. returning stale freshness (%v)", req, err.Error()))
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) response date get error. returning stale freshness (%v)", req, err.Error()))
 		return stale
 	}
-	currentAge := clock.since(date)
+	currentAge := currentAge(respHeaders, date)
+
+	lifetime := cc.capLifetime(req, cc.lifetime(respHeaders, respCacheControl, date))
 
+	// Request directives bound the age of an acceptable response. max-age is a hard limit that
+	// max-stale doesn't relax, while min-fresh and max-stale shift the expiration time the age
+	// is compared to, in opposite directions.
+	reqDirectives := withContextMaxAge(req.Context(), parseRequestDirectives(reqHeaders))
+	if reqDirectives.hasMaxAge && currentAge >= reqDirectives.maxAge {
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) request max-age exceeded. returning stale freshness (%s >= %s)", req, currentAge, reqDirectives.maxAge))
+		return stale
+	}
+	if cc.mustRevalidate(respCacheControl) {
+		if currentAge >= lifetime {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) response must-revalidate header found on expired entry. returning stale freshness", req))
+			return stale
+		}
+		// Stale responses can't be served, whatever the request accepts
+		reqDirectives.anyStale, reqDirectives.maxStale = false, 0
+	}
+	if reqDirectives.anyStale {
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) request max-stale header found. returning fresh freshness", req))
+		return fresh
+	}
+	currentAge = addDurations(currentAge, reqDirectives.minFresh)
+	lifetime = addDurations(lifetime, reqDirectives.maxStale)
+
+	if lifetime > currentAge {
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) lifetime > currentAge. returning fresh freshness (%s, %s)", req, lifetime, currentAge))
+		return fresh
+	}
+
+	cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) cannot infer freshness. fallback to stale freshness (lifetime: %s <= currentAge: %s)", req, lifetime, currentAge))
+	return stale
+}
+
+// mustRevalidate returns true if a response with the given cache control directives must not be
+// served once stale without being validated with the origin first, even on errors or if the
+// request accepts stale responses. In shared mode, proxy-revalidate and s-maxage have the same effect.
+func (cc *CachedClient) mustRevalidate(respCacheControl cacheControl) bool {
+	if _, ok := respCacheControl["must-revalidate"]; ok {
+		return true
+	}
+	if !cc.Options.SharedCache {
+		return false
+	}
+	_, proxyRevalidate := respCacheControl["proxy-revalidate"]
+	_, sMaxAge := respCacheControl["s-maxage"]
+	return proxyRevalidate || sMaxAge
+}
+
+// freshnessLifetime returns the freshness lifetime of a response generated at date
+func freshnessLifetime(respHeaders http.Header, respCacheControl cacheControl, date time.Time) time.Duration {
 	var lifetime time.Duration
 	var zeroDuration time.Duration
+	var err error
 
 	// If a response includes both an Expires header and a max-age directive,
 	// the max-age directive overrides the Expires header, even if the Expires header is more restrictive.
@@ -389,7 +1033,7 @@ func (cc *CachedClient) getFreshness(req *http.Request, respHeaders http.Header)
 	} else {
 		expiresHeader := respHeaders.Get("Expires")
 		if expiresHeader != "" {
-			expires, err := time.Parse(time.RFC1123, expiresHeader)
+			expires, err := parseHTTPDate(expiresHeader)
 			if err != nil {
 				lifetime = zeroDuration
 			} else {
@@ -397,48 +1041,7 @@ func (cc *CachedClient) getFreshness(req *http.Request, respHeaders http.Header)
 			}
 		}
 	}
-
-	if maxAge, ok := reqCacheControl["max-age"]; ok {
-		// the client is willing to accept a response whose age is no greater than the specified time in seconds
-		lifetime, err = time.ParseDuration(maxAge + "s")
-		if err != nil {
-			lifetime = zeroDuration
-		}
-	}
-	if minfresh, ok := reqCacheControl["min-fresh"]; ok {
-		//  the client wants a response that will still be fresh for at least the specified number of seconds.
-		minfreshDuration, err := time.ParseDuration(minfresh + "s")
-		if err == nil {
-			currentAge = time.Duration(currentAge + minfreshDuration)
-		}
-	}
-
-	if maxstale, ok := reqCacheControl["max-stale"]; ok {
-		// Indicates that the client is willing to accept a response that has exceeded its expiration time.
-		// If max-stale is assigned a value, then the client is willing to accept a response that has exceeded
-		// its expiration time by no more than the specified number of seconds.
-		// If no value is assigned to max-stale, then the client is willing to accept a stale response of any age.
-		//
-		// Responses served only because of a max-stale value are supposed to have a Warning header added to them,
-		// but that seems like a  hassle, and is it actually useful? If so, then there needs to be a different
-		// return-value available here.
-		if maxstale == "" {
-			cc.log(fmt.Sprintf("[httpcache](%p) request max-stale header found. returning fresh freshness", req))
-			return fresh
-		}
-		maxstaleDuration, err := time.ParseDuration(maxstale + "s")
-		if err == nil {
-			currentAge = time.Duration(currentAge - maxstaleDuration)
-		}
-	}
-
-	if lifetime > currentAge {
-		cc.log(fmt.Sprintf("[httpcache](%p) lifetime > currentAge. returning fresh freshness (%s, %s)", req, lifetime, currentAge))
-		return fresh
-	}
-
-	cc.log(fmt.Sprintf("[httpcache](%p) cannot infer freshness. fallback to stale freshness (lifetime: %s <= currentAge: %s)", req, lifetime, currentAge))
-	return stale
+	return lifetime
 }
 
 // Returns true if either the request or the response includes the stale-if-error
@@ -472,12 +1075,11 @@ func canStaleOnError(respHeaders, reqHeaders http.Header) bool {
 	}
 
 	if lifetime >= 0 {
-		date, err := Date(respHeaders)
+		date, err := responseDate(respHeaders)
 		if err != nil {
 			return false
 		}
-		currentAge := clock.since(date)
-		if lifetime > currentAge {
+		if lifetime > currentAge(respHeaders, date) {
 			return true
 		}
 	}
@@ -523,6 +1125,65 @@ func canStore(reqCacheControl, respCacheControl cacheControl) (canStore bool) {
 	return true
 }
 
+// unstorableFields returns the names of the header fields that mustn't be stored along with a
+// response with the given cache control directives: the ones listed by a qualified no-cache
+// directive, and in shared mode by a qualified private directive
+func (cc *CachedClient) unstorableFields(respCacheControl cacheControl) []string {
+	fields := fieldNames(respCacheControl["no-cache"])
+	if cc.Options.SharedCache {
+		fields = append(fields, fieldNames(respCacheControl["private"])...)
+	}
+	return fields
+}
+
+// storedResponse returns a shallow copy of resp to be written to the cache, with its own header
+// stamped with the times of its exchange with the origin, and without the fields that mustn't be
+// stored. The header of resp is left untouched, so that metadata is only recorded in the cache.
+func (cc *CachedClient) storedResponse(resp *http.Response) *http.Response {
+	stored := *resp
+	stored.Header = make(http.Header, len(resp.Header)+2)
+	for k, v := range resp.Header {
+		stored.Header[k] = v
+	}
+	for _, field := range cc.unstorableFields(parseCacheControl(resp.Header)) {
+		stored.Header.Del(field)
+	}
+	stampExchange(&stored, resp)
+	return &stored
+}
+
+// entryResponse returns the copy of resp, a response to req, to be written to the cache, marked with
+// the time it was received at and the lifetime assigned to it, if any
+func (cc *CachedClient) entryResponse(req *http.Request, resp *http.Response) *http.Response {
+	stored := cc.storedResponse(resp)
+	markReceived(stored)
+	cc.markHeuristicLifetime(stored)
+	cc.markLifetime(req, stored)
+	return stored
+}
+
+// onlyIfCached returns true if req carries the only-if-cached directive, and must not be forwarded
+// to the origin
+func onlyIfCached(req *http.Request) bool {
+	_, ok := parseCacheControl(req.Header)["only-if-cached"]
+	return ok
+}
+
+// onlyIfCachedMiss returns the answer to req, an only-if-cached request the cache can't satisfy: a
+// 504 Gateway Timeout response, or ErrNoCachedEntry if Options.OnlyIfCachedError is set
+func (cc *CachedClient) onlyIfCachedMiss(req *http.Request) (*http.Response, error) {
+	if cc.Options.OnlyIfCachedError {
+		cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) non-cacheable or entry error detected with only-if-cached request. returning error", req))
+		return nil, ErrNoCachedEntry
+	}
+	cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) non-cacheable or entry error detected with only-if-cached request. returning timeout", req))
+	return newGatewayTimeoutResponse(req), nil
+}
+
+// ErrNoCachedEntry is returned for requests with the only-if-cached directive that can't be answered
+// from the cache, if CacheOptions.OnlyIfCachedError is set
+var ErrNoCachedEntry = errors.New("httpcache: no cached entry for only-if-cached request")
+
 func newGatewayTimeoutResponse(req *http.Request) *http.Response {
 	var braw bytes.Buffer
 	braw.WriteString("HTTP/1.1 504 Gateway Timeout\r\n\r\n")
@@ -550,24 +1211,26 @@ func cloneRequest(r *http.Request) *http.Request {
 
 type cacheControl map[string]string
 
+// parseCacheControl returns the Cache-Control directives of headers. If a directive is repeated,
+// its first occurrence is used.
 func parseCacheControl(headers http.Header) cacheControl {
 	cc := cacheControl{}
-	ccHeader := headers.Get("Cache-Control")
-	for _, part := range strings.Split(ccHeader, ",") {
-		part = strings.Trim(part, " ")
-		if part == "" {
-			continue
-		}
-		if strings.ContainsRune(part, '=') {
-			keyval := strings.Split(part, "=")
-			cc[strings.Trim(keyval[0], " ")] = strings.Trim(keyval[1], ",")
-		} else {
-			cc[part] = ""
+	for _, d := range parseDirectives(headers) {
+		if _, ok := cc[d.name]; !ok {
+			cc[d.name] = d.value
 		}
 	}
 	return cc
 }
 
+// addDurations returns a+b, saturating instead of overflowing
+func addDurations(a, b time.Duration) time.Duration {
+	if b > 0 && a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
 // headerAllCommaSepValues returns all comma-separated values (each
 // with whitespace trimmed) for header name in headers. According to
 // Section 4.2 of the HTTP/1.1 spec
@@ -2,7 +2,9 @@ package httpcache
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"flag"
 	"io"
 	"io/ioutil"
@@ -473,6 +475,37 @@ func TestGetOnlyIfCachedMiss(t *testing.T) {
 	}
 }
 
+func TestGetOnlyIfCachedError(t *testing.T) {
+	resetTest()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+		Options: CacheOptions{MarkCachedResponses: true, OnlyIfCachedError: true}}
+	req, err := http.NewRequest("GET", s.server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("cache-control", "only-if-cached")
+	resp, err := client.Do(req)
+	if err != ErrNoCachedEntry {
+		t.Fatalf("got %v, %v, want ErrNoCachedEntry", resp, err)
+	}
+
+	// Entries in the cache are still served
+	req.Header.Del("cache-control")
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	req.Header.Add("cache-control", "only-if-cached")
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatalf(`XFromCache header isn't "1": %v`, resp.Header.Get(XFromCache))
+	}
+}
+
 func TestGetNoStoreRequest(t *testing.T) {
 	resetTest()
 	req, err := http.NewRequest("GET", s.server.URL, nil)
@@ -961,6 +994,34 @@ func TestNoCacheRequestExpiration(t *testing.T) {
 	}
 }
 
+func TestPragmaNoCacheRequestExpiration(t *testing.T) {
+	resetTest()
+	respHeaders := http.Header{}
+	respHeaders.Set("Cache-Control", "max-age=7200")
+	respHeaders.Set("Date", time.Now().UTC().Format(time.RFC1123))
+	cc := CachedClient{}
+
+	for _, tc := range []struct {
+		name      string
+		headers   map[string]string
+		freshness entryFreshness
+	}{
+		{"pragma", map[string]string{"Pragma": "no-cache"}, transparent},
+		{"pragma case", map[string]string{"Pragma": "No-Cache"}, transparent},
+		{"other pragma", map[string]string{"Pragma": "x-debug"}, fresh},
+		{"cache-control", map[string]string{"Pragma": "no-cache", "Cache-Control": "max-stale"}, fresh},
+	} {
+		reqHeaders := http.Header{}
+		for k, v := range tc.headers {
+			reqHeaders.Set(k, v)
+		}
+		req := &http.Request{Header: reqHeaders}
+		if got := cc.getFreshness(req, respHeaders); got != tc.freshness {
+			t.Fatalf("%s: got freshness %v, want %v", tc.name, got, tc.freshness)
+		}
+	}
+}
+
 func TestNoCacheResponseExpiration(t *testing.T) {
 	resetTest()
 	respHeaders := http.Header{}
@@ -1519,3 +1580,279 @@ func TestClientTimeout(t *testing.T) {
 		t.Error("client.Do took 2+ seconds, want < 2 seconds")
 	}
 }
+
+func TestWriteOnly(t *testing.T) {
+	resetTest()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte(strconv.Itoa(calls)))
+	}))
+	defer server.Close()
+
+	cache := NewMemoryCache()
+	warmer := &CachedClient{Cache: cache, Options: CacheOptions{WriteOnly: true}, Transport: &http.Transport{}}
+	reader := &CachedClient{Cache: cache, Options: CacheOptions{MarkCachedResponses: true}, Transport: &http.Transport{}}
+
+	for i := 1; i <= 2; i++ {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := warmer.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(body), strconv.Itoa(i); got != want {
+			t.Fatalf("got body %q, want %q", got, want)
+		}
+		if resp.Header.Get(XFromCache) != "" {
+			t.Fatal("write-only client served a response from the cache")
+		}
+	}
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := reader.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("entry stored by the write-only client wasn't served from the cache")
+	}
+	if string(body) != "2" {
+		t.Fatalf("got body %q, want the latest warmed response", body)
+	}
+}
+
+func TestGeneration(t *testing.T) {
+	resetTest()
+	cache := NewMemoryCache()
+	newClient := func(generation string) *CachedClient {
+		return &CachedClient{
+			Cache:     cache,
+			Transport: &http.Transport{},
+			Options:   CacheOptions{MarkCachedResponses: true, Generation: generation},
+		}
+	}
+	get := func(client *CachedClient) *http.Response {
+		req, err := http.NewRequest("GET", s.server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	v1, v2 := newClient("v1"), newClient("v2")
+	get(v1)
+	if resp := get(v1); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("entry wasn't served from the cache within its generation")
+	}
+	if resp := get(v2); resp.Header.Get(XFromCache) != "" {
+		t.Fatal("entry from a previous generation was served from the cache")
+	}
+	if resp := get(v2); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("entry wasn't served from the cache within the new generation")
+	}
+	if resp := get(newClient("")); resp.Header.Get(XFromCache) != "" {
+		t.Fatal("generation scoped entry was served to a client without generation")
+	}
+}
+
+func TestStoreDeadlineMargin(t *testing.T) {
+	resetTest()
+	cache := NewMemoryCache()
+	client := &CachedClient{
+		Cache:     cache,
+		Transport: &http.Transport{},
+		Options:   CacheOptions{StoreDeadlineMargin: time.Minute},
+	}
+
+	for _, tc := range []struct {
+		timeout time.Duration
+		stored  bool
+	}{
+		{time.Second, false},
+		{time.Hour, true},
+	} {
+		cache.Delete(s.server.URL)
+		ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+		req, err := http.NewRequest("GET", s.server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		if _, ok := cache.Get(s.server.URL); ok != tc.stored {
+			t.Fatalf("timeout %v: got stored %v, want %v", tc.timeout, ok, tc.stored)
+		}
+	}
+}
+
+func TestMustRevalidate(t *testing.T) {
+	resetTest()
+	date := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	entry := func(cacheControl string) []byte {
+		return []byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nDate: %s\r\nCache-Control: %s\r\n\r\ncached",
+			date.Format(time.RFC1123), cacheControl))
+	}
+
+	for _, tc := range []struct {
+		name         string
+		entry        []byte
+		cacheControl string
+		cached       bool
+	}{
+		{"max-stale", entry("max-age=60"), "max-stale", true},
+		{"max-stale must-revalidate", entry("max-age=60, must-revalidate"), "max-stale", false},
+		{"max-stale=7200 must-revalidate", entry("max-age=60, must-revalidate"), "max-stale=7200", false},
+		{"fresh must-revalidate", entry("max-age=7200, must-revalidate"), "max-stale", true},
+		{"stale-if-error", entry("max-age=60, stale-if-error"), "", true},
+		{"stale-if-error must-revalidate", entry("max-age=60, stale-if-error, must-revalidate"), "", false},
+	} {
+		cache := NewMemoryCache()
+		client := &CachedClient{
+			Cache:     cache,
+			Transport: transportMock{err: errors.New("origin down")},
+		}
+		req, _ := http.NewRequest("GET", "http://example.com/data", nil)
+		if tc.cacheControl != "" {
+			req.Header.Set("Cache-Control", tc.cacheControl)
+		}
+		cache.Set(cacheKey(req), tc.entry, 0)
+
+		resp, err := client.Do(req)
+		if cached := err == nil; cached != tc.cached {
+			t.Fatalf("%s: got served from cache %v (err: %v), want %v", tc.name, cached, err, tc.cached)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestDateFormats(t *testing.T) {
+	want := time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC)
+	for _, value := range []string{
+		"Sun, 06 Nov 1994 08:49:37 GMT",
+		"Sunday, 06-Nov-94 08:49:37 GMT",
+		"Sun Nov  6 08:49:37 1994",
+		"Sun, 06 Nov 1994 08:49:37 +0000",
+		" Sun, 06 Nov 1994 08:49:37 GMT ",
+	} {
+		date, err := Date(http.Header{"Date": []string{value}})
+		if err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		if !date.Equal(want) {
+			t.Fatalf("%q: got %v, want %v", value, date, want)
+		}
+	}
+	if _, err := Date(http.Header{"Date": []string{"yesterday"}}); err == nil {
+		t.Fatal("got no error for an invalid date")
+	}
+
+	respHeaders := http.Header{}
+	respHeaders.Set("Expires", "Sunday, 06-Nov-94 08:50:37 GMT")
+	if got := freshnessLifetime(respHeaders, cacheControl{}, want); got != time.Minute {
+		t.Fatalf("got lifetime %v for an RFC 850 Expires, want 1m", got)
+	}
+}
+
+func TestUpstreamAge(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Age", "50")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	do := func() {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	do()
+	clock = &fakeClock{elapsed: 5 * time.Second}
+	do()
+	if requests != 1 {
+		t.Fatalf("got %d origin requests, want the entry served while fresh", requests)
+	}
+	clock = &fakeClock{elapsed: 15 * time.Second}
+	do()
+	if requests != 2 {
+		t.Fatalf("got %d origin requests, want the entry relayed with Age 50 expired after 15s", requests)
+	}
+}
+
+func TestVaryStar(t *testing.T) {
+	resetTest()
+	for _, noStore := range []bool{false, true} {
+		var conditional int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.Header().Set("Vary", "Accept, *")
+			w.Header().Set("Etag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				conditional++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("body"))
+		}))
+		cache := NewMemoryCache()
+		client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{NoStoreVaryStar: noStore}}
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "body" {
+				t.Fatalf("got body %q", body)
+			}
+		}
+		server.Close()
+		if noStore {
+			if len(cache.Keys()) != 0 || conditional != 0 {
+				t.Fatalf("got %d keys and %d conditional requests, want the response not stored", len(cache.Keys()), conditional)
+			}
+			continue
+		}
+		if conditional != 2 {
+			t.Fatalf("got %d conditional requests, want the entry revalidated before each reuse", conditional)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package httpcache
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// indexedKeysKey is the key under which IndexedCache stores its index
+const indexedKeysKey = "httpcache/index/keys"
+
+// IndexedCache wraps a Cache that can't enumerate its keys (such as memcached) and maintains a
+// sidecar index of them on every Set and Delete, so that it implements KeyLister and supports
+// operations like InvalidateCollection.
+//
+// The index is stored in the Index cache, which defaults to the wrapped cache itself. Every write
+// rewrites the whole index, so IndexedCache is meant for caches of moderate size. Index updates
+// made concurrently by several processes may race: keys missing from the index are still served,
+// they just can't be enumerated until set again.
+type IndexedCache struct {
+	Cache Cache
+	Index Cache
+
+	mu sync.Mutex
+}
+
+// NewIndexedCache returns a new IndexedCache wrapping c and storing its index in index, or in c
+// itself if index is nil
+func NewIndexedCache(c Cache, index Cache) *IndexedCache {
+	if index == nil {
+		index = c
+	}
+	return &IndexedCache{Cache: c, Index: index}
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (ic *IndexedCache) Get(key string) (resp []byte, ok bool) {
+	return ic.Cache.Get(key)
+}
+
+// Set saves response resp to the cache with key, adding key to the index
+func (ic *IndexedCache) Set(key string, resp []byte, ttl int) {
+	ic.Cache.Set(key, resp, ttl)
+	ic.update(func(keys map[string]bool) bool {
+		if keys[key] {
+			return false
+		}
+		keys[key] = true
+		return true
+	})
+}
+
+// Delete removes key from the cache and from the index
+func (ic *IndexedCache) Delete(key string) {
+	ic.Cache.Delete(key)
+	ic.update(func(keys map[string]bool) bool {
+		if !keys[key] {
+			return false
+		}
+		delete(keys, key)
+		return true
+	})
+}
+
+// Keys returns the indexed keys, sorted. Keys whose entry expired or was evicted by the wrapped
+// cache are included until Prune is called.
+func (ic *IndexedCache) Keys() []string {
+	ic.mu.Lock()
+	keys := ic.load()
+	ic.mu.Unlock()
+
+	list := make([]string, 0, len(keys))
+	for key := range keys {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Prune removes from the index the keys no longer present in the wrapped cache, and returns
+// their number
+func (ic *IndexedCache) Prune() int {
+	pruned := 0
+	ic.update(func(keys map[string]bool) bool {
+		for key := range keys {
+			if _, ok := ic.Cache.Get(key); !ok {
+				delete(keys, key)
+				pruned++
+			}
+		}
+		return pruned > 0
+	})
+	return pruned
+}
+
+// update applies fn to the index, storing it back if fn returns true
+func (ic *IndexedCache) update(fn func(keys map[string]bool) bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	keys := ic.load()
+	if !fn(keys) {
+		return
+	}
+	list := make([]string, 0, len(keys))
+	for key := range keys {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	ic.Index.Set(indexedKeysKey, []byte(strings.Join(list, "\n")), 0)
+}
+
+func (ic *IndexedCache) load() map[string]bool {
+	keys := map[string]bool{}
+	index, ok := ic.Index.Get(indexedKeysKey)
+	if !ok || len(index) == 0 {
+		return keys
+	}
+	for _, key := range strings.Split(string(index), "\n") {
+		keys[key] = true
+	}
+	return keys
+}
@@ -0,0 +1,67 @@
+package httpcache
+
+import (
+	"context"
+	"testing"
+)
+
+// unlistedCache hides the KeyLister implementation of MemoryCache
+type unlistedCache struct {
+	c *MemoryCache
+}
+
+func (u unlistedCache) Get(key string) ([]byte, bool)        { return u.c.Get(key) }
+func (u unlistedCache) Set(key string, resp []byte, ttl int) { u.c.Set(key, resp, ttl) }
+func (u unlistedCache) Delete(key string)                    { u.c.Delete(key) }
+
+func TestIndexedCache(t *testing.T) {
+	backend := unlistedCache{NewMemoryCache()}
+	ic := NewIndexedCache(backend, nil)
+	ic.Set("http://example.com/b", []byte("b"), 0)
+	ic.Set("http://example.com/a", []byte("a"), 0)
+	ic.Set("http://example.com/a", []byte("a2"), 0)
+	ic.Set("http://example.com/c", []byte("c"), 0)
+	ic.Delete("http://example.com/c")
+
+	keys := ic.Keys()
+	if len(keys) != 2 || keys[0] != "http://example.com/a" || keys[1] != "http://example.com/b" {
+		t.Fatalf("got keys %v, want [http://example.com/a http://example.com/b]", keys)
+	}
+
+	// The index survives a new wrapper, as it would a process restart
+	backend.Delete("http://example.com/b")
+	ic = NewIndexedCache(backend, nil)
+	if pruned := ic.Prune(); pruned != 1 {
+		t.Fatalf("pruned %d keys, want 1", pruned)
+	}
+	if keys := ic.Keys(); len(keys) != 1 || keys[0] != "http://example.com/a" {
+		t.Fatalf("got keys %v after pruning, want [http://example.com/a]", keys)
+	}
+}
+
+func TestIndexedCacheSeparateIndex(t *testing.T) {
+	backend, index := NewMemoryCache(), NewMemoryCache()
+	ic := NewIndexedCache(unlistedCache{backend}, index)
+	ic.Set("key", []byte("value"), 0)
+	if _, ok := backend.Get(indexedKeysKey); ok {
+		t.Fatal("index stored in the wrapped cache")
+	}
+	if _, ok := index.Get(indexedKeysKey); !ok {
+		t.Fatal("index not stored in the index cache")
+	}
+}
+
+func TestIndexedCacheInvalidateCollection(t *testing.T) {
+	client := &CachedClient{Cache: NewIndexedCache(unlistedCache{NewMemoryCache()}, nil)}
+	client.Cache.Set("http://example.com/items?page=1", []byte("1"), 0)
+	client.Cache.Set("http://example.com/items?page=2", []byte("2"), 0)
+	client.Cache.Set("http://example.com/other", []byte("3"), 0)
+
+	removed, err := client.InvalidateCollection(context.Background(), "http://example.com/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Fatalf("removed %d keys, want 2", removed)
+	}
+}
@@ -0,0 +1,119 @@
+package httpcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives the measurements of an InstrumentedCache. Implementations must be safe for
+// concurrent use; they usually feed a metrics library.
+type Metrics interface {
+	// ObserveGet is called after every Get, with the size of the returned entry if hit
+	ObserveGet(hit bool, size int, latency time.Duration)
+	// ObserveSet is called after every Set, with the size of the stored entry
+	ObserveSet(size int, latency time.Duration)
+	// ObserveDelete is called after every Delete
+	ObserveDelete(latency time.Duration)
+}
+
+// InstrumentedCache is a Cache wrapper reporting the operations on the wrapped cache to a Metrics
+type InstrumentedCache struct {
+	inner   Cache
+	metrics Metrics
+}
+
+// NewInstrumentedCache returns a new InstrumentedCache reporting the operations on inner to metrics
+func NewInstrumentedCache(inner Cache, metrics Metrics) *InstrumentedCache {
+	return &InstrumentedCache{inner: inner, metrics: metrics}
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *InstrumentedCache) Get(key string) (resp []byte, ok bool) {
+	start := time.Now()
+	resp, ok = c.inner.Get(key)
+	c.metrics.ObserveGet(ok, len(resp), time.Since(start))
+	return resp, ok
+}
+
+// Set saves response resp to the cache with key
+func (c *InstrumentedCache) Set(key string, resp []byte, ttl int) {
+	start := time.Now()
+	c.inner.Set(key, resp, ttl)
+	c.metrics.ObserveSet(len(resp), time.Since(start))
+}
+
+// Delete removes key from the cache
+func (c *InstrumentedCache) Delete(key string) {
+	start := time.Now()
+	c.inner.Delete(key)
+	c.metrics.ObserveDelete(time.Since(start))
+}
+
+// Keys returns the keys of the wrapped cache, if it implements KeyLister
+func (c *InstrumentedCache) Keys() []string {
+	if kl, ok := c.inner.(KeyLister); ok {
+		return kl.Keys()
+	}
+	return nil
+}
+
+// CounterMetrics is a Metrics keeping running totals, for use without a metrics library. Use
+// Snapshot to read them.
+type CounterMetrics struct {
+	counters CounterSnapshot
+}
+
+// CounterSnapshot holds the totals of a CounterMetrics
+type CounterSnapshot struct {
+	Gets, Hits, Sets, Deletes int64
+	// BytesRead and BytesWritten are the total sizes of the entries returned by hits and stored
+	BytesRead, BytesWritten int64
+	// GetTime, SetTime and DeleteTime are the total durations of the operations
+	GetTime, SetTime, DeleteTime time.Duration
+}
+
+// HitRatio returns the share of Get calls that hit
+func (s CounterSnapshot) HitRatio() float64 {
+	if s.Gets == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Gets)
+}
+
+// ObserveGet implements Metrics
+func (m *CounterMetrics) ObserveGet(hit bool, size int, latency time.Duration) {
+	atomic.AddInt64(&m.counters.Gets, 1)
+	if hit {
+		atomic.AddInt64(&m.counters.Hits, 1)
+		atomic.AddInt64(&m.counters.BytesRead, int64(size))
+	}
+	atomic.AddInt64((*int64)(&m.counters.GetTime), int64(latency))
+}
+
+// ObserveSet implements Metrics
+func (m *CounterMetrics) ObserveSet(size int, latency time.Duration) {
+	atomic.AddInt64(&m.counters.Sets, 1)
+	atomic.AddInt64(&m.counters.BytesWritten, int64(size))
+	atomic.AddInt64((*int64)(&m.counters.SetTime), int64(latency))
+}
+
+// ObserveDelete implements Metrics
+func (m *CounterMetrics) ObserveDelete(latency time.Duration) {
+	atomic.AddInt64(&m.counters.Deletes, 1)
+	atomic.AddInt64((*int64)(&m.counters.DeleteTime), int64(latency))
+}
+
+// Snapshot returns the current totals
+func (m *CounterMetrics) Snapshot() CounterSnapshot {
+	return CounterSnapshot{
+		Gets:         atomic.LoadInt64(&m.counters.Gets),
+		Hits:         atomic.LoadInt64(&m.counters.Hits),
+		Sets:         atomic.LoadInt64(&m.counters.Sets),
+		Deletes:      atomic.LoadInt64(&m.counters.Deletes),
+		BytesRead:    atomic.LoadInt64(&m.counters.BytesRead),
+		BytesWritten: atomic.LoadInt64(&m.counters.BytesWritten),
+		GetTime:      time.Duration(atomic.LoadInt64((*int64)(&m.counters.GetTime))),
+		SetTime:      time.Duration(atomic.LoadInt64((*int64)(&m.counters.SetTime))),
+		DeleteTime:   time.Duration(atomic.LoadInt64((*int64)(&m.counters.DeleteTime))),
+	}
+}
@@ -0,0 +1,28 @@
+package httpcache
+
+import "testing"
+
+func TestInstrumentedCache(t *testing.T) {
+	metrics := &CounterMetrics{}
+	c := NewInstrumentedCache(NewMemoryCache(), metrics)
+
+	c.Set("a", []byte("12345"), 0)
+	c.Get("a")
+	c.Get("a")
+	c.Get("missing")
+	c.Delete("a")
+
+	s := metrics.Snapshot()
+	if s.Gets != 3 || s.Hits != 2 || s.Sets != 1 || s.Deletes != 1 {
+		t.Fatalf("got counts %+v, want 3 gets, 2 hits, 1 set and 1 delete", s)
+	}
+	if s.BytesRead != 10 || s.BytesWritten != 5 {
+		t.Fatalf("got %d bytes read and %d written, want 10 and 5", s.BytesRead, s.BytesWritten)
+	}
+	if s.HitRatio() < 0.66 || s.HitRatio() > 0.67 {
+		t.Fatalf("got hit ratio %v, want 2/3", s.HitRatio())
+	}
+	if s.GetTime <= 0 {
+		t.Fatalf("got get time %v, want the latencies summed", s.GetTime)
+	}
+}
@@ -0,0 +1,94 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ErrNotEnumerable is returned by operations needing to list the keys of a cache
+// that doesn't implement KeyLister
+var ErrNotEnumerable = errors.New("httpcache: cache can't enumerate its keys")
+
+// KeyLister is implemented by Cache and CacheV2 backends that can enumerate their keys
+type KeyLister interface {
+	// Keys returns the keys of all the entries currently stored
+	Keys() []string
+}
+
+// keyLister returns the KeyLister of the client backend, if any
+func (cc *CachedClient) keyLister() (KeyLister, bool) {
+	if cc.CacheV2 != nil {
+		kl, ok := cc.CacheV2.(KeyLister)
+		return kl, ok
+	}
+	kl, ok := cc.Cache.(KeyLister)
+	return kl, ok
+}
+
+// IsInternalKey returns true if key holds one of the records the client stores next to cached
+// responses, such as the index of the artifacts derived from a response or the artifacts
+// themselves, rather than a complete response
+func IsInternalKey(key string) bool {
+	return key == tieredAccessKey || strings.HasSuffix(key, derivedIndexSuffix) ||
+		strings.Contains(key, derivedIndexSuffix+":") || strings.HasSuffix(key, variantIndexSuffix) ||
+		strings.HasSuffix(key, partialSuffix)
+}
+
+// KeyURL returns the URL a cache key was built from, whether it belongs to a response
+// (with or without method, generation and partition prefixes) or to an artifact derived from one
+func KeyURL(key string) string {
+	fields := strings.Split(key, " ")
+	for i := len(fields) - 1; i >= 0; i-- {
+		if strings.Contains(fields[i], "://") {
+			return fields[i]
+		}
+	}
+	return fields[0]
+}
+
+// InvalidateCollection removes the cached entry for baseURL along with every cached page or variant
+// of the collection it designates: entries for the same URL with any query string (?page=2, ?cursor=...)
+// and entries for URLs nested below its path (/items/page/2). Artifacts derived from those entries are
+// removed as well.
+//
+// If baseURL belongs to a logical service (see CacheOptions.Service), the entries of the whole
+// service are matched, whatever the mirror host they were fetched from.
+//
+// The backing cache must implement KeyLister, otherwise ErrNotEnumerable is returned.
+// It returns the number of removed keys.
+func (cc *CachedClient) InvalidateCollection(ctx context.Context, baseURL string) (int, error) {
+	kl, ok := cc.keyLister()
+	if !ok {
+		return 0, ErrNotEnumerable
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return 0, err
+	}
+	base = cc.serviceBaseURL(ctx, base)
+	cc.buryCollection(base)
+
+	removed := 0
+	for _, key := range kl.Keys() {
+		if !inCollection(base, key) {
+			continue
+		}
+		cc.invalidateEntry(ctx, key)
+		removed++
+	}
+	return removed, nil
+}
+
+// inCollection returns true if key belongs to the collection designated by base: its URL has the
+// same path as base, or a path nested below it
+func inCollection(base *url.URL, key string) bool {
+	u, err := url.Parse(KeyURL(key))
+	if err != nil || u.Scheme != base.Scheme || u.Host != base.Host {
+		return false
+	}
+	basePath := strings.TrimSuffix(base.Path, "/")
+	path := strings.TrimSuffix(u.Path, "/")
+	return path == basePath || strings.HasPrefix(path, basePath+"/")
+}
@@ -0,0 +1,81 @@
+package httpcache
+
+import (
+	"context"
+	"sort"
+	"testing"
+)
+
+func TestInvalidateCollection(t *testing.T) {
+	cache := NewMemoryCache()
+	keys := []string{
+		"http://example.com/items",
+		"http://example.com/items?page=2",
+		"http://example.com/items/page/3",
+		"HEAD http://example.com/items?page=2",
+		"generation:v2 HEAD http://example.com/items/page/4",
+		"http://example.com/items?page=2" + derivedIndexSuffix + ":index",
+		"http://example.com/itemsother",
+		"http://example.com/other?page=2",
+		"http://other.com/items",
+	}
+	for _, key := range keys {
+		cache.Set(key, []byte("value"), 0)
+	}
+	client := &CachedClient{Cache: cache}
+
+	removed, err := client.InvalidateCollection(context.Background(), "http://example.com/items/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 6 {
+		t.Fatalf("removed %d keys, want 6", removed)
+	}
+	left := cache.Keys()
+	sort.Strings(left)
+	want := []string{"http://example.com/itemsother", "http://example.com/other?page=2", "http://other.com/items"}
+	if len(left) != len(want) {
+		t.Fatalf("keys left: %v, want %v", left, want)
+	}
+	for i := range want {
+		if left[i] != want[i] {
+			t.Fatalf("keys left: %v, want %v", left, want)
+		}
+	}
+}
+
+func TestInvalidateCollectionNotEnumerable(t *testing.T) {
+	client := &CachedClient{CacheV2: &failingCacheV2{}}
+	if _, err := client.InvalidateCollection(context.Background(), "http://example.com/"); err != ErrNotEnumerable {
+		t.Fatalf("got error %v, want ErrNotEnumerable", err)
+	}
+}
+
+func TestKeyURL(t *testing.T) {
+	for key, want := range map[string]string{
+		"http://example.com/a":                               "http://example.com/a",
+		"HEAD http://example.com/a":                          "http://example.com/a",
+		"generation:2 partition:x HEAD http://example.com/a": "http://example.com/a",
+		"http://example.com/a derived:index":                 "http://example.com/a",
+	} {
+		if got := KeyURL(key); got != want {
+			t.Errorf("got URL %q for key %q, want %q", got, key, want)
+		}
+	}
+}
+
+func TestIsInternalKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"http://example.com/a":                      false,
+		"http://example.com/a variant:Accept=x":     false,
+		"http://example.com/a" + derivedIndexSuffix: true,
+		"http://example.com/a derived:index":        true,
+		"http://example.com/a" + variantIndexSuffix: true,
+		"http://example.com/a" + partialSuffix:      true,
+		tieredAccessKey:                             true,
+	} {
+		if got := IsInternalKey(key); got != want {
+			t.Errorf("got %v for key %q, want %v", got, key, want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyRewriter modifies u, a copy of a request URL, before it becomes part of the request cache key
+type KeyRewriter func(u *url.URL)
+
+// rewriteURL returns u as rewritten by the KeyRewriters option, or u itself if there are none
+func (cc *CachedClient) rewriteURL(u *url.URL) *url.URL {
+	if len(cc.Options.KeyRewriters) == 0 || u == nil {
+		return u
+	}
+	rewritten := *u
+	if u.User != nil {
+		user := *u.User
+		rewritten.User = &user
+	}
+	for _, rewrite := range cc.Options.KeyRewriters {
+		rewrite(&rewritten)
+	}
+	return &rewritten
+}
+
+// headersPrefix returns the prefix of the cache key of req holding the values of the request
+// headers listed in the KeyHeaders option, normalized like Vary values, or an empty string if
+// req has none of them
+func (cc *CachedClient) headersPrefix(req *http.Request) string {
+	values := url.Values{}
+	for _, name := range cc.Options.KeyHeaders {
+		name = http.CanonicalHeaderKey(name)
+		if value := cc.varyValue(name, req.Header.Get(name)); value != "" {
+			values.Set(name, value)
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "headers:" + values.Encode() + " "
+}
+
+// SortQuery returns a KeyRewriter sorting the query parameters of URLs by name, so that their
+// order doesn't matter. The values of a repeated parameter keep their order.
+func SortQuery() KeyRewriter {
+	return func(u *url.URL) {
+		if u.RawQuery != "" {
+			u.RawQuery = u.Query().Encode()
+		}
+	}
+}
+
+// DropQueryParams returns a KeyRewriter removing the given query parameters from URLs, such as
+// tracking parameters, cache busters or signatures. A name ending with * removes every parameter
+// starting with the rest of it, like utm_*.
+func DropQueryParams(names ...string) KeyRewriter {
+	return func(u *url.URL) {
+		if u.RawQuery == "" {
+			return
+		}
+		query := u.Query()
+		changed := false
+		for param := range query {
+			for _, name := range names {
+				if param == name || (strings.HasSuffix(name, "*") && strings.HasPrefix(param, strings.TrimSuffix(name, "*"))) {
+					delete(query, param)
+					changed = true
+					break
+				}
+			}
+		}
+		if changed {
+			u.RawQuery = query.Encode()
+		}
+	}
+}
+
+// StripFragment returns a KeyRewriter removing the fragment of URLs, which is never sent to the
+// origin anyway
+func StripFragment() KeyRewriter {
+	return func(u *url.URL) {
+		u.Fragment = ""
+	}
+}
+
+// NormalizeHost returns a KeyRewriter lowercasing the scheme and host of URLs, and removing their
+// port if it is the default one of their scheme, so that HTTP://Example.com:80/x and
+// http://example.com/x share an entry. If toASCII is set, such as idna.Lookup.ToASCII from
+// golang.org/x/net/idna, internationalized host names are converted to their punycode form too.
+// Hosts it fails to convert are kept as is.
+func NormalizeHost(toASCII func(host string) (string, error)) KeyRewriter {
+	return func(u *url.URL) {
+		if u.Host == "" {
+			return
+		}
+		u.Scheme = strings.ToLower(u.Scheme)
+		host, port := strings.ToLower(u.Hostname()), u.Port()
+		if toASCII != nil {
+			if ascii, err := toASCII(host); err == nil {
+				host = ascii
+			}
+		}
+		if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+			port = ""
+		}
+		if strings.Contains(host, ":") {
+			// IPv6 literal
+			host = "[" + host + "]"
+		}
+		if port != "" {
+			host += ":" + port
+		}
+		u.Host = host
+	}
+}
+
+// TimeBucket returns a KeyRewriter rounding down the time values of the given query parameters to
+// a multiple of bucket, so that requests for nearly the same time (a dashboard polling "the last
+// hour" with ?to=<now>, for instance) share a cache entry. Values can be unix timestamps in seconds
+// or milliseconds, RFC 3339 timestamps or dates, and keep their format. Other values are left as is.
+func TimeBucket(bucket time.Duration, params ...string) KeyRewriter {
+	return func(u *url.URL) {
+		if bucket <= 0 || u.RawQuery == "" {
+			return
+		}
+		query := u.Query()
+		changed := false
+		for _, param := range params {
+			values, ok := query[param]
+			if !ok {
+				continue
+			}
+			for i, v := range values {
+				values[i] = bucketTime(v, bucket)
+			}
+			changed = true
+		}
+		if changed {
+			u.RawQuery = query.Encode()
+		}
+	}
+}
+
+// bucketTime rounds the time value v down to a multiple of bucket, keeping its format
+func bucketTime(v string, bucket time.Duration) string {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		unit := int64(time.Second)
+		if len(v) >= 13 {
+			unit = int64(time.Millisecond)
+		}
+		step := int64(bucket) / unit
+		if step <= 1 {
+			return v
+		}
+		return strconv.FormatInt(n-mod(n, step), 10)
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			if layout == time.RFC3339Nano {
+				layout = time.RFC3339
+			}
+			// Round in the value own time zone, so that daily buckets start at its midnight
+			_, offset := t.Zone()
+			shift := time.Duration(offset) * time.Second
+			return t.Add(shift).Truncate(bucket).Add(-shift).Format(layout)
+		}
+	}
+	return v
+}
+
+// mod returns the non-negative remainder of n divided by d
+func mod(n, d int64) int64 {
+	if r := n % d; r < 0 {
+		return r + d
+	}
+	return n % d
+}
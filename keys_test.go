@@ -0,0 +1,147 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTimeBucket(t *testing.T) {
+	rewrite := TimeBucket(time.Hour, "t", "from")
+	for _, tc := range []struct {
+		query, want string
+	}{
+		{"t=1700003599", "t=1700002800"},
+		{"t=1700003599123", "t=1700002800000"},
+		{"from=2023-11-14T22:59:59Z&x=1", "from=2023-11-14T22%3A00%3A00Z&x=1"},
+		{"from=2023-11-14T22:59:59.5%2B05:30", "from=2023-11-14T22%3A00%3A00%2B05%3A30"},
+		{"from=2023-11-14", "from=2023-11-14"},
+		{"t=now", "t=now"},
+		{"other=1700003599", "other=1700003599"},
+	} {
+		u, err := url.Parse("http://example.com/stats?" + tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rewrite(u)
+		if u.RawQuery != tc.want {
+			t.Fatalf("%s: got query %q, want %q", tc.query, u.RawQuery, tc.want)
+		}
+	}
+
+	u, _ := url.Parse("http://example.com/stats?from=2023-11-16&to=2023-11-19%2B01:00")
+	TimeBucket(7*24*time.Hour, "from")(u)
+	if want := "from=2023-11-13&to=2023-11-19%2B01%3A00"; u.RawQuery != want {
+		t.Fatalf("got query %q, want %q", u.RawQuery, want)
+	}
+}
+
+func TestKeyRewriters(t *testing.T) {
+	client := &CachedClient{
+		Cache:   NewMemoryCache(),
+		Options: CacheOptions{KeyRewriters: []KeyRewriter{TimeBucket(time.Minute, "t")}},
+	}
+	first, _ := http.NewRequest("GET", "http://example.com/stats?t=1700000041", nil)
+	second, _ := http.NewRequest("GET", "http://example.com/stats?t=1700000099", nil)
+	if client.cacheKey(first) != client.cacheKey(second) {
+		t.Fatalf("got keys %q and %q, want a shared key", client.cacheKey(first), client.cacheKey(second))
+	}
+	if first.URL.RawQuery != "t=1700000041" {
+		t.Fatalf("got request query %q, want it unchanged", first.URL.RawQuery)
+	}
+}
+
+func TestQueryNormalization(t *testing.T) {
+	for _, tc := range []struct {
+		rewriter  KeyRewriter
+		url, want string
+	}{
+		{SortQuery(), "http://example.com/?b=2&a=1&b=1", "http://example.com/?a=1&b=2&b=1"},
+		{SortQuery(), "http://example.com/", "http://example.com/"},
+		{DropQueryParams("utm_*", "_ts"), "http://example.com/?q=x&utm_source=a&utm_medium=b&_ts=1", "http://example.com/?q=x"},
+		{DropQueryParams("utm_*"), "http://example.com/?z=1&a=2", "http://example.com/?z=1&a=2"},
+		{DropQueryParams("sig"), "http://example.com/?sig=abc", "http://example.com/"},
+		{StripFragment(), "http://example.com/page?a=1#section", "http://example.com/page?a=1"},
+	} {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.rewriter(u)
+		if u.String() != tc.want {
+			t.Fatalf("%s: got %q, want %q", tc.url, u.String(), tc.want)
+		}
+	}
+
+	client := &CachedClient{
+		Cache:   NewMemoryCache(),
+		Options: CacheOptions{KeyRewriters: []KeyRewriter{DropQueryParams("utm_*"), SortQuery(), StripFragment()}},
+	}
+	first, _ := http.NewRequest("GET", "http://example.com/list?page=2&sort=name&utm_campaign=x", nil)
+	second, _ := http.NewRequest("GET", "http://example.com/list?sort=name&page=2#top", nil)
+	if client.cacheKey(first) != client.cacheKey(second) {
+		t.Fatalf("got keys %q and %q, want a shared key", client.cacheKey(first), client.cacheKey(second))
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	punycode := map[string]string{"bücher.example": "xn--bcher-kva.example"}
+	toASCII := func(host string) (string, error) {
+		if ascii, ok := punycode[host]; ok {
+			return ascii, nil
+		}
+		return host, nil
+	}
+	for _, tc := range []struct {
+		url, want string
+	}{
+		{"HTTP://Example.com:80/x", "http://example.com/x"},
+		{"https://EXAMPLE.com:443/x?A=B", "https://example.com/x?A=B"},
+		{"http://example.com:443/x", "http://example.com:443/x"},
+		{"https://example.com:8443/x", "https://example.com:8443/x"},
+		{"http://[::1]:80/x", "http://[::1]/x"},
+		{"http://[::1]:8080/x", "http://[::1]:8080/x"},
+		{"http://Bücher.example/x", "http://xn--bcher-kva.example/x"},
+		{"/relative", "/relative"},
+	} {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		NormalizeHost(toASCII)(u)
+		if u.String() != tc.want {
+			t.Fatalf("%s: got %q, want %q", tc.url, u.String(), tc.want)
+		}
+	}
+}
+
+func TestKeyHeaders(t *testing.T) {
+	client := &CachedClient{
+		Cache:   NewMemoryCache(),
+		Options: CacheOptions{KeyHeaders: []string{"accept", "X-Tenant"}, NormalizeVary: NormalizeAcceptHeaders},
+	}
+	key := func(headers map[string]string) string {
+		req, _ := http.NewRequest("GET", "http://example.com/items", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return client.cacheKey(req)
+	}
+	if got := key(nil); got != "http://example.com/items" {
+		t.Fatalf("got key %q without headers, want the URL", got)
+	}
+	json := key(map[string]string{"Accept": "application/json", "X-Tenant": "a"})
+	if want := "headers:Accept=application%2Fjson&X-Tenant=a http://example.com/items"; json != want {
+		t.Fatalf("got key %q, want %q", json, want)
+	}
+	if other := key(map[string]string{"Accept": "application/json", "X-Tenant": "b"}); other == json {
+		t.Fatal("got the same key for different tenants")
+	}
+	if xml := key(map[string]string{"Accept": "application/xml", "X-Tenant": "a"}); xml == json {
+		t.Fatal("got the same key for different Accept headers")
+	}
+	if normalized := key(map[string]string{"Accept": "Application/JSON", "X-Tenant": "a"}); normalized != json {
+		t.Fatalf("got key %q, want the normalized Accept to share %q", normalized, json)
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/lggomez/httpcache/v2/leveldbcache
+
+go 1.17
+
+require (
+	github.com/lggomez/httpcache/v2 v2.0.0
+	github.com/syndtr/goleveldb v1.0.0
+)
+
+require github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
+
+replace github.com/lggomez/httpcache/v2 => ../
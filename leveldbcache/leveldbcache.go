@@ -0,0 +1,123 @@
+// Package leveldbcache provides an implementation of httpcache.Cache that stores responses in a
+// LevelDB database, suited to high throughput local persistence. Unlike diskcache, which keeps a
+// file per entry, LevelDB packs entries into compacted sorted tables.
+//
+// It is a separate module so that the main one stays free of dependencies.
+package leveldbcache
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// now returns the current time, and is replaced in tests
+var now = time.Now
+
+// Cache is an implementation of httpcache.Cache backed by a LevelDB database. Every value is
+// prefixed with its expiration time; expired entries are reported as missing and removed lazily
+// or by DeleteExpired.
+//
+// Cache also implements httpcache.KeyLister.
+type Cache struct {
+	db *leveldb.DB
+}
+
+// New opens (creating it if needed) the LevelDB database at path and returns a Cache using it
+func New(path string) (*Cache, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithDB(db), nil
+}
+
+// NewWithDB returns a Cache using the already opened database db
+func NewWithDB(db *leveldb.DB) *Cache {
+	return &Cache{db: db}
+}
+
+// Close closes the underlying database
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *Cache) Get(key string) (resp []byte, ok bool) {
+	v, err := c.db.Get([]byte(key), nil)
+	if err != nil {
+		return nil, false
+	}
+	if expired(v, now()) {
+		c.db.Delete([]byte(key), nil)
+		return nil, false
+	}
+	return v[8:], true
+}
+
+// Set saves response resp to the cache with key, expiring after ttl seconds if positive
+func (c *Cache) Set(key string, resp []byte, ttl int) {
+	var expires int64
+	if ttl > 0 {
+		expires = now().Add(time.Duration(ttl) * time.Second).UnixNano()
+	}
+	v := make([]byte, 8+len(resp))
+	binary.BigEndian.PutUint64(v, uint64(expires))
+	copy(v[8:], resp)
+	c.db.Put([]byte(key), v, nil)
+}
+
+// Delete removes key from the cache
+func (c *Cache) Delete(key string) {
+	c.db.Delete([]byte(key), nil)
+}
+
+// Keys returns the keys of all the unexpired entries in the cache
+func (c *Cache) Keys() []string {
+	var keys []string
+	t := now()
+	it := c.db.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		if !expired(it.Value(), t) {
+			keys = append(keys, string(it.Key()))
+		}
+	}
+	return keys
+}
+
+// DeleteExpired removes all the expired entries from the database and returns their number
+func (c *Cache) DeleteExpired() (int, error) {
+	t := now()
+	batch := new(leveldb.Batch)
+	it := c.db.NewIterator(nil, nil)
+	for it.Next() {
+		if expired(it.Value(), t) {
+			batch.Delete(append([]byte(nil), it.Key()...))
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return 0, err
+	}
+	if err := c.db.Write(batch, nil); err != nil {
+		return 0, err
+	}
+	return batch.Len(), nil
+}
+
+// Compact compacts the whole database, reclaiming the space of deleted and overwritten entries
+func (c *Cache) Compact() error {
+	return c.db.CompactRange(util.Range{})
+}
+
+// expired returns true if the stored value v has expired at t. Malformed values count as expired.
+func expired(v []byte, t time.Time) bool {
+	if len(v) < 8 {
+		return true
+	}
+	expires := int64(binary.BigEndian.Uint64(v))
+	return expires != 0 && expires <= t.UnixNano()
+}
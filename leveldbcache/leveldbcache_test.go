@@ -0,0 +1,67 @@
+package leveldbcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+func newCache(t *testing.T) (*Cache, func()) {
+	dir, err := ioutil.TempDir("", "leveldbcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(filepath.Join(dir, "db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return c, func() {
+		c.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLevelDBCache(t *testing.T) {
+	c, cleanup := newCache(t)
+	defer cleanup()
+	test.Cache(t, c)
+}
+
+func TestExpiry(t *testing.T) {
+	c, cleanup := newCache(t)
+	defer cleanup()
+	defer func() { now = time.Now }()
+
+	c.Set("expiring", []byte("1"), 10)
+	c.Set("other", []byte("2"), 10)
+	c.Set("permanent", []byte("3"), 0)
+	if _, ok := c.Get("expiring"); !ok {
+		t.Fatal("entry expired before its TTL")
+	}
+
+	now = func() time.Time { return time.Now().Add(time.Minute) }
+	if _, ok := c.Get("expiring"); ok {
+		t.Fatal("entry didn't expire after its TTL")
+	}
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "permanent" {
+		t.Fatalf("got keys %v, want [permanent]", keys)
+	}
+	removed, err := c.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("removed %d entries, want 1", removed)
+	}
+	if err := c.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if val, ok := c.Get("permanent"); !ok || string(val) != "3" {
+		t.Fatalf("got %q, %v after compaction, want 3, true", val, ok)
+	}
+}
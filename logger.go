@@ -0,0 +1,36 @@
+package httpcache
+
+import "context"
+
+type debugCtxKey struct{}
+
+// A Logger receives the debug messages of a CachedClient, when CacheOptions.Debug is enabled or
+// the request context was returned by WithDebug. Along with the message, it is given alternating
+// field names and values describing the request being handled, such as its cache key ("key"), the
+// outcome of the lookup ("outcome", the name of a Decision, "evict" or "store") and the freshness
+// of the cached entry ("freshness").
+//
+// *slog.Logger implements it.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+}
+
+// LoggerFunc is an adapter to use an ordinary function as a Logger
+type LoggerFunc func(msg string, keyvals ...interface{})
+
+// Debug calls f(msg, keyvals...)
+func (f LoggerFunc) Debug(msg string, keyvals ...interface{}) {
+	f(msg, keyvals...)
+}
+
+// WithDebug returns a copy of ctx enabling the debug logging of the requests it is used with, as
+// CacheOptions.Debug does for all the requests of a client, to trace a single request in production.
+func WithDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugCtxKey{}, true)
+}
+
+// debugFromContext returns true if ctx was returned by WithDebug
+func debugFromContext(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugCtxKey{}).(bool)
+	return debug
+}
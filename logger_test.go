@@ -0,0 +1,107 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	var fields []map[string]interface{}
+	var messages []string
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}, Options: CacheOptions{
+		Debug: true,
+		Logger: LoggerFunc(func(msg string, keyvals ...interface{}) {
+			if len(keyvals)%2 != 0 {
+				t.Fatalf("got odd key/value list %v", keyvals)
+			}
+			m := map[string]interface{}{}
+			for i := 0; i < len(keyvals); i += 2 {
+				m[keyvals[i].(string)] = keyvals[i+1]
+			}
+			messages = append(messages, msg)
+			fields = append(fields, m)
+		}),
+	}}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	var outcomes []string
+	for i, m := range fields {
+		if strings.HasPrefix(messages[i], "\n") {
+			t.Fatalf("got message %q, want it trimmed", messages[i])
+		}
+		if outcome, ok := m["outcome"]; ok {
+			if m["key"] != server.URL {
+				t.Fatalf("got key %v, want %v", m["key"], server.URL)
+			}
+			outcomes = append(outcomes, outcome.(string))
+		}
+	}
+	want := []string{"miss", "store", "hit"}
+	if strings.Join(outcomes, ",") != strings.Join(want, ",") {
+		t.Fatalf("got outcomes %v, want %v", outcomes, want)
+	}
+	found := false
+	for _, m := range fields {
+		if m["freshness"] == "fresh" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("got no fresh entry logged")
+	}
+}
+
+func TestLoggerNotDebug(t *testing.T) {
+	client := &CachedClient{Options: CacheOptions{Logger: LoggerFunc(func(msg string, keyvals ...interface{}) {
+		t.Fatalf("got message %q with Debug disabled", msg)
+	})}}
+	client.log(context.Background(), "message", "key", "k")
+}
+
+func TestWithDebug(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	var messages int
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}, Options: CacheOptions{
+		Logger: LoggerFunc(func(msg string, keyvals ...interface{}) {
+			messages++
+		}),
+	}}
+	do := func(ctx context.Context) {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	do(context.Background())
+	if messages != 0 {
+		t.Fatalf("got %d messages, want none without debug", messages)
+	}
+	do(WithDebug(context.Background()))
+	if messages == 0 {
+		t.Fatal("got no message for the request with debug enabled")
+	}
+}
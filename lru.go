@@ -0,0 +1,281 @@
+package httpcache
+
+import (
+	"container/list"
+	"net/url"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// LRUCache is an implementation of Cache that stores responses in memory, bounded by
+// a maximum number of entries and a maximum total size in bytes. When either limit is
+// exceeded the least recently used entries are evicted.
+//
+// The limits can be temporarily lowered with ApplyPressure, which allows a service to shrink
+// the cache when the process is close to running out of memory. SetHostQuota additionally bounds
+// the share of the cache any single host can take.
+type LRUCache struct {
+	// OnEvicted, if set, is called with the entries removed to keep the cache within its limits.
+	// It runs with the cache lock held, so it must not call back into the cache.
+	OnEvicted func(key string, value []byte)
+
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	size       int64
+	ll         *list.List
+	items      map[string]*list.Element
+
+	hostMaxEntries int
+	hostMaxBytes   int64
+	hosts          map[string]*lruHost
+
+	pressure      float64
+	pressureUntil time.Time
+}
+
+type lruEntry struct {
+	key    string
+	value  []byte
+	host   string
+	hostEl *list.Element
+}
+
+// lruHost holds the entries of a single host, from the most to the least recently used
+type lruHost struct {
+	ll   *list.List
+	size int64
+}
+
+func (e *lruEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// NewLRUCache returns a new LRUCache holding at most maxEntries entries and maxBytes bytes
+// of keys and values. A limit of zero or less disables that limit.
+func NewLRUCache(maxEntries int, maxBytes int64) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+		hosts:      map[string]*lruHost{},
+		pressure:   1,
+	}
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *LRUCache) Get(key string) (resp []byte, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, hit := c.items[key]; hit {
+		c.ll.MoveToFront(el)
+		e := el.Value.(*lruEntry)
+		c.hosts[e.host].ll.MoveToFront(e.hostEl)
+		return e.value, true
+	}
+	return nil, false
+}
+
+// Set saves response resp to the cache with key, evicting the least recently used entries
+// if the cache goes over its limits
+func (c *LRUCache) Set(key string, resp []byte, ttl int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var host *lruHost
+	if el, hit := c.items[key]; hit {
+		e := el.Value.(*lruEntry)
+		host = c.hosts[e.host]
+		delta := int64(len(resp) - len(e.value))
+		c.size += delta
+		host.size += delta
+		e.value = resp
+		c.ll.MoveToFront(el)
+		host.ll.MoveToFront(e.hostEl)
+	} else {
+		e := &lruEntry{key: key, value: resp, host: keyHost(key)}
+		if host = c.hosts[e.host]; host == nil {
+			host = &lruHost{ll: list.New()}
+			c.hosts[e.host] = host
+		}
+		c.items[key] = c.ll.PushFront(e)
+		e.hostEl = host.ll.PushFront(e)
+		c.size += e.size()
+		host.size += e.size()
+	}
+	c.evictHost(host)
+	c.evict()
+}
+
+// SetHostQuota limits the number of entries and the total size in bytes of keys and values any
+// single host can hold, so a chatty origin can't push the entries of every other host out of the
+// cache. A host going over its quota has its own least recently used entries evicted. A limit of
+// zero or less disables that limit. Entries are attributed to the host of the URL in their key.
+func (c *LRUCache) SetHostQuota(maxEntries int, maxBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hostMaxEntries, c.hostMaxBytes = maxEntries, maxBytes
+	for _, host := range c.hosts {
+		c.evictHost(host)
+	}
+}
+
+// HostUsage returns the number of entries and the total size in bytes of keys and values held
+// for host
+func (c *LRUCache) HostUsage(host string) (entries int, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if h := c.hosts[host]; h != nil {
+		return h.ll.Len(), h.size
+	}
+	return 0, 0
+}
+
+// Delete removes key from the cache
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	if el, hit := c.items[key]; hit {
+		c.remove(el)
+	}
+	c.mu.Unlock()
+}
+
+// Keys returns the keys of all the entries in the cache, from the most to the least recently used
+func (c *LRUCache) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, c.ll.Len())
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*lruEntry).key)
+	}
+	return keys
+}
+
+// Len returns the number of entries in the cache
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Size returns the total size in bytes of the keys and values in the cache
+func (c *LRUCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// ApplyPressure lowers the entry and byte limits of the cache to factor times their
+// configured values for the duration d, evicting entries right away if needed. A factor
+// of 1 or more lifts any pressure currently applied.
+func (c *LRUCache) ApplyPressure(factor float64, d time.Duration) {
+	if factor <= 0 {
+		factor = 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if factor >= 1 {
+		c.pressure = 1
+		c.pressureUntil = time.Time{}
+		return
+	}
+	c.pressure = factor
+	c.pressureUntil = time.Now().Add(d)
+	c.evict()
+}
+
+// MonitorHeap polls the runtime heap statistics every interval and applies pressure to the
+// cache while the allocated heap is above softLimit bytes, halving its budget until the next
+// check. It runs until the returned stop function is called.
+func (c *LRUCache) MonitorHeap(interval time.Duration, softLimit uint64) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > softLimit {
+					c.ApplyPressure(0.5, interval)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// limits returns the current entry and byte limits, taking any applied pressure into account.
+// c.mu must be held.
+func (c *LRUCache) limits() (maxEntries int, maxBytes int64) {
+	if c.pressure < 1 && !c.pressureUntil.IsZero() && time.Now().After(c.pressureUntil) {
+		c.pressure = 1
+		c.pressureUntil = time.Time{}
+	}
+	maxEntries, maxBytes = c.maxEntries, c.maxBytes
+	if c.pressure < 1 {
+		if maxEntries > 0 {
+			maxEntries = int(float64(maxEntries) * c.pressure)
+		}
+		if maxBytes > 0 {
+			maxBytes = int64(float64(maxBytes) * c.pressure)
+		}
+	}
+	return maxEntries, maxBytes
+}
+
+// evict removes least recently used entries until the cache fits its limits.
+// c.mu must be held.
+func (c *LRUCache) evict() {
+	maxEntries, maxBytes := c.limits()
+	for c.ll.Len() > 0 &&
+		((c.maxEntries > 0 && c.ll.Len() > maxEntries) || (c.maxBytes > 0 && c.size > maxBytes)) {
+		e := c.remove(c.ll.Back())
+		if c.OnEvicted != nil {
+			c.OnEvicted(e.key, e.value)
+		}
+	}
+}
+
+// evictHost removes the least recently used entries of host until it fits its quota.
+// c.mu must be held.
+func (c *LRUCache) evictHost(host *lruHost) {
+	for host.ll.Len() > 0 &&
+		((c.hostMaxEntries > 0 && host.ll.Len() > c.hostMaxEntries) || (c.hostMaxBytes > 0 && host.size > c.hostMaxBytes)) {
+		e := host.ll.Back().Value.(*lruEntry)
+		c.remove(c.items[e.key])
+		if c.OnEvicted != nil {
+			c.OnEvicted(e.key, e.value)
+		}
+	}
+}
+
+func (c *LRUCache) remove(el *list.Element) *lruEntry {
+	e := c.ll.Remove(el).(*lruEntry)
+	delete(c.items, e.key)
+	c.size -= e.size()
+	host := c.hosts[e.host]
+	host.ll.Remove(e.hostEl)
+	host.size -= e.size()
+	if host.ll.Len() == 0 {
+		delete(c.hosts, e.host)
+	}
+	return e
+}
+
+// keyHost returns the host of the URL of a cache key, or an empty string if it has none
+func keyHost(key string) string {
+	u, err := url.Parse(KeyURL(key))
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,147 @@
+package httpcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEntryLimit(t *testing.T) {
+	c := NewLRUCache(2, 0)
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+	c.Get("a")
+	c.Set("c", []byte("3"), 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used entry wasn't evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("entry %q was evicted", key)
+		}
+	}
+}
+
+func TestLRUCacheByteLimit(t *testing.T) {
+	c := NewLRUCache(0, 10)
+	c.Set("a", []byte("1234"), 0)
+	c.Set("b", []byte("1234"), 0)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("entry evicted while under the byte limit")
+	}
+	c.Set("c", []byte("1234"), 0)
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("least recently used entry wasn't evicted")
+	}
+	if c.size > 10 {
+		t.Fatalf("cache size is %d, want <= 10", c.size)
+	}
+}
+
+func TestLRUCachePressure(t *testing.T) {
+	c := NewLRUCache(10, 0)
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), []byte("v"), 0)
+	}
+
+	c.ApplyPressure(0.5, time.Hour)
+	if n := c.ll.Len(); n != 5 {
+		t.Fatalf("cache holds %d entries under pressure, want 5", n)
+	}
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), []byte("v"), 0)
+	}
+	if n := c.ll.Len(); n != 5 {
+		t.Fatalf("cache holds %d entries under pressure, want 5", n)
+	}
+
+	c.ApplyPressure(1, 0)
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), []byte("v"), 0)
+	}
+	if n := c.ll.Len(); n != 10 {
+		t.Fatalf("cache holds %d entries after pressure was lifted, want 10", n)
+	}
+}
+
+func TestLRUCachePressureExpires(t *testing.T) {
+	c := NewLRUCache(10, 0)
+	c.ApplyPressure(0.1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), []byte("v"), 0)
+	}
+	if n := c.ll.Len(); n != 10 {
+		t.Fatalf("cache holds %d entries after pressure expired, want 10", n)
+	}
+}
+
+func TestLRUCacheOnEvicted(t *testing.T) {
+	c := NewLRUCache(1, 0)
+	var evicted []string
+	c.OnEvicted = func(key string, value []byte) {
+		evicted = append(evicted, key+"="+string(value))
+	}
+	c.Set("a", []byte("1"), 0)
+	c.Set("b", []byte("2"), 0)
+	c.Delete("b")
+
+	if len(evicted) != 1 || evicted[0] != "a=1" {
+		t.Fatalf("got evictions %v, want [a=1]", evicted)
+	}
+	if c.Len() != 0 || c.Size() != 0 {
+		t.Fatalf("got len %d and size %d, want an empty cache", c.Len(), c.Size())
+	}
+}
+
+func TestLRUCacheHostQuota(t *testing.T) {
+	c := NewLRUCache(10, 0)
+	c.SetHostQuota(3, 0)
+	var evicted []string
+	c.OnEvicted = func(key string, value []byte) {
+		evicted = append(evicted, key)
+	}
+
+	c.Set("https://quiet.example.com/a", []byte("1"), 0)
+	c.Set("HEAD https://quiet.example.com/b", []byte("1"), 0)
+	for i := 0; i < 20; i++ {
+		c.Set("https://crawled.example.com/"+strconv.Itoa(i), []byte("1"), 0)
+		if i == 1 {
+			c.Get("https://crawled.example.com/0")
+		}
+	}
+
+	if entries, _ := c.HostUsage("crawled.example.com"); entries != 3 {
+		t.Fatalf("got %d entries for the crawled host, want its quota of 3", entries)
+	}
+	if entries, _ := c.HostUsage("quiet.example.com"); entries != 2 {
+		t.Fatalf("got %d entries for the quiet host, want both kept", entries)
+	}
+	if evicted[0] != "https://crawled.example.com/1" {
+		t.Fatalf("got %v evicted first, want the least recently used entry of the crawled host", evicted[0])
+	}
+	if c.Len() != 5 {
+		t.Fatalf("got %d entries, want 5", c.Len())
+	}
+}
+
+func TestLRUCacheHostQuotaBytes(t *testing.T) {
+	c := NewLRUCache(0, 0)
+	key := func(i int) string { return "https://example.com/" + strconv.Itoa(i) }
+	for i := 0; i < 4; i++ {
+		c.Set(key(i), make([]byte, 100), 0)
+	}
+	c.SetHostQuota(0, int64(2*(len(key(0))+100)))
+	if entries, size := c.HostUsage("example.com"); entries != 2 || size != int64(2*(len(key(0))+100)) {
+		t.Fatalf("got %d entries of %d bytes, want the 2 most recent ones", entries, size)
+	}
+	if _, ok := c.Get(key(3)); !ok {
+		t.Fatal("most recent entry evicted")
+	}
+	c.Delete(key(3))
+	c.Delete(key(2))
+	if entries, size := c.HostUsage("example.com"); entries != 0 || size != 0 {
+		t.Fatalf("got %d entries of %d bytes after deleting them all", entries, size)
+	}
+}
@@ -0,0 +1,32 @@
+package httpcache
+
+import (
+	"context"
+	"time"
+)
+
+type maxAgeCtxKey struct{}
+
+// WithMaxAcceptableAge returns a copy of ctx with which requests only accept cached responses
+// younger than maxAge, as if they had a Cache-Control max-age request directive. Unlike the
+// directive, it is never sent to the origin.
+func WithMaxAcceptableAge(ctx context.Context, maxAge time.Duration) context.Context {
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	return context.WithValue(ctx, maxAgeCtxKey{}, maxAge)
+}
+
+// MaxAcceptableAgeFromContext returns the maximum acceptable age carried by ctx, if any
+func MaxAcceptableAgeFromContext(ctx context.Context) (time.Duration, bool) {
+	maxAge, ok := ctx.Value(maxAgeCtxKey{}).(time.Duration)
+	return maxAge, ok
+}
+
+// withContextMaxAge returns rd bound by the maximum acceptable age carried by ctx, if lower
+func withContextMaxAge(ctx context.Context, rd requestDirectives) requestDirectives {
+	if maxAge, ok := MaxAcceptableAgeFromContext(ctx); ok && (!rd.hasMaxAge || maxAge < rd.maxAge) {
+		rd.maxAge, rd.hasMaxAge = maxAge, true
+	}
+	return rd
+}
@@ -0,0 +1,63 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxAcceptableAge(t *testing.T) {
+	var forwarded []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = append(forwarded, r.Header.Get("Cache-Control"))
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("origin"))
+	}))
+	defer server.Close()
+
+	cache := NewMemoryCache()
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{StripRequestDirectives: true}}
+	entry := fmt.Sprintf("HTTP/1.1 200 OK\r\nDate: %s\r\nCache-Control: max-age=3600\r\n\r\ncached",
+		time.Now().Add(-10*time.Minute).UTC().Format(http.TimeFormat))
+
+	for _, tc := range []struct {
+		name      string
+		maxAge    time.Duration
+		header    string
+		cached    bool
+		forwarded string
+	}{
+		{"no limit", -1, "", true, ""},
+		{"young enough", time.Hour, "", true, ""},
+		{"too old", time.Minute, "", false, ""},
+		{"stricter header", time.Hour, "max-age=60", false, ""},
+		{"stricter context", time.Minute, "max-age=3600, no-transform", false, "no-transform"},
+	} {
+		forwarded = nil
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache.Set(client.cacheKey(req), []byte(entry), 0)
+		if tc.maxAge >= 0 {
+			req = req.WithContext(WithMaxAcceptableAge(context.Background(), tc.maxAge))
+		}
+		if tc.header != "" {
+			req.Header.Set("Cache-Control", tc.header)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if got := len(forwarded) == 0; got != tc.cached {
+			t.Fatalf("%s: got served from cache %v, want %v", tc.name, got, tc.cached)
+		}
+		if !tc.cached && forwarded[0] != tc.forwarded {
+			t.Fatalf("%s: origin got Cache-Control %q, want %q", tc.name, forwarded[0], tc.forwarded)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package httpcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheTTL(t *testing.T) {
+	resetTest()
+	c := NewMemoryCache()
+	c.Set("expiring", []byte("1"), 10)
+	c.Set("permanent", []byte("2"), 0)
+
+	if _, ok := c.Get("expiring"); !ok {
+		t.Fatal("entry expired before its TTL")
+	}
+
+	clock = &fakeClock{elapsed: 11 * time.Second}
+	if _, ok := c.Get("expiring"); ok {
+		t.Fatal("entry didn't expire after its TTL")
+	}
+	if _, ok := c.Get("permanent"); !ok {
+		t.Fatal("entry without TTL expired")
+	}
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "permanent" {
+		t.Fatalf("got keys %v, want [permanent]", keys)
+	}
+
+	c.DeleteExpired()
+	if len(c.items) != 1 {
+		t.Fatalf("got %d items after DeleteExpired, want 1", len(c.items))
+	}
+}
+
+func TestMemoryCacheJanitor(t *testing.T) {
+	resetTest()
+	c := NewMemoryCache()
+	c.Set("expiring", []byte("1"), 1)
+	clock = &fakeClock{elapsed: 2 * time.Second}
+
+	stop := c.StartJanitor(time.Millisecond)
+	defer stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.RLock()
+		n := len(c.items)
+		c.mu.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("janitor didn't remove the expired entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,191 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// receivedHeader and requestTimeHeader record the time a response was received at, and the time
+// the request it answers was sent at, to compute the age of entries
+const (
+	receivedHeader    = "X-Httpcache-Received"
+	requestTimeHeader = "X-Httpcache-Request-Time"
+)
+
+// EntryMetadata holds the information about a cached response that is derived from its headers
+// rather than being part of the response itself
+type EntryMetadata struct {
+	// Date is the time the response was generated at, as reported by its Date header, or the time
+	// it was received at if it has none. It is zero if neither is known.
+	Date time.Time
+	// Sunset is the time at which the resource is expected to become unresponsive, as announced by
+	// the origin through the Sunset header (RFC 8594). It is zero if none was announced.
+	Sunset time.Time
+	// Deprecated is true if the origin flagged the resource as deprecated through the Deprecation header
+	Deprecated bool
+	// DeprecatedSince is the time of the deprecation, if the origin provided one
+	DeprecatedSince time.Time
+	// Seeded is true if the entry was populated from the SeedSource rather than the origin
+	Seeded bool
+	// BodyHash is the hex encoded hash of the response body, if the client that stored the entry
+	// had HashBodies set
+	BodyHash string
+}
+
+// GetEntryMetadata returns the metadata of resp, usually a response returned from the cache
+func GetEntryMetadata(resp *http.Response) EntryMetadata {
+	var meta EntryMetadata
+	if date, err := responseDate(resp.Header); err == nil {
+		meta.Date = date
+	}
+	if sunset := resp.Header.Get("Sunset"); sunset != "" {
+		if t, err := parseHTTPDate(sunset); err == nil {
+			meta.Sunset = t
+		}
+	}
+	if deprecation := strings.TrimSpace(resp.Header.Get("Deprecation")); deprecation != "" {
+		meta.Deprecated = true
+		if strings.HasPrefix(deprecation, "@") {
+			// Structured field date, as defined by the Deprecation header draft
+			if secs, err := strconv.ParseInt(deprecation[1:], 10, 64); err == nil {
+				meta.DeprecatedSince = time.Unix(secs, 0).UTC()
+			}
+		} else if t, err := parseHTTPDate(deprecation); err == nil {
+			meta.DeprecatedSince = t
+		} else if strings.EqualFold(deprecation, "false") {
+			meta.Deprecated = false
+		}
+	}
+	meta.Seeded = resp.Header.Get(seededHeader) != ""
+	meta.BodyHash = resp.Header.Get(bodyHashHeader)
+	return meta
+}
+
+// responseDate returns the date freshness is computed from for a response: the value of its Date
+// header, or the time it was received at if it has none (RFC 9111 section 4.2.3)
+func responseDate(respHeaders http.Header) (time.Time, error) {
+	date, err := Date(respHeaders)
+	if err != ErrNoDateHeader {
+		return date, err
+	}
+	if received := respHeaders.Get(receivedHeader); received != "" {
+		return parseHTTPDate(received)
+	}
+	return date, err
+}
+
+// exchange holds the times of an exchange with the origin: the time the request was sent at, and
+// the time the response was received at
+type exchange struct {
+	requested, received time.Time
+}
+
+type exchangeCtxKey struct{}
+
+// withExchange records the times of the exchange resp, a response from the origin to req, results
+// from in the context of its Request, so that they are only stamped on its stored copy (see
+// stampExchange)
+func withExchange(resp *http.Response, req *http.Request, requested, received time.Time) {
+	if resp.Request != nil {
+		req = resp.Request
+	}
+	resp.Request = req.WithContext(context.WithValue(req.Context(), exchangeCtxKey{}, exchange{requested, received}))
+}
+
+// stampExchange records in dst, a response about to be stored, the times of the exchange with the
+// origin src results from, if known
+func stampExchange(dst, src *http.Response) {
+	if src.Request == nil {
+		return
+	}
+	if ex, ok := src.Request.Context().Value(exchangeCtxKey{}).(exchange); ok {
+		stampTimes(dst, ex.requested, ex.received)
+	}
+}
+
+// stampTimes records in resp, a response from the origin, the time the request was sent at and the
+// time the response was received at
+func stampTimes(resp *http.Response, requested, received time.Time) {
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Set(requestTimeHeader, requested.UTC().Format(http.TimeFormat))
+	resp.Header.Set(receivedHeader, received.UTC().Format(http.TimeFormat))
+}
+
+// markReceived records the current time in resp, a response about to be stored, if it has neither
+// a Date header nor a receipt time to compute its freshness from, such as a seeded response
+func markReceived(resp *http.Response) {
+	if resp.Header.Get("Date") != "" || resp.Header.Get(receivedHeader) != "" {
+		return
+	}
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Set(receivedHeader, time.Now().UTC().Format(http.TimeFormat))
+}
+
+// currentAge returns the age of a response generated at date, using the corrected initial age
+// algorithm of RFC 9111 section 4.2.3 when the times of its exchange with the origin were recorded,
+// so that latency, upstream Age headers and origin clocks running late are accounted for
+func currentAge(respHeaders http.Header, date time.Time) time.Duration {
+	responseTime, initialAge := initialAge(respHeaders, date)
+	return addDurations(initialAge, clock.since(responseTime))
+}
+
+// initialAge returns the time a response generated at date was received at, and its age at that
+// time, which is at least the one reported by its Age header. Without recorded exchange times, the
+// response is considered received when generated.
+func initialAge(respHeaders http.Header, date time.Time) (time.Time, time.Duration) {
+	ageValue, _ := parseDeltaSeconds(strings.TrimSpace(respHeaders.Get("Age")))
+	responseTime, err := parseHTTPDate(respHeaders.Get(receivedHeader))
+	if err != nil {
+		return date, ageValue
+	}
+	requestTime, err := parseHTTPDate(respHeaders.Get(requestTimeHeader))
+	if err != nil || requestTime.After(responseTime) {
+		requestTime = responseTime
+	}
+	apparentAge := responseTime.Sub(date)
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	correctedAgeValue := addDurations(ageValue, responseTime.Sub(requestTime))
+	if correctedAgeValue > apparentAge {
+		return responseTime, correctedAgeValue
+	}
+	return responseTime, apparentAge
+}
+
+// EntryMetadata returns the metadata of the entry cached for req and true if present, false if not
+func (cc *CachedClient) EntryMetadata(req *http.Request) (EntryMetadata, bool) {
+	cachedResp, err := cachedResponse(cc.backend(req.Context()), cc.cacheKey(req), req)
+	if err != nil {
+		return EntryMetadata{}, false
+	}
+	defer cachedResp.Body.Close()
+	return GetEntryMetadata(cachedResp), true
+}
+
+// sunsetImminent returns true if the resource of resp announced a sunset that falls within the
+// configured SunsetRefreshWindow
+func (cc *CachedClient) sunsetImminent(resp *http.Response) bool {
+	if cc.Options.SunsetRefreshWindow <= 0 {
+		return false
+	}
+	sunset := GetEntryMetadata(resp).Sunset
+	return !sunset.IsZero() && time.Until(sunset) < cc.Options.SunsetRefreshWindow
+}
+
+// notifySunset calls the OnSunset callback if resp announces a sunset or a deprecation
+func (cc *CachedClient) notifySunset(req *http.Request, resp *http.Response) {
+	if cc.Options.OnSunset == nil {
+		return
+	}
+	if meta := GetEntryMetadata(resp); meta.Deprecated || !meta.Sunset.IsZero() {
+		cc.Options.OnSunset(req, meta)
+	}
+}
@@ -0,0 +1,204 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetEntryMetadata(t *testing.T) {
+	sunset := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		deprecation     string
+		deprecated      bool
+		deprecatedSince time.Time
+	}{
+		{"", false, time.Time{}},
+		{"true", true, time.Time{}},
+		{"false", false, time.Time{}},
+		{"@1688169599", true, time.Unix(1688169599, 0).UTC()},
+		{"Sun, 11 Nov 2018 23:59:59 GMT", true, time.Date(2018, 11, 11, 23, 59, 59, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Sunset", sunset.Format(http.TimeFormat))
+		if tt.deprecation != "" {
+			resp.Header.Set("Deprecation", tt.deprecation)
+		}
+		meta := GetEntryMetadata(resp)
+		if !meta.Sunset.Equal(sunset) {
+			t.Errorf("got sunset %v, want %v", meta.Sunset, sunset)
+		}
+		if meta.Deprecated != tt.deprecated || !meta.DeprecatedSince.Equal(tt.deprecatedSince) {
+			t.Errorf("deprecation %q: got %v/%v, want %v/%v",
+				tt.deprecation, meta.Deprecated, meta.DeprecatedSince, tt.deprecated, tt.deprecatedSince)
+		}
+	}
+}
+
+func TestSunsetRefreshAndCallback(t *testing.T) {
+	resetTest()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Sunset", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		w.Write([]byte("sunsetting"))
+	}))
+	defer server.Close()
+
+	var notified []EntryMetadata
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options: CacheOptions{
+			SunsetRefreshWindow: 2 * time.Hour,
+			OnSunset: func(req *http.Request, meta EntryMetadata) {
+				notified = append(notified, meta)
+			},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if calls != 2 {
+		t.Fatalf("origin called %d times, want 2 since the sunset is within the refresh window", calls)
+	}
+	if len(notified) != 2 || notified[0].Sunset.IsZero() {
+		t.Fatalf("got %d sunset notifications, want 2", len(notified))
+	}
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	meta, ok := client.EntryMetadata(req)
+	if !ok || meta.Sunset.IsZero() {
+		t.Fatal("entry metadata doesn't expose the sunset")
+	}
+}
+
+func TestReceivedDate(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header()["Date"] = nil
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	do := func() *http.Response {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	do()
+	resp := do()
+	if requests != 1 {
+		t.Fatalf("got %d origin requests, want the response without Date served from cache", requests)
+	}
+	if meta := GetEntryMetadata(resp); meta.Date.IsZero() || time.Since(meta.Date) > time.Minute {
+		t.Fatalf("got entry date %v, want the time it was received", meta.Date)
+	}
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	do()
+	if requests != 2 {
+		t.Fatalf("got %d origin requests, want the expired entry refreshed", requests)
+	}
+}
+
+func TestStampsOnStoredCopyOnly(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	cache := NewMemoryCache()
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{},
+		Options: CacheOptions{CachePOST: func(req *http.Request) bool { return true }}}
+	for _, method := range []string{"GET", "POST"} {
+		req, _ := http.NewRequest(method, server.URL+"/"+method, nil)
+		resp, err := client.Do(req.WithContext(WithTTL(context.Background(), time.Hour)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		for _, name := range []string{receivedHeader, requestTimeHeader, lifetimeHeader} {
+			if v := resp.Header.Get(name); v != "" {
+				t.Fatalf("%s: got %s %q on the response returned, want none", method, name, v)
+			}
+		}
+	}
+
+	entry, ok := cache.Get(server.URL + "/GET")
+	if !ok {
+		t.Fatal("entry not stored")
+	}
+	stored, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(entry)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{receivedHeader, requestTimeHeader, lifetimeHeader} {
+		if stored.Header.Get(name) == "" {
+			t.Fatalf("got no %s on the stored entry", name)
+		}
+	}
+}
+
+func TestCurrentAge(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	clock = &fakeClock{elapsed: 10 * time.Second}
+	received := time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+	header := func(requested time.Duration, age string) http.Header {
+		h := http.Header{}
+		stampTimes(&http.Response{Header: h}, received.Add(-requested), received)
+		if age != "" {
+			h.Set("Age", age)
+		}
+		return h
+	}
+
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		date   time.Time
+		want   time.Duration
+	}{
+		{"unstamped", http.Header{}, received, 10 * time.Second},
+		{"unstamped upstream age", http.Header{"Age": []string{"30"}}, received, 40 * time.Second},
+		{"in sync", header(0, ""), received, 10 * time.Second},
+		{"origin late", header(0, ""), received.Add(-time.Minute), 70 * time.Second},
+		{"origin early", header(0, ""), received.Add(time.Minute), 10 * time.Second},
+		{"upstream age", header(0, "30"), received, 40 * time.Second},
+		{"upstream age and delay", header(5*time.Second, "30"), received, 45 * time.Second},
+		{"invalid upstream age", header(5*time.Second, "x"), received, 15 * time.Second},
+	} {
+		if got := currentAge(tc.header, tc.date); got != tc.want {
+			t.Fatalf("%s: got age %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
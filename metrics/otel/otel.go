@@ -0,0 +1,114 @@
+// Package otel reports the events of a httpcache.CachedClient as OpenTelemetry metrics.
+//
+// Create an Observer from a Meter and set it as the client Observer:
+//
+//	observer, err := otel.New(meterProvider.Meter("github.com/lggomez/httpcache/v2"))
+//	if err != nil {
+//		return err
+//	}
+//	client := httpcache.NewCachedClient(httpClient, cache, httpcache.CacheOptions{Observer: observer})
+package otel
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/lggomez/httpcache/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Observer is a httpcache.Observer recording the following instruments:
+//
+//   - httpcache.requests: requests by decision (hit, miss, stale or bypass)
+//   - httpcache.hit_ratio: share of the cache lookups that found a fresh entry, since the Observer
+//     was created
+//   - httpcache.entry.age: histogram of the age of the entries found, in seconds
+//   - httpcache.backend.duration: histogram of the cache backend lookup latency, in seconds
+//   - httpcache.upstream.duration: histogram of the origin latency, in seconds, by status code
+//     (0 if the request failed)
+//   - httpcache.stored: size of the entries stored, in bytes, excluding streamed entries
+type Observer struct {
+	requests        metric.Int64Counter
+	entryAge        metric.Float64Histogram
+	backendLatency  metric.Float64Histogram
+	upstreamLatency metric.Float64Histogram
+	stored          metric.Int64Histogram
+
+	lookups, hits int64
+}
+
+// New returns a new Observer creating its instruments with meter
+func New(meter metric.Meter) (*Observer, error) {
+	o := &Observer{}
+	var err error
+	if o.requests, err = meter.Int64Counter("httpcache.requests",
+		metric.WithDescription("Requests handled by the cache, by decision.")); err != nil {
+		return nil, err
+	}
+	if o.entryAge, err = meter.Float64Histogram("httpcache.entry.age", metric.WithUnit("s"),
+		metric.WithDescription("Age of the cached entries found.")); err != nil {
+		return nil, err
+	}
+	if o.backendLatency, err = meter.Float64Histogram("httpcache.backend.duration", metric.WithUnit("s"),
+		metric.WithDescription("Latency of the cache backend lookups.")); err != nil {
+		return nil, err
+	}
+	if o.upstreamLatency, err = meter.Float64Histogram("httpcache.upstream.duration", metric.WithUnit("s"),
+		metric.WithDescription("Latency of the requests sent to the origin, by status code.")); err != nil {
+		return nil, err
+	}
+	if o.stored, err = meter.Int64Histogram("httpcache.stored", metric.WithUnit("By"),
+		metric.WithDescription("Size of the entries stored in the cache.")); err != nil {
+		return nil, err
+	}
+	_, err = meter.Float64ObservableGauge("httpcache.hit_ratio",
+		metric.WithDescription("Share of the cache lookups that found a fresh entry."),
+		metric.WithFloat64Callback(func(ctx context.Context, obs metric.Float64Observer) error {
+			obs.Observe(o.HitRatio())
+			return nil
+		}))
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// HitRatio returns the share of the cache lookups that found a fresh entry, bypassed requests
+// excluded
+func (o *Observer) HitRatio() float64 {
+	lookups := atomic.LoadInt64(&o.lookups)
+	if lookups == 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&o.hits)) / float64(lookups)
+}
+
+// Observe implements httpcache.Observer
+func (o *Observer) Observe(e httpcache.Event) {
+	ctx := context.Background()
+	switch e.Type {
+	case httpcache.EventDecision:
+		o.requests.Add(ctx, 1, metric.WithAttributes(attribute.String("decision", e.Decision.String())))
+		if e.Decision == httpcache.DecisionBypass {
+			return
+		}
+		atomic.AddInt64(&o.lookups, 1)
+		o.backendLatency.Record(ctx, e.Latency.Seconds())
+		switch e.Decision {
+		case httpcache.DecisionHit:
+			atomic.AddInt64(&o.hits, 1)
+			o.entryAge.Record(ctx, e.Age.Seconds())
+		case httpcache.DecisionStale:
+			o.entryAge.Record(ctx, e.Age.Seconds())
+		}
+	case httpcache.EventUpstream:
+		o.upstreamLatency.Record(ctx, e.Latency.Seconds(),
+			metric.WithAttributes(attribute.String("status", strconv.Itoa(e.Status))))
+	case httpcache.EventStore:
+		if e.Size >= 0 {
+			o.stored.Record(ctx, int64(e.Size))
+		}
+	}
+}
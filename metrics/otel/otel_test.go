@@ -0,0 +1,62 @@
+package otel
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lggomez/httpcache/v2"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestObserver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	reader := sdkmetric.NewManualReader()
+	observer, err := New(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := httpcache.NewCachedClient(&http.Client{Transport: &http.Transport{}}, httpcache.NewMemoryCache(),
+		httpcache.CacheOptions{Observer: observer})
+	for i := 0; i < 4; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = m.Data
+		}
+	}
+	ratio, ok := found["httpcache.hit_ratio"].(metricdata.Gauge[float64])
+	if !ok || len(ratio.DataPoints) != 1 || ratio.DataPoints[0].Value != 0.75 {
+		t.Fatalf("got hit ratio %+v, want 0.75", found["httpcache.hit_ratio"])
+	}
+	for _, name := range []string{"httpcache.entry.age", "httpcache.backend.duration", "httpcache.upstream.duration"} {
+		h, ok := found[name].(metricdata.Histogram[float64])
+		if !ok || len(h.DataPoints) == 0 {
+			t.Fatalf("got %s %+v, want a histogram", name, found[name])
+		}
+	}
+	if age := found["httpcache.entry.age"].(metricdata.Histogram[float64]); age.DataPoints[0].Count != 3 {
+		t.Fatalf("got %d entry ages recorded, want one per hit", age.DataPoints[0].Count)
+	}
+}
@@ -0,0 +1,133 @@
+// Package prometheus exposes the events of a httpcache.CachedClient as Prometheus metrics.
+//
+// Create an Observer, register it and set it as the client Observer:
+//
+//	observer := prometheus.New("myapp")
+//	registry.MustRegister(observer)
+//	client := httpcache.NewCachedClient(httpClient, cache, httpcache.CacheOptions{Observer: observer})
+package prometheus
+
+import (
+	"strconv"
+
+	"github.com/lggomez/httpcache/v2"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Observer is a httpcache.Observer and a prometheus.Collector, counting the events of the clients it
+// is set on. The metrics are named with the namespace given to New and the httpcache subsystem:
+//
+//   - requests_total: requests by decision (hit, miss, stale or bypass)
+//   - revalidations_total: stale entries revalidated with the origin, by result (not_modified,
+//     modified or error)
+//   - stale_served_total: stale entries served without being revalidated first
+//   - stored_entries_total and stored_bytes_total: entries stored, and their size when known
+//   - stored_entry_size_bytes: histogram of the size of the stored entries
+//   - evictions_total: entries removed
+//   - upstream_requests_total: requests sent to the origin, by status code (0 if failed)
+//   - upstream_duration_seconds: histogram of the origin latency
+type Observer struct {
+	requests        *prom.CounterVec
+	revalidations   *prom.CounterVec
+	staleServed     prom.Counter
+	storedEntries   prom.Counter
+	storedBytes     prom.Counter
+	entrySize       prom.Histogram
+	evictions       prom.Counter
+	upstream        *prom.CounterVec
+	upstreamLatency prom.Histogram
+}
+
+// New returns a new Observer whose metrics are named within namespace
+func New(namespace string) *Observer {
+	const subsystem = "httpcache"
+	return &Observer{
+		requests: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem, Name: "requests_total",
+			Help: "Requests handled by the cache, by decision.",
+		}, []string{"decision"}),
+		revalidations: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem, Name: "revalidations_total",
+			Help: "Stale entries revalidated with the origin, by result.",
+		}, []string{"result"}),
+		staleServed: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem, Name: "stale_served_total",
+			Help: "Stale entries served without being revalidated first.",
+		}),
+		storedEntries: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem, Name: "stored_entries_total",
+			Help: "Entries stored in the cache.",
+		}),
+		storedBytes: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem, Name: "stored_bytes_total",
+			Help: "Bytes stored in the cache, excluding streamed entries.",
+		}),
+		entrySize: prom.NewHistogram(prom.HistogramOpts{
+			Namespace: namespace, Subsystem: subsystem, Name: "stored_entry_size_bytes",
+			Help:    "Size of the entries stored in the cache, excluding streamed entries.",
+			Buckets: prom.ExponentialBuckets(256, 4, 8),
+		}),
+		evictions: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem, Name: "evictions_total",
+			Help: "Entries removed from the cache.",
+		}),
+		upstream: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem, Name: "upstream_requests_total",
+			Help: "Requests sent to the origin, by status code.",
+		}, []string{"code"}),
+		upstreamLatency: prom.NewHistogram(prom.HistogramOpts{
+			Namespace: namespace, Subsystem: subsystem, Name: "upstream_duration_seconds",
+			Help:    "Latency of the requests sent to the origin.",
+			Buckets: prom.DefBuckets,
+		}),
+	}
+}
+
+func (o *Observer) collectors() []prom.Collector {
+	return []prom.Collector{o.requests, o.revalidations, o.staleServed, o.storedEntries, o.storedBytes,
+		o.entrySize, o.evictions, o.upstream, o.upstreamLatency}
+}
+
+// Describe implements prometheus.Collector
+func (o *Observer) Describe(ch chan<- *prom.Desc) {
+	for _, c := range o.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (o *Observer) Collect(ch chan<- prom.Metric) {
+	for _, c := range o.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// Observe implements httpcache.Observer
+func (o *Observer) Observe(e httpcache.Event) {
+	switch e.Type {
+	case httpcache.EventDecision:
+		o.requests.WithLabelValues(e.Decision.String()).Inc()
+	case httpcache.EventRevalidate:
+		result := "modified"
+		switch e.Status {
+		case 0:
+			result = "error"
+		case 304:
+			result = "not_modified"
+		}
+		o.revalidations.WithLabelValues(result).Inc()
+	case httpcache.EventServeStale:
+		o.staleServed.Inc()
+	case httpcache.EventStore:
+		o.storedEntries.Inc()
+		if e.Size >= 0 {
+			o.storedBytes.Add(float64(e.Size))
+			o.entrySize.Observe(float64(e.Size))
+		}
+	case httpcache.EventEvict:
+		o.evictions.Inc()
+	case httpcache.EventUpstream:
+		o.upstream.WithLabelValues(strconv.Itoa(e.Status)).Inc()
+		o.upstreamLatency.Observe(e.Latency.Seconds())
+	}
+}
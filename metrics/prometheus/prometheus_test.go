@@ -0,0 +1,51 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lggomez/httpcache/v2"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	observer := New("test")
+	registry := prom.NewRegistry()
+	registry.MustRegister(observer)
+	client := httpcache.NewCachedClient(&http.Client{Transport: &http.Transport{}}, httpcache.NewMemoryCache(),
+		httpcache.CacheOptions{Observer: observer})
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	if got := testutil.ToFloat64(observer.requests.WithLabelValues("hit")); got != 2 {
+		t.Fatalf("got %v hits, want 2", got)
+	}
+	if got := testutil.ToFloat64(observer.requests.WithLabelValues("miss")); got != 1 {
+		t.Fatalf("got %v misses, want 1", got)
+	}
+	if got := testutil.ToFloat64(observer.upstream.WithLabelValues("200")); got != 1 {
+		t.Fatalf("got %v upstream requests, want 1", got)
+	}
+	if got := testutil.ToFloat64(observer.storedBytes); got <= float64(len("body")) {
+		t.Fatalf("got %v stored bytes, want the size of the entry", got)
+	}
+	if n, err := testutil.GatherAndCount(registry); err != nil || n == 0 {
+		t.Fatalf("got %d metrics gathered (%v)", n, err)
+	}
+}
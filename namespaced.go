@@ -0,0 +1,61 @@
+package httpcache
+
+import "strings"
+
+// NamespacedCache is a Cache wrapper storing entries in the wrapped cache under keys starting with
+// a prefix, so that several clients or applications can share a backend without key collisions,
+// and each of them can flush its own entries.
+type NamespacedCache struct {
+	inner  Cache
+	prefix string
+}
+
+// NewNamespacedCache returns a new NamespacedCache storing entries in inner with keys prefixed by
+// prefix, which usually ends with a separator such as "app:"
+func NewNamespacedCache(inner Cache, prefix string) *NamespacedCache {
+	return &NamespacedCache{inner: inner, prefix: prefix}
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *NamespacedCache) Get(key string) (resp []byte, ok bool) {
+	return c.inner.Get(c.prefix + key)
+}
+
+// Set saves response resp to the cache with key
+func (c *NamespacedCache) Set(key string, resp []byte, ttl int) {
+	c.inner.Set(c.prefix+key, resp, ttl)
+}
+
+// Delete removes key from the cache
+func (c *NamespacedCache) Delete(key string) {
+	c.inner.Delete(c.prefix + key)
+}
+
+// Keys returns the keys of the namespace, without prefix, if the wrapped cache implements KeyLister
+func (c *NamespacedCache) Keys() []string {
+	kl, ok := c.inner.(KeyLister)
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, key := range kl.Keys() {
+		if strings.HasPrefix(key, c.prefix) {
+			keys = append(keys, key[len(c.prefix):])
+		}
+	}
+	return keys
+}
+
+// Flush removes every entry of the namespace and returns their number. The wrapped cache must
+// implement KeyLister, otherwise ErrNotEnumerable is returned; with backends that can't enumerate
+// their keys, use CacheOptions.Generation to invalidate entries instead.
+func (c *NamespacedCache) Flush() (int, error) {
+	if _, ok := c.inner.(KeyLister); !ok {
+		return 0, ErrNotEnumerable
+	}
+	keys := c.Keys()
+	for _, key := range keys {
+		c.Delete(key)
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,38 @@
+package httpcache
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestNamespacedCache(t *testing.T) {
+	inner := NewMemoryCache()
+	a, b := NewNamespacedCache(inner, "a:"), NewNamespacedCache(inner, "b:")
+
+	a.Set("key", []byte("1"), 0)
+	b.Set("key", []byte("2"), 0)
+	a.Set("other", []byte("3"), 0)
+	if v, _ := a.Get("key"); string(v) != "1" {
+		t.Fatalf("got %q from namespace a, want 1", v)
+	}
+	if v, _ := b.Get("key"); string(v) != "2" {
+		t.Fatalf("got %q from namespace b, want 2", v)
+	}
+	keys := a.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "key" || keys[1] != "other" {
+		t.Fatalf("got keys %v, want [key other]", keys)
+	}
+
+	if n, err := a.Flush(); err != nil || n != 2 {
+		t.Fatalf("got %d flushed (err: %v), want 2", n, err)
+	}
+	if left := inner.Keys(); len(left) != 1 || left[0] != "b:key" {
+		t.Fatalf("got keys %v left, want only the other namespace", left)
+	}
+
+	unlisted := NewNamespacedCache(unlistedCache{inner}, "a:")
+	if _, err := unlisted.Flush(); err != ErrNotEnumerable {
+		t.Fatalf("got error %v, want ErrNotEnumerable", err)
+	}
+}
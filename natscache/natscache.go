@@ -0,0 +1,118 @@
+// Package natscache provides an implementation of httpcache.CacheV2 that stores responses in a NATS
+// JetStream key-value bucket, so that services already running NATS can share a cache without new
+// infrastructure.
+//
+// JetStream removes entries once they are older than the bucket TTL. Entry TTLs shorter than that
+// are stored along the values and checked on every read.
+//
+// It is a separate module so that the main one stays free of dependencies.
+package natscache
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/nats-io/nats.go"
+)
+
+// now returns the current time, and is replaced in tests
+var now = time.Now
+
+// Cache is an implementation of httpcache.CacheV2 backed by a JetStream key-value bucket. Use
+// httpcache.AdaptCacheV2 to use it where a httpcache.Cache is expected.
+//
+// Since bucket keys are restricted to a few characters, cache keys are stored base64 encoded.
+// Operations are bound by the NATS connection timeouts rather than by their context. The size of
+// entries is limited by the maximum message size of the NATS server (1MB by default).
+//
+// Cache also implements httpcache.KeyLister.
+type Cache struct {
+	kv nats.KeyValue
+}
+
+// New returns a new Cache storing entries in the bucket kv
+func New(kv nats.KeyValue) *Cache {
+	return &Cache{kv: kv}
+}
+
+// NewBucket creates (or binds to, if it exists with the same configuration) the bucket named
+// bucket, in which entries expire after ttl if positive, and returns a Cache storing entries in it
+func NewBucket(js nats.JetStreamContext, bucket string, ttl time.Duration) (*Cache, error) {
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: bucket, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+	return New(kv), nil
+}
+
+// Get returns the []byte representation of the response stored with key, or httpcache.ErrCacheMiss
+// if there is none
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	entry, err := c.kv.Get(encodeKey(key))
+	if err == nats.ErrKeyNotFound {
+		return nil, httpcache.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	v := entry.Value()
+	if expired(v, now()) {
+		return nil, httpcache.ErrCacheMiss
+	}
+	return v[8:], nil
+}
+
+// Set stores response resp with key, expiring after ttl seconds if positive
+func (c *Cache) Set(ctx context.Context, key string, resp []byte, ttl int) error {
+	var expires int64
+	if ttl > 0 {
+		expires = now().Add(time.Duration(ttl) * time.Second).UnixNano()
+	}
+	v := make([]byte, 8+len(resp))
+	binary.BigEndian.PutUint64(v, uint64(expires))
+	copy(v[8:], resp)
+	_, err := c.kv.Put(encodeKey(key), v)
+	return err
+}
+
+// Delete removes key from the bucket
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	err := c.kv.Delete(encodeKey(key))
+	if err == nats.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// Keys returns the keys of all the entries in the bucket, including the ones whose entry TTL has
+// passed but that the bucket TTL didn't remove yet. It returns nil if the bucket can't be read.
+func (c *Cache) Keys() []string {
+	encoded, err := c.kv.Keys()
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(encoded))
+	for _, k := range encoded {
+		if key, err := base64.RawURLEncoding.DecodeString(k); err == nil {
+			keys = append(keys, string(key))
+		}
+	}
+	return keys
+}
+
+// encodeKey returns key encoded with characters allowed in bucket keys
+func encodeKey(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// expired returns true if the stored value v has expired at t. Malformed values count as expired.
+func expired(v []byte, t time.Time) bool {
+	if len(v) < 8 {
+		return true
+	}
+	expires := int64(binary.BigEndian.Uint64(v))
+	return expires != 0 && expires <= t.UnixNano()
+}
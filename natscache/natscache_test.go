@@ -0,0 +1,91 @@
+package natscache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/lggomez/httpcache/v2/test"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// startNATS runs a NATS server with JetStream enabled and returns a JetStream context connected to
+// it, along with a function stopping both
+func startNATS(t *testing.T) (nats.JetStreamContext, func()) {
+	dir, err := ioutil.TempDir("", "natscache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: dir})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(10 * time.Second) {
+		s.Shutdown()
+		os.RemoveAll(dir)
+		t.Fatal("NATS server didn't start")
+	}
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		s.Shutdown()
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return js, func() {
+		nc.Close()
+		s.Shutdown()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNATSCache(t *testing.T) {
+	js, stop := startNATS(t)
+	defer stop()
+	c, err := NewBucket(js, "httpcache", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.Cache(t, httpcache.AdaptCacheV2(c))
+
+	ctx := context.Background()
+	c.Set(ctx, "http://example.com/a?x=1", []byte("1"), 0)
+	c.Set(ctx, "GET http://example.com/b", []byte("2"), 0)
+	keys := c.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "GET http://example.com/b" || keys[1] != "http://example.com/a?x=1" {
+		t.Fatalf("got keys %q, want the stored keys", keys)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	js, stop := startNATS(t)
+	defer stop()
+	defer func() { now = time.Now }()
+	ctx := context.Background()
+	c, err := NewBucket(js, "httpcache", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set(ctx, "expiring", []byte("1"), 10)
+	c.Set(ctx, "permanent", []byte("2"), 0)
+	now = func() time.Time { return time.Now().Add(time.Minute) }
+	if _, err := c.Get(ctx, "expiring"); err != httpcache.ErrCacheMiss {
+		t.Fatalf("got error %v after the entry TTL, want ErrCacheMiss", err)
+	}
+	if v, err := c.Get(ctx, "permanent"); err != nil || string(v) != "2" {
+		t.Fatalf("got %q (err: %v), want the entry without TTL", v, err)
+	}
+}
@@ -0,0 +1,103 @@
+package httpcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMaxNegativeLookups is the default number of keys remembered as known misses
+const DefaultMaxNegativeLookups = 10000
+
+// negativeLookups remembers the keys known to have no entry in the backend, so their lookups can
+// be skipped for a while. Every store bumps seq, and a miss is only remembered if no store happened
+// since its lookup started: a miss racing with a store for the same key is never memoized.
+type negativeLookups struct {
+	ttl time.Duration
+	max int
+
+	mu     sync.Mutex
+	seq    uint64
+	misses map[string]time.Time
+}
+
+// negativeLookups returns the known misses of the client, creating them on first use, or nil if
+// Options.NegativeLookupTTL isn't set or ctx carries a scratch cache, whose lookups and deletions
+// say nothing about the client cache. Canary arms share the known misses of their parent, as they
+// share its entries.
+func (cc *CachedClient) negativeLookups(ctx context.Context) *negativeLookups {
+	o := cc.owner()
+	if o.Options.NegativeLookupTTL <= 0 || scratchLayerFromContext(ctx) != nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.misses == nil {
+		max := o.Options.MaxNegativeLookups
+		if max <= 0 {
+			max = DefaultMaxNegativeLookups
+		}
+		o.misses = &negativeLookups{ttl: o.Options.NegativeLookupTTL, max: max, misses: map[string]time.Time{}}
+	}
+	return o.misses
+}
+
+// lookupSeq returns the sequence to pass to rememberMiss once a lookup of the backend completes
+func (n *negativeLookups) lookupSeq() uint64 {
+	if n == nil {
+		return 0
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.seq
+}
+
+// knownMiss returns true if key was remembered as a miss less than ttl ago
+func (n *negativeLookups) knownMiss(key string) bool {
+	if n == nil {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	at, ok := n.misses[key]
+	if ok && clock.since(at) >= n.ttl {
+		delete(n.misses, key)
+		return false
+	}
+	return ok
+}
+
+// rememberMiss remembers key as a miss, unless an entry was stored since seq was taken. Once max
+// keys are remembered, expired ones are dropped and new misses are ignored if none is.
+func (n *negativeLookups) rememberMiss(key string, seq uint64) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.seq != seq {
+		return
+	}
+	if _, ok := n.misses[key]; !ok && len(n.misses) >= n.max {
+		for k, at := range n.misses {
+			if clock.since(at) >= n.ttl {
+				delete(n.misses, k)
+			}
+		}
+		if len(n.misses) >= n.max {
+			return
+		}
+	}
+	n.misses[key] = time.Now()
+}
+
+// forget drops key from the known misses because an entry is being stored for it
+func (n *negativeLookups) forget(key string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.seq++
+	delete(n.misses, key)
+}
@@ -0,0 +1,90 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCache is a CacheV2 counting the lookups reaching it
+type countingCache struct {
+	CacheV2
+	gets int32
+}
+
+func (c *countingCache) Get(ctx context.Context, key string) ([]byte, error) {
+	atomic.AddInt32(&c.gets, 1)
+	return c.CacheV2.Get(ctx, key)
+}
+
+func TestNegativeLookups(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/uncacheable" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	backend := &countingCache{CacheV2: AdaptCache(NewMemoryCache())}
+	client := &CachedClient{
+		CacheV2:   backend,
+		Transport: &http.Transport{},
+		Options:   CacheOptions{NegativeLookupTTL: time.Minute, MarkCachedResponses: true},
+	}
+	get := func(path string) *http.Response {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	get("/uncacheable")
+	get("/uncacheable")
+	if gets := atomic.LoadInt32(&backend.gets); gets != 1 {
+		t.Fatalf("got %d lookups, want the uncacheable key remembered after the first one", gets)
+	}
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	get("/uncacheable")
+	if gets := atomic.LoadInt32(&backend.gets); gets != 2 {
+		t.Fatalf("got %d lookups, want the uncacheable key forgotten once expired", gets)
+	}
+	clock = &realClock{}
+
+	get("/cacheable")
+	if resp := get("/cacheable"); resp.Header.Get(XFromCache) != "1" {
+		t.Fatal("stored entry hidden by a remembered miss")
+	}
+}
+
+func TestNegativeLookupsRacingStore(t *testing.T) {
+	n := &negativeLookups{ttl: time.Minute, max: 2, misses: map[string]time.Time{}}
+	seq := n.lookupSeq()
+	n.forget("a")
+	n.rememberMiss("a", seq)
+	if n.knownMiss("a") {
+		t.Fatal("miss remembered although an entry was stored during its lookup")
+	}
+
+	seq = n.lookupSeq()
+	for _, key := range []string{"a", "b", "c"} {
+		n.rememberMiss(key, seq)
+	}
+	if len(n.misses) != 2 || n.knownMiss("c") {
+		t.Fatalf("got %d misses remembered, want them bounded to 2", len(n.misses))
+	}
+}
@@ -0,0 +1,31 @@
+package httpcache
+
+import (
+	"context"
+	"net"
+	"net/url"
+)
+
+// unreachable returns true if err, returned by the Transport, means the origin couldn't be
+// reached: a DNS resolution, connection or timeout error
+func unreachable(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+			continue
+		case *net.DNSError, *net.OpError:
+			return true
+		case net.Error:
+			return e.Timeout()
+		}
+		return err == context.DeadlineExceeded
+	}
+	return false
+}
+
+// offlineFallback returns true if the entry matching req can be served in place of a response the
+// origin couldn't be reached for, because of err (see CacheOptions.OfflineFallback)
+func (cc *CachedClient) offlineFallback(err error, decision cacheDecision) bool {
+	return cc.Options.OfflineFallback && decision.varyMatches && unreachable(err)
+}
@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestUnreachable(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{&url.Error{Op: "Get", Err: &net.DNSError{Err: "no such host"}}, true},
+		{context.DeadlineExceeded, true},
+		{errors.New("x509: certificate signed by unknown authority"), false},
+		{nil, false},
+	} {
+		if got := unreachable(tc.err); got != tc.want {
+			t.Errorf("unreachable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestOfflineFallback(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	for _, offline := range []bool{false, true} {
+		status := http.StatusOK
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=10, must-revalidate")
+			w.WriteHeader(status)
+			w.Write([]byte("body"))
+		}))
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+			Options: CacheOptions{OfflineFallback: offline}}
+		do := func() (*http.Response, error) {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := client.Do(req)
+			if err == nil {
+				ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			return resp, err
+		}
+
+		clock = &fakeClock{}
+		do()
+		clock = &fakeClock{elapsed: time.Hour}
+		// A reachable origin failing isn't a disconnection
+		status = http.StatusInternalServerError
+		if resp, err := do(); err != nil || resp.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("offline %v: got %v, %v, want the origin error", offline, resp, err)
+		}
+		status = http.StatusOK
+		do()
+		server.Close()
+
+		resp, err := do()
+		if !offline {
+			if err == nil {
+				t.Fatalf("got status %d, want the connection error", resp.StatusCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("got error %v, want the stale entry", err)
+		}
+		if warnings := resp.Header["Warning"]; len(warnings) != 2 || warnings[1] != disconnectedWarning {
+			t.Fatalf("got warnings %q, want the entry labeled as served disconnected", warnings)
+		}
+	}
+}
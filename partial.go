@@ -0,0 +1,188 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partialSuffix is appended to a response cache key to build the key of the partial response
+// assembled for that resource (see CacheOptions.StorePartial)
+const partialSuffix = " partial"
+
+// parseContentRange parses the Content-Range header value of a 206 response, returning the range
+// it holds and the complete length of the representation, or -1 if unknown
+func parseContentRange(value string) (r byteRange, size int64, ok bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "bytes ") {
+		return r, 0, false
+	}
+	spec := strings.TrimSpace(strings.TrimPrefix(value, "bytes "))
+	slash := strings.IndexByte(spec, '/')
+	dash := strings.IndexByte(spec, '-')
+	if slash < 0 || dash < 0 || dash > slash {
+		return r, 0, false
+	}
+	var err error
+	if r.start, err = strconv.ParseInt(spec[:dash], 10, 64); err != nil || r.start < 0 {
+		return r, 0, false
+	}
+	if r.end, err = strconv.ParseInt(spec[dash+1:slash], 10, 64); err != nil || r.end < r.start {
+		return r, 0, false
+	}
+	size = -1
+	if complete := spec[slash+1:]; complete != "*" {
+		if size, err = strconv.ParseInt(complete, 10, 64); err != nil || r.end >= size {
+			return r, 0, false
+		}
+	}
+	return r, size, true
+}
+
+// lookupPartial returns the response to the Range request req built from the partial response
+// stored for key, or nil if there is none that is fresh and holds the whole range
+func (cc *CachedClient) lookupPartial(req *http.Request, key string) *http.Response {
+	if !cc.Options.StorePartial {
+		return nil
+	}
+	stored, err := cachedResponse(cc.backend(req.Context()), key+partialSuffix, req)
+	if err != nil {
+		return nil
+	}
+	defer stored.Body.Close()
+	if !cc.varyMatches(stored, req) || cc.getFreshness(req, stored.Header) != fresh ||
+		!ifRangeMatches(req.Header.Get("If-Range"), stored.Header) {
+		return nil
+	}
+	held, size, ok := parseContentRange(stored.Header.Get("Content-Range"))
+	if !ok || size < 0 {
+		return nil
+	}
+	body, err := ioutil.ReadAll(stored.Body)
+	if err != nil || int64(len(body)) != held.end-held.start+1 {
+		return nil
+	}
+	r, satisfiable, ok := parseRange(req.Header.Get("Range"), size)
+	if !ok || !satisfiable || r.start < held.start || r.end > held.end {
+		return nil
+	}
+	return sliceResponse(stored, body, held.start, r, size)
+}
+
+// storePartialOnEOF arranges for resp, the response to the Range request req, to be stored once
+// its body is read if it is a storable partial response. It returns true if so.
+func (cc *CachedClient) storePartialOnEOF(req *http.Request, key string, resp *http.Response, started time.Time) bool {
+	if !cc.Options.StorePartial || resp.StatusCode != http.StatusPartialContent {
+		return false
+	}
+	respCacheControl := parseCacheControl(resp.Header)
+	if !canStore(parseCacheControl(req.Header), respCacheControl) || !cc.canShare(req, respCacheControl) {
+		return false
+	}
+	if _, _, ok := parseContentRange(resp.Header.Get("Content-Range")); !ok {
+		return false
+	}
+	resp.Body = &cachingReadCloser{
+		R: resp.Body,
+		OnEOF: func(r io.Reader) {
+			body, _ := ioutil.ReadAll(r)
+			cc.storePartial(req, key, resp, body, started)
+		},
+	}
+	return true
+}
+
+// storePartial stores body, the content of resp, a partial response to req for the resource stored
+// under key. If the partial response already stored for it is of the same representation, and
+// adjacent to or overlapping with resp, both are merged. A complete representation is stored as a
+// full response under key.
+func (cc *CachedClient) storePartial(req *http.Request, key string, resp *http.Response, body []byte, started time.Time) {
+	r, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if !ok || int64(len(body)) != r.end-r.start+1 || cc.nearDeadline(req.Context()) {
+		return
+	}
+	ctx := req.Context()
+	pkey := key + partialSuffix
+	unlock := cc.owner().keyLocks.lock(pkey)
+	defer unlock()
+	if cc.buriedSince(key, started) {
+		return
+	}
+	if prev, err := cachedResponse(cc.backend(ctx), pkey, req); err == nil {
+		r, body = mergeSegment(prev, resp.Header, r, size, body)
+	}
+
+	stored := cc.storedResponse(resp)
+	setVaried(stored, req)
+	markReceived(stored)
+	if size >= 0 && r.start == 0 && r.end == size-1 {
+		full := withBody(stored, http.StatusOK, body)
+		full.Header.Del("Content-Range")
+		respBytes, err := httputil.DumpResponse(full, true)
+		if err != nil {
+			return
+		}
+		cc.log(ctx, fmt.Sprintf("[httpcache](%p) insert entry (source: assembled partial responses) for key %v", req, key),
+			"key", key, "outcome", "store")
+		if cc.storeEntry(ctx, key, respBytes, started) {
+			cc.backend(ctx).Delete(ctx, pkey)
+			cc.hook(req, Event{Type: EventStore, Key: key, Size: len(respBytes)})
+		}
+		return
+	}
+	partial := sliceResponse(stored, body, r.start, r, size)
+	if size < 0 {
+		partial.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", r.start, r.end))
+	}
+	respBytes, err := httputil.DumpResponse(partial, true)
+	if err != nil {
+		return
+	}
+	if err := cc.backend(ctx).Set(ctx, pkey, respBytes, cc.entryTTL(ctx, key)); err != nil {
+		cc.log(ctx, fmt.Sprintf("[httpcache] cache backend error on set for key %v (%v)", pkey, err))
+	}
+}
+
+// mergeSegment merges the range r of a representation of size bytes, with the headers respHeaders
+// and the content body, with prev, a stored partial response. The range and content of resp are
+// returned as is unless prev holds an adjacent or overlapping range of the same representation, as
+// identified by their strong validators (see rangeValidator).
+func mergeSegment(prev *http.Response, respHeaders http.Header, r byteRange, size int64, body []byte) (byteRange, []byte) {
+	defer prev.Body.Close()
+	validator := rangeValidator(respHeaders)
+	if validator == "" || validator != rangeValidator(prev.Header) {
+		return r, body
+	}
+	held, heldSize, ok := parseContentRange(prev.Header.Get("Content-Range"))
+	if !ok || heldSize != size || r.start > held.end+1 || held.start > r.end+1 {
+		return r, body
+	}
+	heldBody, err := ioutil.ReadAll(prev.Body)
+	if err != nil || int64(len(heldBody)) != held.end-held.start+1 {
+		return r, body
+	}
+	merged := r
+	if held.start < merged.start {
+		merged.start = held.start
+	}
+	if held.end > merged.end {
+		merged.end = held.end
+	}
+	assembled := make([]byte, merged.end-merged.start+1)
+	copy(assembled[held.start-merged.start:], heldBody)
+	copy(assembled[r.start-merged.start:], body)
+	return merged, assembled
+}
+
+// purgePartial removes the partial response stored for the resource stored under key
+func (cc *CachedClient) purgePartial(ctx context.Context, key string) {
+	if cc.Options.StorePartial {
+		cc.backend(ctx).Delete(ctx, key+partialSuffix)
+	}
+}
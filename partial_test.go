@@ -0,0 +1,92 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseContentRange(t *testing.T) {
+	for _, c := range []struct {
+		value string
+		want  byteRange
+		size  int64
+		isOK  bool
+	}{
+		{"bytes 0-4/10", byteRange{0, 4}, 10, true},
+		{"bytes 5-9/*", byteRange{5, 9}, -1, true},
+		{"bytes 5-10/10", byteRange{}, 0, false},
+		{"bytes */10", byteRange{}, 0, false},
+		{"items 0-4/10", byteRange{}, 0, false},
+	} {
+		r, size, ok := parseContentRange(c.value)
+		if ok != c.isOK || (ok && (r != c.want || size != c.size)) {
+			t.Errorf("parseContentRange(%q) = %v, %d, %v, want %v, %d, %v", c.value, r, size, ok, c.want, c.size, c.isOK)
+		}
+	}
+}
+
+func TestStorePartial(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer server.Close()
+	cache := NewMemoryCache()
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{StorePartial: true}}
+	do := func(rangeHeader string) (*http.Response, string) {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	for i, c := range []struct {
+		rangeHeader, body string
+		requests          int
+	}{
+		{"bytes=0-3", "0123", 1},
+		{"bytes=1-2", "12", 1},
+		{"bytes=2-5", "2345", 2},
+		{"bytes=0-5", "012345", 2},
+		{"bytes=3-7", "34567", 3},
+		{"bytes=8-9", "89", 4},
+		{"", "0123456789", 4},
+		{"bytes=-4", "6789", 4},
+	} {
+		resp, body := do(c.rangeHeader)
+		if body != c.body || requests != c.requests {
+			t.Fatalf("request %d: got %d %q after %d origin requests, want %q after %d", i, resp.StatusCode, body, requests,
+				c.body, c.requests)
+		}
+	}
+	if keys := cache.Keys(); len(keys) != 1 || keys[0] != server.URL {
+		t.Fatalf("got keys %v, want the assembled response only", keys)
+	}
+
+	client.evictEntry(context.Background(), server.URL)
+	do("bytes=0-1")
+	if keys := cache.Keys(); len(keys) != 1 || keys[0] != server.URL+partialSuffix {
+		t.Fatalf("got keys %v, want the partial response", keys)
+	}
+	client.evictEntry(context.Background(), server.URL)
+	if keys := cache.Keys(); len(keys) != 0 {
+		t.Fatalf("got keys %v after eviction, want the partial response removed", keys)
+	}
+}
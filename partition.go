@@ -0,0 +1,39 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type partitionCtxKey struct{}
+
+// WithPartition returns a copy of ctx carrying the cache partition of the requests made with it.
+// See CacheOptions.Partition.
+func WithPartition(ctx context.Context, partition string) context.Context {
+	return context.WithValue(ctx, partitionCtxKey{}, partition)
+}
+
+// PartitionFromContext returns the cache partition carried by ctx, or an empty string if none
+func PartitionFromContext(ctx context.Context) string {
+	partition, _ := ctx.Value(partitionCtxKey{}).(string)
+	return partition
+}
+
+// partition returns the cache partition of req: the one returned by the Partition option if set,
+// and the one carried by the request context otherwise
+func (cc *CachedClient) partition(req *http.Request) string {
+	if cc.Options.Partition != nil {
+		return cc.Options.Partition(req)
+	}
+	return PartitionFromContext(req.Context())
+}
+
+// partitionPrefix returns the key prefix isolating the entries of req partition, if any
+func (cc *CachedClient) partitionPrefix(req *http.Request) string {
+	partition := cc.partition(req)
+	if partition == "" {
+		return ""
+	}
+	return "partition:" + url.QueryEscape(partition) + " "
+}
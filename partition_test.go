@@ -0,0 +1,67 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestPartition(t *testing.T) {
+	resetTest()
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{MarkCachedResponses: true},
+	}
+	get := func(partition string) string {
+		req, err := http.NewRequest("GET", s.server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if partition != "" {
+			req = req.WithContext(WithPartition(req.Context(), partition))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.Header.Get(XFromCache)
+	}
+
+	get("https://a.example.com")
+	if got := get("https://a.example.com"); got != "1" {
+		t.Fatal("entry wasn't served from the cache within its partition")
+	}
+	if got := get("https://b.example.com"); got != "" {
+		t.Fatal("entry was served from the cache to another partition")
+	}
+	if got := get(""); got != "" {
+		t.Fatal("partitioned entry was served from the cache without partition")
+	}
+}
+
+func TestPartitionOption(t *testing.T) {
+	client := &CachedClient{Options: CacheOptions{
+		Partition: func(req *http.Request) string { return req.Header.Get("X-Caller") },
+	}}
+	req, err := http.NewRequest("GET", "http://example.com/items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(WithPartition(context.Background(), "ignored"))
+	req.Header.Set("X-Caller", "billing service")
+
+	key := client.cacheKey(req)
+	if want := "partition:billing+service http://example.com/items"; key != want {
+		t.Fatalf("got key %q, want %q", key, want)
+	}
+	if got := KeyURL(key); got != "http://example.com/items" {
+		t.Fatalf("got URL %q from key %q", got, key)
+	}
+	if got := PartitionFromContext(context.Background()); got != "" {
+		t.Fatalf("got partition %q from an empty context", got)
+	}
+}
@@ -0,0 +1,67 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// lifetimeHeader records the freshness lifetime, in seconds, assigned to an entry when it was
+// stored, overriding its own (see WithTTL and CacheOptions.ErrorTTL)
+const lifetimeHeader = "X-Httpcache-Lifetime"
+
+type ttlCtxKey struct{}
+
+type policyCtxKey struct{}
+
+// WithTTL returns a copy of ctx with which the responses to requests are stored for ttl, and
+// served from the cache for as long, whatever their own expiration time, so that a single call
+// site can cache an expensive response longer than the origin allows. Their directives
+// forbidding storage or reuse, such as no-store or no-cache, still apply. Rounded up to the second.
+func WithTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlCtxKey{}, ttl)
+}
+
+// TTLFromContext returns the TTL carried by ctx, if any
+func TTLFromContext(ctx context.Context) (time.Duration, bool) {
+	ttl, ok := ctx.Value(ttlCtxKey{}).(time.Duration)
+	return ttl, ok && ttl > 0
+}
+
+// WithPolicy returns a copy of ctx with which requests follow the policy of rule, as if it were the
+// route rule matching their URL. Its Pattern is ignored.
+func WithPolicy(ctx context.Context, rule RouteRule) context.Context {
+	return context.WithValue(ctx, policyCtxKey{}, rule)
+}
+
+// PolicyFromContext returns the route rule carried by ctx, if any
+func PolicyFromContext(ctx context.Context) (RouteRule, bool) {
+	rule, ok := ctx.Value(policyCtxKey{}).(RouteRule)
+	return rule, ok
+}
+
+// policy returns the rule applying to rawURL for a request made with ctx: the one carried by ctx
+// if any, or else the route rule matching rawURL
+func (cc *CachedClient) policy(ctx context.Context, rawURL string) (RouteRule, bool) {
+	if rule, ok := PolicyFromContext(ctx); ok {
+		return rule, true
+	}
+	return cc.routeRule(rawURL)
+}
+
+// ttlSeconds returns d in seconds, rounded up
+func ttlSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// markLifetime records the freshness lifetime of resp, a response to req about to be stored, if
+// one is assigned to it: the TTL carried by the request context, or Options.ErrorTTL for error
+// responses
+func (cc *CachedClient) markLifetime(req *http.Request, resp *http.Response) {
+	if ttl, ok := TTLFromContext(req.Context()); ok {
+		resp.Header.Set(lifetimeHeader, strconv.FormatInt(ttlSeconds(ttl), 10))
+	} else if cc.Options.ErrorTTL > 0 && errorStatus(resp.StatusCode) {
+		resp.Header.Set(lifetimeHeader, strconv.FormatInt(int64(cc.Options.ErrorTTL/time.Second), 10))
+	}
+}
@@ -0,0 +1,128 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// ttlRecordingCache is a MemoryCache recording the TTL of every entry set
+type ttlRecordingCache struct {
+	*MemoryCache
+	ttls map[string]int
+}
+
+func (c *ttlRecordingCache) Set(key string, resp []byte, ttl int) {
+	c.ttls[key] = ttl
+	c.MemoryCache.Set(key, resp, ttl)
+}
+
+func TestWithTTL(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("report"))
+	}))
+	defer server.Close()
+	cache := &ttlRecordingCache{MemoryCache: NewMemoryCache(), ttls: map[string]int{}}
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{TTL: 60}}
+	do := func(ctx context.Context, path string, elapsed time.Duration) {
+		clock = &fakeClock{elapsed: elapsed}
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	ctx := WithTTL(context.Background(), time.Hour)
+	do(ctx, "/report", 0)
+	if ttl := cache.ttls[server.URL+"/report"]; ttl != 3600 {
+		t.Fatalf("got backend TTL %d, want the context TTL", ttl)
+	}
+	do(context.Background(), "/report", 30*time.Minute)
+	if requests != 1 {
+		t.Fatalf("got %d origin requests, want the report served from the cache", requests)
+	}
+	do(context.Background(), "/report", 2*time.Hour)
+	if requests != 2 {
+		t.Fatalf("got %d origin requests, want the report refetched past its TTL", requests)
+	}
+
+	// The rest of the client uses the defaults
+	do(context.Background(), "/other", 0)
+	do(context.Background(), "/other", time.Second)
+	if requests != 4 {
+		t.Fatalf("got %d origin requests, want responses without freshness revalidated", requests)
+	}
+	if ttl := cache.ttls[server.URL+"/other"]; ttl != 60 {
+		t.Fatalf("got backend TTL %d, want Options.TTL", ttl)
+	}
+}
+
+func TestOriginLifetimeIgnored(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set(lifetimeHeader, "3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if requests != 3 {
+		t.Fatalf("got %d origin requests, want the lifetime sent by the origin ignored", requests)
+	}
+}
+
+func TestWithPolicy(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	cache := &ttlRecordingCache{MemoryCache: NewMemoryCache(), ttls: map[string]int{}}
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{
+		Rules: []RouteRule{{Pattern: regexp.MustCompile("."), TTL: 600}},
+	}}
+	ctx := WithPolicy(context.Background(), RouteRule{TTL: 30, MaxAge: 10})
+	for _, elapsed := range []time.Duration{0, 5 * time.Second, 20 * time.Second} {
+		clock = &fakeClock{elapsed: elapsed}
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if requests != 2 {
+		t.Fatalf("got %d origin requests, want the context MaxAge to cap the lifetime", requests)
+	}
+	if ttl := cache.ttls[server.URL]; ttl != 30 {
+		t.Fatalf("got backend TTL %d, want the context policy TTL over the route rule", ttl)
+	}
+}
@@ -0,0 +1,52 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// cachesPost returns true if req is a POST request the client caches (see CacheOptions.CachePOST)
+func (cc *CachedClient) cachesPost(req *http.Request) bool {
+	return req.Method == http.MethodPost && cc.Options.CachePOST != nil && cc.Options.CachePOST(req)
+}
+
+// postKey returns the key of the cached POST request req, stored under key if its body were
+// ignored, along with a request equivalent to req whose body can still be sent
+func (cc *CachedClient) postKey(req *http.Request, key string) (string, *http.Request, error) {
+	body, req, err := replayableBody(req)
+	if err != nil {
+		return "", nil, err
+	}
+	return key + " body:" + hashBytes(cc.hasher(), body), req, nil
+}
+
+// replayableBody returns the body of req, along with a request equivalent to req whose body can
+// still be read. req itself is returned if its body can be obtained again through GetBody.
+func replayableBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer r.Close()
+		body, err := ioutil.ReadAll(r)
+		return body, req, err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	req = cloneRequest(req)
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return body, req, nil
+}
@@ -0,0 +1,56 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCachePOST(t *testing.T) {
+	resetTest()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write(body)
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}, Options: CacheOptions{
+		CachePOST: func(req *http.Request) bool { return req.URL.Path == "/search" },
+	}}
+	for i, c := range []struct {
+		path, body string
+		replayable bool
+		requests   int
+	}{
+		{"/search", "q=a", true, 1},
+		{"/search", "q=a", false, 1},
+		{"/search", "q=b", false, 2},
+		{"/search", "q=b", true, 2},
+		{"/search", "", false, 3},
+		{"/search", "", false, 3},
+		{"/submit", "q=a", true, 4},
+		{"/submit", "q=a", true, 5},
+	} {
+		var body io.Reader = bytes.NewBufferString(c.body)
+		if !c.replayable {
+			// Hides the type of the reader from NewRequest, which then sets no GetBody
+			body = ioutil.NopCloser(strings.NewReader(c.body))
+		}
+		req, _ := http.NewRequest("POST", server.URL+c.path, body)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(got) != c.body || requests != c.requests {
+			t.Fatalf("request %d: got body %q after %d origin requests, want %q after %d", i, got, requests, c.body, c.requests)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package httpcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PrefetchOptions configures the background prefetching of the resources linked from
+// stored responses through Link headers
+type PrefetchOptions struct {
+	// Rels lists the link relation types to prefetch. Defaults to preload and next.
+	Rels []string
+	// Concurrency bounds the number of prefetches running at the same time. Links found
+	// while the limit is reached are skipped. Defaults to 4.
+	Concurrency int
+	// Timeout bounds the duration of each prefetch. Defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+type prefetchCtxKey struct{}
+
+// link is a single value of a Link header
+type link struct {
+	URL  string
+	Rels []string
+}
+
+// parseLinkHeader returns all the links listed in the Link headers of headers
+func parseLinkHeader(headers http.Header) []link {
+	var links []link
+	for _, val := range headers[http.CanonicalHeaderKey("link")] {
+		for _, part := range strings.Split(val, ",") {
+			part = strings.TrimSpace(part)
+			if !strings.HasPrefix(part, "<") {
+				continue
+			}
+			end := strings.Index(part, ">")
+			if end < 0 {
+				continue
+			}
+			l := link{URL: part[1:end]}
+			for _, param := range strings.Split(part[end+1:], ";") {
+				keyval := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(keyval) != 2 || !strings.EqualFold(strings.TrimSpace(keyval[0]), "rel") {
+					continue
+				}
+				rels := strings.Trim(strings.TrimSpace(keyval[1]), `"`)
+				l.Rels = append(l.Rels, strings.Fields(strings.ToLower(rels))...)
+			}
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// prefetchLinks fetches in the background every resource linked from resp with one of the
+// configured relation types, so it is stored before the caller asks for it. Only links to the
+// scheme and host of req are followed, as prefetches carry its headers and credentials.
+// Prefetched responses don't trigger further prefetches.
+func (cc *CachedClient) prefetchLinks(req *http.Request, resp *http.Response) {
+	opts := cc.Options.Prefetch
+	if opts == nil || req.Context().Value(prefetchCtxKey{}) != nil {
+		return
+	}
+	rels := opts.Rels
+	if len(rels) == 0 {
+		rels = []string{"preload", "next"}
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	for _, l := range parseLinkHeader(resp.Header) {
+		if !linkHasRel(l, rels) {
+			continue
+		}
+		target, err := req.URL.Parse(l.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+			continue
+		}
+		if !strings.EqualFold(target.Scheme, req.URL.Scheme) || !strings.EqualFold(target.Host, req.URL.Host) {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) skipping prefetch of cross-origin link %v", req, target))
+			continue
+		}
+		if !cc.acquirePrefetchSlot() {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) prefetch concurrency limit reached. skipping %v", req, target))
+			return
+		}
+
+		preq := cloneRequest(req)
+		preq.URL = target
+		preq.Host = ""
+		for _, h := range []string{"If-None-Match", "If-Modified-Since", "Range", "If-Range"} {
+			preq.Header.Del(h)
+		}
+		ctx := withScratchLayer(context.Background(), scratchLayerFromContext(req.Context()))
+		ctx, cancel := context.WithTimeout(context.WithValue(ctx, prefetchCtxKey{}, true), timeout)
+		preq = preq.WithContext(ctx)
+
+		cc.log(ctx, fmt.Sprintf("[httpcache](%p) prefetching linked resource %v", req, target))
+		go func() {
+			defer cc.releasePrefetchSlot()
+			defer cancel()
+			presp, err := cc.Do(preq)
+			if err != nil {
+				return
+			}
+			io.Copy(ioutil.Discard, presp.Body)
+			presp.Body.Close()
+		}()
+	}
+}
+
+func linkHasRel(l link, rels []string) bool {
+	for _, rel := range l.Rels {
+		for _, wanted := range rels {
+			if strings.EqualFold(rel, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (cc *CachedClient) acquirePrefetchSlot() bool {
+	cc.mu.Lock()
+	if cc.prefetchSem == nil {
+		concurrency := cc.Options.Prefetch.Concurrency
+		if concurrency <= 0 {
+			concurrency = 4
+		}
+		cc.prefetchSem = make(chan struct{}, concurrency)
+	}
+	sem := cc.prefetchSem
+	cc.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cc *CachedClient) releasePrefetchSlot() {
+	<-cc.prefetchSem
+}
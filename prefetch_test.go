@@ -0,0 +1,128 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLinkHeader(t *testing.T) {
+	headers := http.Header{}
+	headers.Add("Link", `</page/2>; rel="next", </style.css>; rel=preload; as=style`)
+	headers.Add("Link", `<https://example.com/>; rel="canonical alternate"`)
+
+	got := parseLinkHeader(headers)
+	want := []link{
+		{URL: "/page/2", Rels: []string{"next"}},
+		{URL: "/style.css", Rels: []string{"preload"}},
+		{URL: "https://example.com/", Rels: []string{"canonical", "alternate"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestPrefetchLinks(t *testing.T) {
+	resetTest()
+	fetched := make(chan string, 10)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Link", `</page/2>; rel="next", </ignored>; rel="prev"`)
+		w.Write([]byte("page 1"))
+	})
+	mux.HandleFunc("/page/2", func(w http.ResponseWriter, r *http.Request) {
+		fetched <- r.URL.Path
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Link", `</page/3>; rel="next"`)
+		w.Write([]byte("page 2"))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fetched <- r.URL.Path
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{MarkCachedResponses: true, Prefetch: &PrefetchOptions{}},
+	}
+	req, err := http.NewRequest("GET", server.URL+"/page/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	select {
+	case path := <-fetched:
+		if path != "/page/2" {
+			t.Fatalf("prefetched %s, want /page/2", path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("next page wasn't prefetched")
+	}
+
+	// Wait for the prefetched body to be stored
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := client.Cache.Get(server.URL + "/page/2"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("prefetched response wasn't stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case path := <-fetched:
+		t.Fatalf("unexpected fetch of %s", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPrefetchSkipsCrossOriginLinks(t *testing.T) {
+	fetched := make(chan string, 10)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched <- r.Header.Get("Authorization")
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Link", "<"+other.URL+"/next>; rel=next")
+		w.Write([]byte("page"))
+	}))
+	defer server.Close()
+
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{Prefetch: &PrefetchOptions{}},
+	}
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	select {
+	case auth := <-fetched:
+		t.Fatalf("cross-origin link prefetched with Authorization %q", auth)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,226 @@
+package httpcache
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteRange is a single range of a Range request header, resolved against the size of a body
+type byteRange struct {
+	start, end int64 // end is inclusive
+}
+
+// parseRange parses a Range header value holding a single byte range, resolving it against size.
+// ok is false if the header is malformed or holds several ranges, which the cache leaves to the
+// origin. satisfiable is false if the range falls outside the body.
+func parseRange(value string, size int64) (r byteRange, satisfiable, ok bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "bytes=") || strings.Contains(value, ",") {
+		return r, false, false
+	}
+	spec := strings.TrimSpace(strings.TrimPrefix(value, "bytes="))
+	i := strings.IndexByte(spec, '-')
+	if i < 0 {
+		return r, false, false
+	}
+	first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return r, false, false
+		}
+		if n == 0 || size == 0 {
+			return r, false, true
+		}
+		if n > size {
+			n = size
+		}
+		return byteRange{start: size - n, end: size - 1}, true, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return r, false, false
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return r, false, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return r, false, true
+	}
+	return byteRange{start: start, end: end}, true, true
+}
+
+// rangeValidator returns the strong validator of the entry with the headers respHeaders, to send in
+// an If-Range header or to tell whether partial responses can be combined (RFC 9111 section 3.4):
+// its ETag if strong, or else its Last-Modified date if it has no ETag and the date is strong. It
+// returns an empty string if the entry has no strong validator.
+func rangeValidator(respHeaders http.Header) string {
+	if etag := respHeaders.Get("ETag"); etag != "" && respHeaders.Get(syntheticETagHeader) == "" {
+		if strings.HasPrefix(etag, "W/") {
+			return ""
+		}
+		return etag
+	}
+	if strongLastModified(respHeaders) {
+		return respHeaders.Get("Last-Modified")
+	}
+	return ""
+}
+
+// strongLastModified returns true if the Last-Modified date of the entry with the headers
+// respHeaders can be used as a strong validator, its Date being at least 60 seconds later (RFC 9110
+// section 8.8.2.2)
+func strongLastModified(respHeaders http.Header) bool {
+	lastModified, err := parseHTTPDate(respHeaders.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	date, err := parseHTTPDate(respHeaders.Get("Date"))
+	return err == nil && date.Sub(lastModified) >= time.Minute
+}
+
+// ifRangeMatches returns true if the If-Range header value ifRange, if any, matches the entry with
+// the headers respHeaders. Validators are compared strongly, as required by RFC 9110 section 13.1.5.
+func ifRangeMatches(ifRange string, respHeaders http.Header) bool {
+	return ifRange == "" || ifRange == rangeValidator(respHeaders)
+}
+
+// partialResponse returns the response to the Range request req from cachedResp, a complete 200
+// response: a 206 response with the requested bytes, a 416 response if the range can't be
+// satisfied, or cachedResp itself if req holds an If-Range the entry doesn't match. It returns nil
+// if the range isn't one the cache can serve, or if the entry is incomplete. The body of
+// cachedResp is consumed unless cachedResp is returned.
+func partialResponse(req *http.Request, cachedResp *http.Response) (*http.Response, error) {
+	if cachedResp.StatusCode != http.StatusOK || cachedResp.Header.Get("Content-Encoding") != "" {
+		return nil, nil
+	}
+	if !ifRangeMatches(req.Header.Get("If-Range"), cachedResp.Header) {
+		return cachedResp, nil
+	}
+	body, err := ioutil.ReadAll(cachedResp.Body)
+	cachedResp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(body))
+	if cachedResp.ContentLength >= 0 && cachedResp.ContentLength != size {
+		return nil, nil
+	}
+	r, satisfiable, ok := parseRange(req.Header.Get("Range"), size)
+	if !ok {
+		return nil, nil
+	}
+	if !satisfiable {
+		resp := withBody(cachedResp, http.StatusRequestedRangeNotSatisfiable, nil)
+		resp.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return resp, nil
+	}
+	return sliceResponse(cachedResp, body, 0, r, size), nil
+}
+
+// sliceResponse returns a 206 response with the headers of resp and the range r of a representation
+// of size bytes, taken from body, the bytes of that representation starting at offset
+func sliceResponse(resp *http.Response, body []byte, offset int64, r byteRange, size int64) *http.Response {
+	partial := withBody(resp, http.StatusPartialContent, body[r.start-offset:r.end-offset+1])
+	partial.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size))
+	return partial
+}
+
+// withBody returns a copy of resp, with its own headers, the given status code and body
+func withBody(resp *http.Response, code int, body []byte) *http.Response {
+	copied := *resp
+	copied.Header = cloneHeader(resp.Header)
+	copied.StatusCode = code
+	copied.Status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+	copied.ContentLength = int64(len(body))
+	copied.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	copied.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return &copied
+}
+
+// cloneHeader returns a copy of h
+func cloneHeader(h http.Header) http.Header {
+	h2 := make(http.Header, len(h))
+	for k, v := range h {
+		h2[k] = v
+	}
+	return h2
+}
+
+// doRange does the Range request req, stored under key if it weren't partial. Partial responses
+// are only stored if Options.StorePartial is set, but a fresh complete entry serves them locally. A
+// stale one has its validator sent in an If-Range header, so that the origin only sends the range
+// if the entry is still current.
+func (cc *CachedClient) doRange(req *http.Request, key string, started time.Time) (*http.Response, error) {
+	status := cacheStatus{fwd: fwdBypass}
+	if !cc.Options.WriteOnly {
+		d := cc.decideWithin(req, key)
+		if d.resp != nil && d.err == nil && d.varyMatches {
+			switch d.freshness {
+			case fresh:
+				resp, err := partialResponse(req, d.resp)
+				if resp != d.resp {
+					d.resp.Body.Close()
+				}
+				if resp != nil && err == nil {
+					cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) serving range from cached entry for key %v", req, key),
+						"key", key, "outcome", DecisionHit.String())
+					return cc.serveHit(req, key, resp, d.lookup), nil
+				}
+			case stale:
+				d.resp.Body.Close()
+				status.fwd = fwdStale
+				if validator := rangeValidator(d.resp.Header); validator != "" && req.Header.Get("If-Range") == "" {
+					cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) setting request if-range to %s from cached entry", req, validator))
+					req = cloneRequest(req)
+					req.Header.Set("If-Range", validator)
+				}
+			default:
+				d.resp.Body.Close()
+			}
+		} else if d.resp != nil {
+			d.resp.Body.Close()
+		}
+		if status.fwd == fwdBypass {
+			if resp := cc.lookupPartial(req, key); resp != nil {
+				cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) serving range from stored partial response for key %v", req, key),
+					"key", key, "outcome", DecisionHit.String())
+				return cc.serveHit(req, key, resp, d.lookup), nil
+			}
+		}
+	}
+	if status.fwd == fwdStale {
+		cc.emit(Event{Type: EventDecision, Key: key, Decision: DecisionStale})
+	} else {
+		cc.emit(Event{Type: EventDecision, Key: key, Decision: DecisionBypass})
+	}
+	if onlyIfCached(req) {
+		resp, err := cc.onlyIfCachedMiss(req)
+		if err == nil {
+			cc.setCacheStatus(resp, cacheStatus{})
+		}
+		return resp, err
+	}
+
+	cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) range request. executing remote request", req))
+	resp, err := cc.roundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	status.fwdStatus = resp.StatusCode
+	status.stored = cc.storePartialOnEOF(req, key, resp, started)
+	cc.setCacheStatus(resp, status)
+	return resp, nil
+}
@@ -0,0 +1,149 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	for _, c := range []struct {
+		value             string
+		want              byteRange
+		satisfiable, isOK bool
+	}{
+		{"bytes=0-4", byteRange{0, 4}, true, true},
+		{"bytes=5-", byteRange{5, 9}, true, true},
+		{"bytes=-3", byteRange{7, 9}, true, true},
+		{"bytes=-20", byteRange{0, 9}, true, true},
+		{"bytes=8-20", byteRange{8, 9}, true, true},
+		{"bytes=10-", byteRange{}, false, true},
+		{"bytes=-0", byteRange{}, false, true},
+		{"bytes=4-2", byteRange{}, false, false},
+		{"bytes=0-1,4-5", byteRange{}, false, false},
+		{"items=0-1", byteRange{}, false, false},
+		{"bytes=a-", byteRange{}, false, false},
+	} {
+		r, satisfiable, ok := parseRange(c.value, 10)
+		if ok != c.isOK || satisfiable != c.satisfiable || (satisfiable && r != c.want) {
+			t.Errorf("parseRange(%q) = %v, %v, %v, want %v, %v, %v", c.value, r, satisfiable, ok, c.want, c.satisfiable, c.isOK)
+		}
+	}
+}
+
+func TestRangeFromCache(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var requests int
+	var ifRange string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		ifRange = r.Header.Get("If-Range")
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	do := func(rangeHeader, ifRange string) (*http.Response, string) {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		if ifRange != "" {
+			req.Header.Set("If-Range", ifRange)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	do("", "")
+	for _, c := range []struct {
+		rangeHeader, ifRange string
+		status               int
+		contentRange, body   string
+	}{
+		{"bytes=2-5", "", http.StatusPartialContent, "bytes 2-5/10", "2345"},
+		{"bytes=-2", `"v1"`, http.StatusPartialContent, "bytes 8-9/10", "89"},
+		{"bytes=20-", "", http.StatusRequestedRangeNotSatisfiable, "bytes */10", ""},
+		{"bytes=2-5", `"v0"`, http.StatusOK, "", "0123456789"},
+	} {
+		resp, body := do(c.rangeHeader, c.ifRange)
+		if resp.StatusCode != c.status || resp.Header.Get("Content-Range") != c.contentRange || body != c.body {
+			t.Fatalf("range %q: got %d %q %q, want %d %q %q", c.rangeHeader, resp.StatusCode, resp.Header.Get("Content-Range"), body,
+				c.status, c.contentRange, c.body)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("got %d origin requests, want ranges served from the cached entry", requests)
+	}
+
+	clock = &fakeClock{elapsed: 20 * time.Second}
+	if resp, body := do("bytes=2-5", ""); resp.StatusCode != http.StatusPartialContent || body != "2345" {
+		t.Fatalf("got %d %q from the origin, want the range", resp.StatusCode, body)
+	}
+	if requests != 2 || ifRange != `"v1"` {
+		t.Fatalf("got %d origin requests with If-Range %q, want the stale entry's ETag sent", requests, ifRange)
+	}
+}
+
+func TestRangeOnlyIfCached(t *testing.T) {
+	resetTest()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	for _, asError := range []bool{false, true} {
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+			Options: CacheOptions{OnlyIfCachedError: asError}}
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("Range", "bytes=0-1")
+		req.Header.Set("Cache-Control", "only-if-cached")
+		resp, err := client.Do(req)
+		if asError {
+			if err != ErrNoCachedEntry {
+				t.Fatalf("got %v, %v, want ErrNoCachedEntry", resp, err)
+			}
+		} else if err != nil || resp.StatusCode != http.StatusGatewayTimeout {
+			t.Fatalf("got %v, %v, want a 504 response", resp, err)
+		}
+	}
+	if requests != 0 {
+		t.Fatalf("got %d requests to the origin, want none", requests)
+	}
+}
+
+func TestRangeValidator(t *testing.T) {
+	date := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name                     string
+		etag, lastModified, date string
+		want                     string
+	}{
+		{"strong etag", `"v1"`, "", "", `"v1"`},
+		{"weak etag", `W/"v1"`, date.Add(-time.Hour).Format(http.TimeFormat), date.Format(http.TimeFormat), ""},
+		{"strong date", "", date.Add(-time.Hour).Format(http.TimeFormat), date.Format(http.TimeFormat), date.Add(-time.Hour).Format(http.TimeFormat)},
+		{"recent date", "", date.Add(-time.Second).Format(http.TimeFormat), date.Format(http.TimeFormat), ""},
+		{"no date", "", date.Add(-time.Hour).Format(http.TimeFormat), "", ""},
+	} {
+		h := http.Header{}
+		for name, value := range map[string]string{"ETag": tc.etag, "Last-Modified": tc.lastModified, "Date": tc.date} {
+			if value != "" {
+				h.Set(name, value)
+			}
+		}
+		if got := rangeValidator(h); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
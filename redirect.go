@@ -0,0 +1,45 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// heuristicLifetimeHeader records the heuristic freshness lifetime, in seconds, assigned to an entry
+// stored without explicit expiration time. Like the other internal headers, it is stripped from
+// origin responses, so only the client sets it.
+const heuristicLifetimeHeader = "X-Httpcache-Heuristic-Lifetime"
+
+// permanentRedirect returns true if code is the status code of a permanent redirect
+func permanentRedirect(code int) bool {
+	return code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+}
+
+// hasExplicitExpiration returns true if a response with the given headers and cache control
+// directives has an explicit expiration time, rather than one computed heuristically
+func hasExplicitExpiration(respHeaders http.Header, respCacheControl cacheControl) bool {
+	_, maxAge := respCacheControl["max-age"]
+	_, sMaxAge := respCacheControl["s-maxage"]
+	return maxAge || sMaxAge || respHeaders.Get("Expires") != ""
+}
+
+// heuristicLifetime returns the heuristic freshness lifetime recorded in an entry, if any
+func heuristicLifetime(respHeaders http.Header) (time.Duration, bool) {
+	return parseDeltaSeconds(respHeaders.Get(heuristicLifetimeHeader))
+}
+
+// markHeuristicLifetime records the heuristic freshness lifetime of resp, a response about to be
+// stored, if it has a heuristically cacheable status code, no explicit expiration time, and
+// Options.HeuristicLifetimes or Options.PermanentRedirectLifetime assign it one (RFC 9111 section
+// 4.2.2)
+func (cc *CachedClient) markHeuristicLifetime(resp *http.Response) {
+	lifetime := cc.Options.HeuristicLifetimes[resp.StatusCode]
+	if lifetime <= 0 && permanentRedirect(resp.StatusCode) {
+		lifetime = cc.Options.PermanentRedirectLifetime
+	}
+	if lifetime <= 0 || !heuristicallyCacheable(resp.StatusCode) || hasExplicitExpiration(resp.Header, parseCacheControl(resp.Header)) {
+		return
+	}
+	resp.Header.Set(heuristicLifetimeHeader, strconv.FormatInt(int64(lifetime/time.Second), 10))
+}
@@ -0,0 +1,101 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPermanentRedirects(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	for _, c := range []struct {
+		code         int
+		cacheControl string
+		lifetime     time.Duration
+		requests     int
+	}{
+		{http.StatusMovedPermanently, "max-age=3600", 0, 1},
+		{http.StatusPermanentRedirect, "", time.Hour, 1},
+		{http.StatusPermanentRedirect, "", 0, 3},
+		{http.StatusMovedPermanently, "max-age=0", time.Hour, 3},
+		{http.StatusFound, "", time.Hour, 3},
+	} {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if c.cacheControl != "" {
+				w.Header().Set("Cache-Control", c.cacheControl)
+			}
+			w.Header().Set("Location", "/moved")
+			w.WriteHeader(c.code)
+		}))
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+			Options: CacheOptions{PermanentRedirectLifetime: c.lifetime}}
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != c.code || resp.Header.Get("Location") != "/moved" {
+				t.Fatalf("got %d to %q, want %d to /moved", resp.StatusCode, resp.Header.Get("Location"), c.code)
+			}
+		}
+		server.Close()
+		if requests != c.requests {
+			t.Fatalf("%d with %q and lifetime %s: got %d origin requests, want %d", c.code, c.cacheControl, c.lifetime, requests, c.requests)
+		}
+	}
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Location", "/moved")
+		w.WriteHeader(http.StatusMovedPermanently)
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+		Options: CacheOptions{PermanentRedirectLifetime: time.Hour}}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		clock = &fakeClock{elapsed: 2 * time.Hour}
+	}
+	if requests != 2 {
+		t.Fatalf("got %d origin requests, want the redirect refetched past its heuristic lifetime", requests)
+	}
+}
+
+func TestOriginHeuristicLifetimeIgnored(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set(heuristicLifetimeHeader, "3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if requests != 3 {
+		t.Fatalf("got %d origin requests, want the heuristic lifetime sent by the origin ignored", requests)
+	}
+}
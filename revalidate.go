@@ -0,0 +1,355 @@
+package httpcache
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of RevalidationOptions
+const (
+	DefaultRevalidationWorkers    = 4
+	DefaultMaxQueuedRevalidations = 100
+)
+
+// DropPolicy defines what happens to a background revalidation when the queue is full
+type DropPolicy int
+
+const (
+	// DropNewest discards the new revalidation: the stale entry is served and stays stale
+	DropNewest DropPolicy = iota
+	// DropOldest discards the revalidation that has been queued for the longest to make room
+	DropOldest
+	// RevalidateInline revalidates the entry synchronously instead, as if stale-while-revalidate
+	// didn't apply, so the caller waits for the origin
+	RevalidateInline
+)
+
+// RevalidationOptions configures the background revalidations of stale-while-revalidate (RFC 5861)
+type RevalidationOptions struct {
+	// Workers is the maximum number of revalidations running at once. Defaults to
+	// DefaultRevalidationWorkers.
+	Workers int
+	// MaxQueued is the maximum number of revalidations waiting for a worker. Defaults to
+	// DefaultMaxQueuedRevalidations.
+	MaxQueued int
+	// Drop defines what happens to revalidations that don't fit in the queue
+	Drop DropPolicy
+}
+
+// RevalidationStats is a snapshot of the background revalidation activity of a client
+type RevalidationStats struct {
+	// Queued is the number of revalidations currently waiting for a worker
+	Queued int
+	// Running is the number of revalidations currently running
+	Running int
+	// Enqueued is the number of revalidations accepted in the queue
+	Enqueued int64
+	// Dropped is the number of revalidations discarded because the queue was full
+	Dropped int64
+	// Inline is the number of revalidations run synchronously because the queue was full
+	Inline int64
+	// Completed is the number of finished revalidations, including the failed ones
+	Completed int64
+	// Failed is the number of revalidations that got an error or a 5xx response
+	Failed int64
+	// QueueWait is the total time spent in the queue by the started revalidations
+	QueueWait time.Duration
+	// Latency is the total time spent by the completed revalidations
+	Latency time.Duration
+}
+
+// AverageQueueWait returns the average time a started revalidation spent in the queue
+func (s RevalidationStats) AverageQueueWait() time.Duration {
+	started := s.Completed + int64(s.Running)
+	if started == 0 {
+		return 0
+	}
+	return s.QueueWait / time.Duration(started)
+}
+
+// AverageLatency returns the average duration of a completed revalidation
+func (s RevalidationStats) AverageLatency() time.Duration {
+	if s.Completed == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Completed)
+}
+
+// QueuedRevalidation describes a background revalidation waiting for a worker
+type QueuedRevalidation struct {
+	// Key is the cache key of the entry to revalidate
+	Key string `json:"key"`
+	// Hits is the number of stale hits the entry got since it was queued
+	Hits int `json:"hits"`
+	// Queued is the time the revalidation was queued at
+	Queued time.Time `json:"queued"`
+	// Deadline is the end of the stale-while-revalidate window of the entry, after which it must
+	// be revalidated synchronously
+	Deadline time.Time `json:"deadline"`
+}
+
+type revalidatingCtxKey struct{}
+
+// revalidationJob is a queued or running revalidation
+type revalidationJob struct {
+	req    *http.Request
+	key    string
+	queued time.Time
+	// hits is the number of stale hits the entry got since it was queued
+	hits int
+	// deadline is the end of the stale-while-revalidate window of the entry
+	deadline time.Time
+	// index of the job in the queue, -1 once running
+	index int
+}
+
+// revalidationQueue is a priority queue of revalidations, implementing heap.Interface. The most
+// requested entries come first, then the ones closest to the end of their stale-while-revalidate
+// window, as they are about to need a synchronous revalidation.
+type revalidationQueue []*revalidationJob
+
+func (q revalidationQueue) Len() int { return len(q) }
+
+func (q revalidationQueue) Less(i, j int) bool {
+	if q[i].hits != q[j].hits {
+		return q[i].hits > q[j].hits
+	}
+	if !q[i].deadline.Equal(q[j].deadline) {
+		return q[i].deadline.Before(q[j].deadline)
+	}
+	return q[i].queued.Before(q[j].queued)
+}
+
+func (q revalidationQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *revalidationQueue) Push(x interface{}) {
+	job := x.(*revalidationJob)
+	job.index = len(*q)
+	*q = append(*q, job)
+}
+
+func (q *revalidationQueue) Pop() interface{} {
+	old := *q
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	job.index = -1
+	*q = old[:len(old)-1]
+	return job
+}
+
+// oldest returns the index of the job queued for the longest
+func (q revalidationQueue) oldest() int {
+	oldest := 0
+	for i, job := range q {
+		if job.queued.Before(q[oldest].queued) {
+			oldest = i
+		}
+	}
+	return oldest
+}
+
+// revalidator runs background revalidations with a bounded number of workers and a bounded queue.
+// Workers are started as needed and exit once the queue is empty.
+type revalidator struct {
+	cc   *CachedClient
+	opts RevalidationOptions
+
+	mu      sync.Mutex
+	queue   revalidationQueue
+	pending map[string]*revalidationJob // jobs queued or running, by key
+	stats   RevalidationStats
+}
+
+func newRevalidator(cc *CachedClient, opts RevalidationOptions) *revalidator {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultRevalidationWorkers
+	}
+	if opts.MaxQueued <= 0 {
+		opts.MaxQueued = DefaultMaxQueuedRevalidations
+	}
+	return &revalidator{cc: cc, opts: opts, pending: map[string]*revalidationJob{}}
+}
+
+// revalidator returns the revalidator of the client, creating it on first use
+func (cc *CachedClient) revalidator() *revalidator {
+	cc.mu.Lock()
+	r := cc.reval
+	created := r == nil
+	if created {
+		r = newRevalidator(cc, *cc.Options.StaleWhileRevalidate)
+		cc.reval = r
+	}
+	cc.mu.Unlock()
+	if created {
+		cc.publishRevalidationQueue()
+	}
+	return r
+}
+
+// RevalidationStats returns a snapshot of the background revalidations of the client
+func (cc *CachedClient) RevalidationStats() RevalidationStats {
+	cc.mu.Lock()
+	r := cc.reval
+	cc.mu.Unlock()
+	if r == nil {
+		return RevalidationStats{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats
+	stats.Queued = len(r.queue)
+	return stats
+}
+
+// RevalidationQueue returns a snapshot of the background revalidations waiting for a worker, in the
+// order they will run
+func (cc *CachedClient) RevalidationQueue() []QueuedRevalidation {
+	cc.mu.Lock()
+	r := cc.reval
+	cc.mu.Unlock()
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	jobs := make(revalidationQueue, len(r.queue))
+	for i, job := range r.queue {
+		copied := *job
+		jobs[i] = &copied
+	}
+	r.mu.Unlock()
+
+	sort.Slice(jobs, jobs.Less)
+	queued := make([]QueuedRevalidation, len(jobs))
+	for i, job := range jobs {
+		queued[i] = QueuedRevalidation{Key: job.key, Hits: job.hits, Queued: job.queued, Deadline: job.deadline}
+	}
+	return queued
+}
+
+// staleWhileRevalidate returns the end of the stale-while-revalidate window of the stale entry with
+// headers respHeaders, and true if it may be served to req while revalidated in the background
+func (cc *CachedClient) staleWhileRevalidate(req *http.Request, respHeaders http.Header) (time.Time, bool) {
+	if cc.Options.StaleWhileRevalidate == nil || req.Method != "GET" || req.Context().Value(revalidatingCtxKey{}) != nil {
+		return time.Time{}, false
+	}
+	// Callers bounding the age of responses explicitly don't get stale ones
+	if _, ok := MaxAcceptableAgeFromContext(req.Context()); ok || parseRequestDirectives(req.Header).hasMaxAge {
+		return time.Time{}, false
+	}
+	respCacheControl := parseCacheControl(respHeaders)
+	window, ok := parseDeltaSeconds(respCacheControl["stale-while-revalidate"])
+	if !ok || cc.mustRevalidate(respCacheControl) || varyStar(respHeaders) {
+		return time.Time{}, false
+	}
+	date, err := responseDate(respHeaders)
+	if err != nil {
+		return time.Time{}, false
+	}
+	lifetime := addDurations(cc.capLifetime(req, cc.lifetime(respHeaders, respCacheControl, date)), window)
+	received, age := initialAge(respHeaders, date)
+	return received.Add(lifetime - age), currentAge(respHeaders, date) < lifetime
+}
+
+// revalidateInBackground queues the revalidation of the entry stored under key for req, whose
+// stale-while-revalidate window ends at deadline. It returns false if the entry must be revalidated
+// synchronously instead.
+func (cc *CachedClient) revalidateInBackground(req *http.Request, key string, deadline time.Time) bool {
+	r := cc.revalidator()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job, ok := r.pending[key]; ok {
+		if job.index >= 0 {
+			job.hits++
+			heap.Fix(&r.queue, job.index)
+		}
+		return true
+	}
+	if len(r.queue) >= r.opts.MaxQueued {
+		switch r.opts.Drop {
+		case RevalidateInline:
+			r.stats.Inline++
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) revalidation queue full. revalidating inline for key %v", req, key))
+			return false
+		case DropOldest:
+			dropped := heap.Remove(&r.queue, r.queue.oldest()).(*revalidationJob)
+			delete(r.pending, dropped.key)
+			r.stats.Dropped++
+		default:
+			r.stats.Dropped++
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) revalidation queue full. dropping revalidation for key %v", req, key))
+			return true
+		}
+	}
+
+	ctx := context.WithValue(detachedContext{req.Context()}, revalidatingCtxKey{}, true)
+	job := &revalidationJob{req: cloneRequest(req).WithContext(ctx), key: key, queued: time.Now(), deadline: deadline}
+	heap.Push(&r.queue, job)
+	r.pending[key] = job
+	r.stats.Enqueued++
+	if r.stats.Running < r.opts.Workers {
+		r.stats.Running++
+		go r.work()
+	}
+	cc.log(ctx, fmt.Sprintf("[httpcache](%p) serving stale entry while revalidating in the background for key %v", req, key))
+	return true
+}
+
+// work runs queued revalidations until the queue is empty
+func (r *revalidator) work() {
+	for {
+		r.mu.Lock()
+		if len(r.queue) == 0 {
+			r.stats.Running--
+			r.mu.Unlock()
+			return
+		}
+		job := heap.Pop(&r.queue).(*revalidationJob)
+		start := time.Now()
+		r.stats.QueueWait += start.Sub(job.queued)
+		r.mu.Unlock()
+
+		failed := false
+		resp, err := r.cc.Do(job.req)
+		if err != nil {
+			failed = true
+		} else {
+			failed = resp.StatusCode >= 500
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		r.mu.Lock()
+		delete(r.pending, job.key)
+		r.stats.Completed++
+		if failed {
+			r.stats.Failed++
+		}
+		r.stats.Latency += time.Since(start)
+		r.mu.Unlock()
+	}
+}
+
+// detachedContext carries the values of its parent without its cancellation, for background work
+// outliving the request that started it
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
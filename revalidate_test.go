@@ -0,0 +1,189 @@
+package httpcache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// staleEntry returns an entry generated 10 seconds ago, stale but within its stale-while-revalidate window
+func staleEntry(body string) []byte {
+	return staleEntryWithin(body, 60)
+}
+
+func staleEntryWithin(body string, window int) []byte {
+	return []byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nDate: %s\r\nCache-Control: max-age=1, stale-while-revalidate=%d\r\n\r\n%s",
+		time.Now().Add(-10*time.Second).UTC().Format(http.TimeFormat), window, body))
+}
+
+// waitFor polls cond until it is true, failing the test after a while
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("fresh"))
+	}))
+	defer server.Close()
+	cache := NewMemoryCache()
+	client := &CachedClient{
+		Cache:     cache,
+		Transport: &http.Transport{},
+		Options:   CacheOptions{StaleWhileRevalidate: &RevalidationOptions{}},
+	}
+	get := func(header string) string {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		if header != "" {
+			req.Header.Set("Cache-Control", header)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	cache.Set(client.cacheKey(req), staleEntry("stale"), 0)
+	if got := get(""); got != "stale" {
+		t.Fatalf("got body %q, want the stale entry served right away", got)
+	}
+	waitFor(t, "the revalidation", func() bool { return client.RevalidationStats().Completed == 1 })
+	if got := get(""); got != "fresh" {
+		t.Fatalf("got body %q, want the revalidated entry", got)
+	}
+
+	// Requests bounding the age of responses revalidate synchronously
+	cache.Set(client.cacheKey(req), staleEntry("stale"), 0)
+	if got := get("max-age=5"); got != "fresh" {
+		t.Fatalf("got body %q with request max-age, want the origin response", got)
+	}
+	if stats := client.RevalidationStats(); stats.Enqueued != 1 || stats.Failed != 0 || stats.AverageLatency() <= 0 {
+		t.Fatalf("got stats %+v, want a single successful revalidation", stats)
+	}
+}
+
+func TestRevalidationSaturation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/slow") {
+			<-release
+		}
+		w.Write([]byte("fresh"))
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		drop            DropPolicy
+		last            string
+		dropped, inline int64
+	}{
+		{DropNewest, "stale", 1, 0},
+		{DropOldest, "stale", 1, 0},
+		{RevalidateInline, "fresh", 0, 1},
+	} {
+		cache := NewMemoryCache()
+		client := &CachedClient{
+			Cache:     cache,
+			Transport: &http.Transport{},
+			Options:   CacheOptions{StaleWhileRevalidate: &RevalidationOptions{Workers: 1, MaxQueued: 1, Drop: tc.drop}},
+		}
+		get := func(path string) string {
+			req, _ := http.NewRequest("GET", server.URL+path, nil)
+			cache.Set(client.cacheKey(req), staleEntry("stale"), 0)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			return string(b)
+		}
+
+		get("/slow1")
+		waitFor(t, "the first revalidation to start", func() bool { return client.RevalidationStats().Queued == 0 })
+		get("/slow2")
+		if got := get("/other"); got != tc.last {
+			t.Fatalf("policy %d: got body %q with a full queue, want %q", tc.drop, got, tc.last)
+		}
+		stats := client.RevalidationStats()
+		if stats.Running != 1 || stats.Queued != 1 || stats.Dropped != tc.dropped || stats.Inline != tc.inline {
+			t.Fatalf("policy %d: got stats %+v", tc.drop, stats)
+		}
+
+		release <- struct{}{}
+		if tc.drop != DropOldest {
+			release <- struct{}{}
+		}
+		waitFor(t, "the revalidations", func() bool { return client.RevalidationStats().Running == 0 })
+	}
+}
+
+func TestRevalidationPriority(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		} else {
+			mu.Lock()
+			order = append(order, r.URL.Path)
+			mu.Unlock()
+		}
+		w.Write([]byte("fresh"))
+	}))
+	defer server.Close()
+	cache := NewMemoryCache()
+	client := &CachedClient{
+		Cache:     cache,
+		Transport: &http.Transport{},
+		Options:   CacheOptions{StaleWhileRevalidate: &RevalidationOptions{Workers: 1}},
+	}
+	get := func(path string, window int) {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		if _, ok := cache.Get(client.cacheKey(req)); !ok {
+			cache.Set(client.cacheKey(req), staleEntryWithin("stale", window), 0)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	get("/slow", 60)
+	waitFor(t, "the first revalidation to start", func() bool { return client.RevalidationStats().Queued == 0 })
+	get("/late", 600)
+	get("/early", 30)
+	get("/first", 600)
+	get("/hot", 600)
+	get("/hot", 600)
+	get("/hot", 600)
+	queue := client.RevalidationQueue()
+	if len(queue) != 4 || queue[0].Key != server.URL+"/hot" || queue[0].Hits != 2 || queue[1].Key != server.URL+"/early" {
+		t.Fatalf("got queue %+v, want /hot with 2 hits first, then /early", queue)
+	}
+	close(release)
+	waitFor(t, "the revalidations", func() bool { return client.RevalidationStats().Completed == 5 })
+
+	want := []string{"/hot", "/early", "/late", "/first"}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(order, " ") != strings.Join(want, " ") {
+		t.Fatalf("got revalidation order %v, want %v", order, want)
+	}
+}
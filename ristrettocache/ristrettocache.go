@@ -0,0 +1,76 @@
+// Package ristrettocache provides an implementation of httpcache.Cache backed by Ristretto, a
+// high performance in-memory cache bounding its size by cost (here, the size of the stored
+// responses) and using TinyLFU admission to keep the most valuable entries.
+//
+// It is a separate module so that the main one stays free of dependencies.
+package ristrettocache
+
+import (
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+)
+
+// Cache is an implementation of httpcache.Cache backed by a ristretto.Cache. Note that Ristretto
+// may reject or evict entries at any time to stay within its MaxCost.
+type Cache struct {
+	cache *ristretto.Cache
+
+	// SyncWrites makes Set and Delete wait for Ristretto to apply the write before returning, so
+	// that it is visible to the next Get. It serializes all the writers, use it only in tests.
+	SyncWrites bool
+}
+
+// New returns a new Cache holding at most maxBytes of responses, tracking the access frequency
+// of about ten times more entries than it can hold assuming entries of avgEntrySize bytes
+func New(maxBytes, avgEntrySize int64) (*Cache, error) {
+	if avgEntrySize <= 0 {
+		avgEntrySize = 1
+	}
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: 10 * (maxBytes/avgEntrySize + 1),
+		MaxCost:     maxBytes,
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewWithCache(cache), nil
+}
+
+// NewWithCache returns a new Cache using the already configured ristretto.Cache cache. Entries
+// are set with their size in bytes as cost.
+func NewWithCache(cache *ristretto.Cache) *Cache {
+	return &Cache{cache: cache}
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *Cache) Get(key string) (resp []byte, ok bool) {
+	v, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+// Set saves response resp to the cache with key, expiring after ttl seconds if positive. Ristretto
+// buffers writes and applies them asynchronously: unless SyncWrites is set, an admitted entry may
+// not be visible to Get right after Set returns.
+func (c *Cache) Set(key string, resp []byte, ttl int) {
+	if c.cache.SetWithTTL(key, resp, int64(len(resp)), time.Duration(ttl)*time.Second) && c.SyncWrites {
+		c.cache.Wait()
+	}
+}
+
+// Delete removes key from the cache
+func (c *Cache) Delete(key string) {
+	c.cache.Del(key)
+	if c.SyncWrites {
+		c.cache.Wait()
+	}
+}
+
+// Close stops the goroutines of the underlying ristretto.Cache
+func (c *Cache) Close() {
+	c.cache.Close()
+}
@@ -0,0 +1,31 @@
+package ristrettocache
+
+import (
+	"testing"
+
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+func TestRistrettoCache(t *testing.T) {
+	c, err := New(1<<20, 1<<10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SyncWrites = true
+	test.Cache(t, c)
+}
+
+func TestMaxBytes(t *testing.T) {
+	c, err := New(1<<10, 1<<8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SyncWrites = true
+
+	c.Set("huge", make([]byte, 1<<12), 0)
+	if _, ok := c.Get("huge"); ok {
+		t.Fatal("entry larger than the cache was stored")
+	}
+}
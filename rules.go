@@ -0,0 +1,218 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// softPurgedHeader marks stored responses that must be revalidated before being served again
+const softPurgedHeader = "X-Httpcache-Soft-Purged"
+
+// RouteRule overrides the caching policy of the requests whose URL matches Pattern. When several
+// rules match a URL, the first one applies.
+type RouteRule struct {
+	Pattern *regexp.Regexp
+	// TTL, in seconds, passed to the Cache when storing matching entries instead of CacheOptions.TTL.
+	// Zero means no expiration.
+	TTL int
+	// If positive, MaxAge caps the freshness lifetime of matching entries, in seconds
+	MaxAge int
+}
+
+func (r RouteRule) equal(other RouteRule) bool {
+	return r.Pattern.String() == other.Pattern.String() && r.TTL == other.TTL && r.MaxAge == other.MaxAge
+}
+
+// routeRules returns the rules currently in effect: the ones set through SetRules if any, and
+// Options.Rules otherwise
+func (cc *CachedClient) routeRules() []RouteRule {
+	if rules, ok := cc.owner().rules.Load().([]RouteRule); ok {
+		return rules
+	}
+	return cc.Options.Rules
+}
+
+// routeRule returns the rule applying to rawURL, if any
+func (cc *CachedClient) routeRule(rawURL string) (RouteRule, bool) {
+	for _, rule := range cc.routeRules() {
+		if rule.Pattern.MatchString(rawURL) {
+			return rule, true
+		}
+	}
+	return RouteRule{}, false
+}
+
+// entryTTL returns the TTL of the entry stored under key by a request made with ctx
+func (cc *CachedClient) entryTTL(ctx context.Context, key string) int {
+	if ttl, ok := TTLFromContext(ctx); ok {
+		return int(ttlSeconds(ttl))
+	}
+	if rule, ok := cc.policy(ctx, KeyURL(key)); ok {
+		return rule.TTL
+	}
+	return cc.Options.TTL
+}
+
+// capLifetime caps lifetime to the MaxAge of the rule applying to req, if any
+func (cc *CachedClient) capLifetime(req *http.Request, lifetime time.Duration) time.Duration {
+	if req.URL == nil {
+		return lifetime
+	}
+	rule, ok := cc.policy(req.Context(), req.URL.String())
+	if !ok || rule.MaxAge <= 0 {
+		return lifetime
+	}
+	if maxAge := time.Duration(rule.MaxAge) * time.Second; lifetime > maxAge {
+		return maxAge
+	}
+	return lifetime
+}
+
+// SetRules replaces the route rules of the client at runtime, taking precedence over Options.Rules.
+// If softPurge is set, the cached entries whose URL matches a rule that was added, removed or changed
+// are soft purged (see SoftPurge), so the new policy applies to them without waiting for their
+// expiration. It returns the number of soft purged entries.
+func (cc *CachedClient) SetRules(ctx context.Context, rules []RouteRule, softPurge bool) (int, error) {
+	old := cc.routeRules()
+	cc.owner().rules.Store(append([]RouteRule(nil), rules...))
+	if !softPurge {
+		return 0, nil
+	}
+
+	changed := changedRules(old, rules)
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	return cc.SoftPurge(ctx, func(rawURL string) bool {
+		for _, rule := range changed {
+			if rule.Pattern.MatchString(rawURL) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// changedRules returns the rules present in only one of old and new
+func changedRules(old, new []RouteRule) []RouteRule {
+	var changed []RouteRule
+	contains := func(rules []RouteRule, r RouteRule) bool {
+		for _, rule := range rules {
+			if rule.equal(r) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, r := range old {
+		if !contains(new, r) {
+			changed = append(changed, r)
+		}
+	}
+	for _, r := range new {
+		if !contains(old, r) {
+			changed = append(changed, r)
+		}
+	}
+	return changed
+}
+
+// SoftPurge marks as stale every cached entry whose URL satisfies match. Unlike a deletion, soft
+// purged entries are kept: they are revalidated with the origin on their next use, and can still
+// be served if the origin fails (stale-if-error).
+//
+// The backing cache must implement KeyLister, otherwise ErrNotEnumerable is returned.
+// It returns the number of soft purged entries.
+func (cc *CachedClient) SoftPurge(ctx context.Context, match func(rawURL string) bool) (int, error) {
+	kl, ok := cc.keyLister()
+	if !ok {
+		return 0, ErrNotEnumerable
+	}
+
+	purged := 0
+	for _, key := range kl.Keys() {
+		if !match(KeyURL(key)) {
+			continue
+		}
+		ok, err := cc.softPurge(ctx, key)
+		if err != nil {
+			return purged, err
+		}
+		if ok {
+			cc.log(ctx, fmt.Sprintf("[httpcache] soft purged entry for key %v", key))
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// softPurge marks the entry stored under key as soft purged, keeping the backend TTL it has left.
+// The key is locked so that a concurrent store isn't overwritten with the previous entry. It
+// returns false if there is no entry to mark.
+func (cc *CachedClient) softPurge(ctx context.Context, key string) (bool, error) {
+	unlock := cc.owner().keyLocks.lock(key)
+	defer unlock()
+	entry, err := cc.backend(ctx).Get(ctx, key)
+	if err != nil {
+		return false, nil
+	}
+	ttl, ok := cc.remainingTTL(ctx, key, entry)
+	if !ok {
+		return false, nil
+	}
+	marked, ok := markSoftPurged(entry)
+	if !ok {
+		return false, nil
+	}
+	return true, cc.backend(ctx).Set(ctx, key, marked, ttl)
+}
+
+// remainingTTL returns the backend TTL, in seconds, left to entry, the response stored under key:
+// the TTL of the entries stored under key, less the time elapsed since it was received. Zero means no
+// expiration. It returns false if the TTL already elapsed.
+func (cc *CachedClient) remainingTTL(ctx context.Context, key string, entry []byte) (int, bool) {
+	ttl := cc.entryTTL(ctx, key)
+	if ttl <= 0 {
+		return 0, true
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(entry)), nil)
+	if err != nil {
+		return ttl, true
+	}
+	resp.Body.Close()
+	received, err := responseDate(resp.Header)
+	if err != nil {
+		return ttl, true
+	}
+	remaining := time.Duration(ttl)*time.Second - clock.since(received)
+	if remaining <= 0 {
+		return 0, false
+	}
+	return int(ttlSeconds(remaining)), true
+}
+
+// markSoftPurged adds the soft purge marker header to a stored response, right after its status
+// line. It returns false if entry isn't a stored response or is already marked.
+func markSoftPurged(entry []byte) ([]byte, bool) {
+	end := bytes.Index(entry, []byte("\r\n"))
+	if end < 0 || !bytes.HasPrefix(entry, []byte("HTTP/")) {
+		return nil, false
+	}
+	head := entry
+	if i := bytes.Index(entry, []byte("\r\n\r\n")); i >= 0 {
+		head = entry[:i]
+	}
+	if bytes.Contains(head, []byte("\r\n"+softPurgedHeader+":")) {
+		return nil, false
+	}
+
+	marked := make([]byte, 0, len(entry)+len(softPurgedHeader)+5)
+	marked = append(marked, entry[:end+2]...)
+	marked = append(marked, softPurgedHeader+": 1\r\n"...)
+	return append(marked, entry[end+2:]...), true
+}
@@ -0,0 +1,175 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRouteRules(t *testing.T) {
+	resetTest()
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Date", time.Now().UTC().Format(time.RFC1123))
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	cache := NewMemoryCache()
+	client := &CachedClient{
+		Cache:     cache,
+		Transport: &http.Transport{},
+		Options: CacheOptions{
+			TTL:   600,
+			Rules: []RouteRule{{Pattern: regexp.MustCompile(`/short`), TTL: 30, MaxAge: 10}},
+		},
+	}
+	get := func(path string) {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	get("/short")
+	get("/long")
+	if ttl := cache.items[server.URL+"/short"].ttl; ttl != 30*time.Second {
+		t.Fatalf("got TTL %v for a rule entry, want 30s", ttl)
+	}
+	if ttl := cache.items[server.URL+"/long"].ttl; ttl != 600*time.Second {
+		t.Fatalf("got TTL %v, want the default 600s", ttl)
+	}
+
+	// Past the rule MaxAge, but not past the response max-age
+	clock = &fakeClock{elapsed: 20 * time.Second}
+	atomic.StoreInt32(&hits, 0)
+	get("/short")
+	get("/long")
+	if hits != 1 {
+		t.Fatalf("got %d origin requests, want 1 for the entry capped by its rule", hits)
+	}
+}
+
+func TestSetRulesSoftPurge(t *testing.T) {
+	resetTest()
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Date", time.Now().UTC().Format(time.RFC1123))
+		w.Header().Set("Etag", `"v1"`)
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	get := func(path string) string {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Header.Get(softPurgedHeader) != "" {
+			t.Fatal("soft purge marker leaked into a response")
+		}
+		return string(body)
+	}
+	get("/items/1")
+	get("/other")
+
+	rules := []RouteRule{{Pattern: regexp.MustCompile(`/items/`), MaxAge: 60}}
+	purged, err := client.SetRules(context.Background(), rules, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatalf("soft purged %d entries, want 1", purged)
+	}
+	if purged, _ := client.SetRules(context.Background(), rules, true); purged != 0 {
+		t.Fatalf("soft purged %d entries for unchanged rules, want 0", purged)
+	}
+
+	atomic.StoreInt32(&hits, 0)
+	for i := 0; i < 2; i++ {
+		if body := get("/items/1"); body != "body" {
+			t.Fatalf("got body %q after revalidation", body)
+		}
+		get("/other")
+	}
+	if hits != 1 {
+		t.Fatalf("got %d origin requests, want a single revalidation of the soft purged entry", hits)
+	}
+}
+
+func TestSoftPurgeKeepsRemainingTTL(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	cache := &ttlRecordingCache{MemoryCache: NewMemoryCache(), ttls: map[string]int{}}
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{TTL: 60}}
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	clock = &fakeClock{elapsed: 20 * time.Second}
+	if purged, err := client.SoftPurge(context.Background(), func(string) bool { return true }); err != nil || purged != 1 {
+		t.Fatalf("soft purged %d entries (%v), want 1", purged, err)
+	}
+	if ttl := cache.ttls[server.URL]; ttl != 40 {
+		t.Fatalf("got backend TTL %d after the soft purge, want the 40s left", ttl)
+	}
+}
+
+func TestSoftPurgeNotEnumerable(t *testing.T) {
+	client := &CachedClient{Cache: unlistedCache{NewMemoryCache()}}
+	if _, err := client.SoftPurge(context.Background(), func(string) bool { return true }); err != ErrNotEnumerable {
+		t.Fatalf("got error %v, want ErrNotEnumerable", err)
+	}
+}
+
+func TestMarkSoftPurged(t *testing.T) {
+	entry := []byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+	marked, ok := markSoftPurged(entry)
+	if !ok {
+		t.Fatal("entry wasn't marked")
+	}
+	if want := "HTTP/1.1 200 OK\r\nX-Httpcache-Soft-Purged: 1\r\nContent-Length: 2\r\n\r\nok"; string(marked) != want {
+		t.Fatalf("got %q, want %q", marked, want)
+	}
+	if _, ok := markSoftPurged(marked); ok {
+		t.Fatal("entry was marked twice")
+	}
+	if _, ok := markSoftPurged([]byte("derived artifact")); ok {
+		t.Fatal("non response entry was marked")
+	}
+}
@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"context"
+	"sync"
+)
+
+type scratchCtxKey struct{}
+
+// scratchLayer is a cache layered over the backend of a client for the requests of a call tree.
+// Reads fall through to the backend, while writes and deletions only reach the scratch cache.
+// Deleted keys are remembered so the backend copy isn't read through again.
+type scratchLayer struct {
+	scratch CacheV2
+
+	mu      sync.Mutex
+	deleted map[string]bool
+}
+
+// WithScratchCache returns a copy of ctx making the requests it is used with, and the requests they
+// trigger, store their responses in c instead of the client cache. Entries not found in c are still
+// read from the client cache, which is never written to. Once the call tree is done, c can be
+// discarded along with everything it stored, such as the responses fetched by a batch job.
+func WithScratchCache(ctx context.Context, c Cache) context.Context {
+	return withScratchLayer(ctx, &scratchLayer{scratch: AdaptCache(c), deleted: map[string]bool{}})
+}
+
+func withScratchLayer(ctx context.Context, l *scratchLayer) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, scratchCtxKey{}, l)
+}
+
+// scratchLayerFromContext returns the scratch layer set on ctx with WithScratchCache, if any
+func scratchLayerFromContext(ctx context.Context) *scratchLayer {
+	l, _ := ctx.Value(scratchCtxKey{}).(*scratchLayer)
+	return l
+}
+
+// over returns the CacheV2 reading through l into main
+func (l *scratchLayer) over(main CacheV2) CacheV2 {
+	return &scratchBackend{scratchLayer: l, main: main}
+}
+
+type scratchBackend struct {
+	*scratchLayer
+	main CacheV2
+}
+
+func (b *scratchBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := b.scratch.Get(ctx, key)
+	if err != ErrCacheMiss {
+		return val, err
+	}
+	b.mu.Lock()
+	deleted := b.deleted[key]
+	b.mu.Unlock()
+	if deleted {
+		return nil, ErrCacheMiss
+	}
+	return b.main.Get(ctx, key)
+}
+
+func (b *scratchBackend) Set(ctx context.Context, key string, responseBytes []byte, ttl int) error {
+	if err := b.scratch.Set(ctx, key, responseBytes, ttl); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	delete(b.deleted, key)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *scratchBackend) Delete(ctx context.Context, key string) error {
+	b.mu.Lock()
+	b.deleted[key] = true
+	b.mu.Unlock()
+	return b.scratch.Delete(ctx, key)
+}
@@ -0,0 +1,65 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestScratchCache(t *testing.T) {
+	var origin int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&origin, 1)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	main := NewMemoryCache()
+	client := &CachedClient{Cache: main, Transport: &http.Transport{}}
+	get := func(ctx context.Context, path string) {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	fetched := func(want int32) {
+		t.Helper()
+		if got := atomic.LoadInt32(&origin); got != want {
+			t.Fatalf("got %d origin requests, want %d", got, want)
+		}
+	}
+
+	get(context.Background(), "/shared")
+	scratch := NewMemoryCache()
+	ctx := WithScratchCache(context.Background(), scratch)
+
+	get(ctx, "/shared")
+	fetched(1)
+	get(ctx, "/job")
+	get(ctx, "/job")
+	fetched(2)
+	if _, ok := main.Get(server.URL + "/job"); ok {
+		t.Fatal("scratch request stored its response in the client cache")
+	}
+	if _, ok := scratch.Get(server.URL + "/job"); !ok {
+		t.Fatal("scratch request didn't store its response in the scratch cache")
+	}
+
+	if _, err := client.InvalidateCollection(ctx, server.URL+"/shared"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := main.Get(server.URL + "/shared"); !ok {
+		t.Fatal("scratch invalidation removed the client cache entry")
+	}
+	get(ctx, "/shared")
+	fetched(3)
+	get(ctx, "/shared")
+	get(context.Background(), "/shared")
+	fetched(3)
+}
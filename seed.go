@@ -0,0 +1,65 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// seededHeader flags cached entries that were populated from a SeedSource
+const seededHeader = "X-Httpcache-Seeded"
+
+// SeedSource provides responses from outside the origin, such as a CDN bucket of pre-rendered
+// responses or a snapshot archive. It is consulted on cache misses of GET requests before going to
+// the origin, and the responses it returns are stored like origin responses, so their freshness is
+// driven by their own headers.
+type SeedSource interface {
+	// Seed returns the response for req, or ErrCacheMiss if the source has none
+	Seed(req *http.Request) (*http.Response, error)
+}
+
+// SeedSourceFunc is an adapter to use ordinary functions as a SeedSource
+type SeedSourceFunc func(req *http.Request) (*http.Response, error)
+
+// Seed calls f(req)
+func (f SeedSourceFunc) Seed(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// seed returns the response of the configured SeedSource for req, or nil if there is none. Only
+// 200 responses are used; a missing Date header is set to the current time.
+func (cc *CachedClient) seed(req *http.Request, cacheable bool) *http.Response {
+	if cc.Options.Seed == nil || !cacheable || req.Method != "GET" || cc.Options.WriteOnly {
+		return nil
+	}
+	resp, err := cc.Options.Seed.Seed(req)
+	if err != nil {
+		if err != ErrCacheMiss {
+			cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) seed source error (%v)", req, err))
+		}
+		return nil
+	}
+	if resp == nil {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		return nil
+	}
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	if resp.Body == nil {
+		resp.Body = http.NoBody
+	}
+	if resp.Request == nil {
+		resp.Request = req
+	}
+	if resp.Header.Get("Date") == "" {
+		resp.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	resp.Header.Set(seededHeader, "1")
+	return resp
+}
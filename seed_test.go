@@ -0,0 +1,78 @@
+package httpcache
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSeedSource(t *testing.T) {
+	var origin, seeded int32
+	seed := SeedSourceFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&seeded, 1)
+		switch req.URL.Path {
+		case "/seeded":
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Cache-Control": []string{"max-age=3600"}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString("from seed")),
+			}, nil
+		case "/broken":
+			return nil, errors.New("seed unavailable")
+		}
+		return nil, ErrCacheMiss
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&origin, 1)
+		w.Write([]byte("from origin"))
+	}))
+	defer server.Close()
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   CacheOptions{Seed: seed},
+	}
+
+	get := func(path string) string {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := get("/seeded"); got != "from seed" {
+			t.Fatalf("got body %q, want the seeded response", got)
+		}
+	}
+	if origin != 0 || seeded != 1 {
+		t.Fatalf("got %d origin and %d seed requests, want 0 and 1", origin, seeded)
+	}
+	req, _ := http.NewRequest("GET", server.URL+"/seeded", nil)
+	if meta, ok := client.EntryMetadata(req); !ok || !meta.Seeded || meta.Date.IsZero() {
+		t.Fatalf("got metadata %+v (found: %v), want a dated seeded entry", meta, ok)
+	}
+
+	for _, path := range []string{"/missing", "/broken"} {
+		if got := get(path); got != "from origin" {
+			t.Fatalf("%s: got body %q, want the origin response", path, got)
+		}
+	}
+	if origin != 2 {
+		t.Fatalf("got %d origin requests, want 2", origin)
+	}
+}
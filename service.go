@@ -0,0 +1,71 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type serviceCtxKey struct{}
+
+// WithService returns a copy of ctx carrying the logical service the requests made with it are
+// addressed to. See CacheOptions.Service.
+func WithService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceCtxKey{}, service)
+}
+
+// ServiceFromContext returns the logical service carried by ctx, or an empty string if none
+func ServiceFromContext(ctx context.Context) string {
+	service, _ := ctx.Value(serviceCtxKey{}).(string)
+	return service
+}
+
+// ServiceHosts returns a function for CacheOptions.Service mapping the hosts of requests to logical
+// services, such as the mirror hostnames of an API to its name. Hosts are matched case-insensitively,
+// including their port if any.
+func ServiceHosts(hosts map[string]string) func(req *http.Request) string {
+	services := make(map[string]string, len(hosts))
+	for host, service := range hosts {
+		services[strings.ToLower(host)] = service
+	}
+	return func(req *http.Request) string {
+		if req.URL == nil {
+			return ""
+		}
+		return services[strings.ToLower(req.URL.Host)]
+	}
+}
+
+// service returns the logical service of req: the one carried by the request context if any, and
+// the one returned by the Service option otherwise
+func (cc *CachedClient) service(req *http.Request) string {
+	if service := ServiceFromContext(req.Context()); service != "" {
+		return service
+	}
+	if cc.Options.Service != nil {
+		return cc.Options.Service(req)
+	}
+	return ""
+}
+
+// serviceURL returns u, or a copy of it addressed to service if not empty, as used in cache keys
+func serviceURL(u *url.URL, service string) *url.URL {
+	if service == "" || u == nil {
+		return u
+	}
+	s := *u
+	s.Scheme = "service"
+	s.Host = url.QueryEscape(service)
+	s.User = nil
+	return &s
+}
+
+// serviceBaseURL returns base addressed to its logical service, if any, for matching cache keys
+func (cc *CachedClient) serviceBaseURL(ctx context.Context, base *url.URL) *url.URL {
+	req, err := http.NewRequest("GET", base.String(), nil)
+	if err != nil {
+		return base
+	}
+	return serviceURL(base, cc.service(req.WithContext(ctx)))
+}
@@ -0,0 +1,50 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestService(t *testing.T) {
+	cache := NewMemoryCache()
+	client := &CachedClient{
+		Cache: cache,
+		Options: CacheOptions{Service: ServiceHosts(map[string]string{
+			"api-eu.example.com":      "billing",
+			"API-US.example.com:8443": "billing",
+		})},
+	}
+	key := func(rawURL string, ctx context.Context) string {
+		req, err := http.NewRequest("GET", rawURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.cacheKey(req.WithContext(ctx))
+	}
+	ctx := context.Background()
+
+	eu := key("https://api-eu.example.com/invoices?page=2", ctx)
+	if eu != "service://billing/invoices?page=2" {
+		t.Fatalf("got key %q, want the service key", eu)
+	}
+	if us := key("http://api-us.example.com:8443/invoices?page=2", ctx); us != eu {
+		t.Fatalf("got key %q for a mirror, want %q", us, eu)
+	}
+	if other := key("https://other.example.com/invoices?page=2", ctx); other != "https://other.example.com/invoices?page=2" {
+		t.Fatalf("got key %q for an unmapped host, want the raw URL", other)
+	}
+	if got := key("https://other.example.com/invoices", WithService(ctx, "billing")); got != "service://billing/invoices" {
+		t.Fatalf("got key %q with a context service, want the service key", got)
+	}
+
+	cache.Set(eu, []byte("entry"), 0)
+	cache.Set("https://other.example.com/invoices", []byte("entry"), 0)
+	removed, err := client.InvalidateCollection(ctx, "https://api-us.example.com:8443/invoices")
+	if err != nil || removed != 1 {
+		t.Fatalf("got %d removed (err: %v), want the service entry invalidated through a mirror", removed, err)
+	}
+	if left := cache.Keys(); len(left) != 1 || left[0] != "https://other.example.com/invoices" {
+		t.Fatalf("got keys %v left, want only the unmapped host entry", left)
+	}
+}
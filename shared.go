@@ -0,0 +1,39 @@
+package httpcache
+
+import (
+	"net/http"
+	"time"
+)
+
+// lifetime returns the freshness lifetime of a response generated at date. In shared mode (see
+// CacheOptions.SharedCache), s-maxage overrides max-age and Expires. Without explicit expiration
+// time, the heuristic lifetime recorded when it was stored applies. The lifetime assigned to an
+// entry when stored, through WithTTL or CacheOptions.ErrorTTL, overrides all of these.
+func (cc *CachedClient) lifetime(respHeaders http.Header, respCacheControl cacheControl, date time.Time) time.Duration {
+	if assigned, ok := parseDeltaSeconds(respHeaders.Get(lifetimeHeader)); ok {
+		return assigned
+	}
+	if cc.Options.SharedCache {
+		if sMaxAge, ok := parseDeltaSeconds(respCacheControl["s-maxage"]); ok {
+			return sMaxAge
+		}
+	}
+	if !hasExplicitExpiration(respHeaders, respCacheControl) {
+		if heuristic, ok := heuristicLifetime(respHeaders); ok {
+			return heuristic
+		}
+	}
+	return freshnessLifetime(respHeaders, respCacheControl, date)
+}
+
+// canShare returns true if resp, a response to req with the given cache control directives, can be
+// stored. Shared clients refuse private responses, unless the private directive lists field names,
+// and the responses to authorized requests are subject to the Authorization policy (see
+// AuthorizationPolicy).
+func (cc *CachedClient) canShare(req *http.Request, respCacheControl cacheControl) bool {
+	// A private directive listing field names only concerns these fields, which aren't stored
+	if fields, ok := respCacheControl["private"]; ok && cc.Options.SharedCache && len(fieldNames(fields)) == 0 {
+		return false
+	}
+	return cc.authorizationAllows(req, respCacheControl)
+}
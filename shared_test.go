@@ -0,0 +1,152 @@
+package httpcache
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSharedCacheStore(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		cacheControl  string
+		authorization bool
+		shared        bool
+		stored        bool
+	}{
+		{"private response", "max-age=60, private", false, false, true},
+		{"shared private response", "max-age=60, private", false, true, false},
+		{"shared public response", "max-age=60, public", false, true, true},
+		{"authorized", "max-age=60", true, false, true},
+		{"shared authorized", "max-age=60", true, true, false},
+		{"shared authorized public", "max-age=60, public", true, true, true},
+		{"shared authorized must-revalidate", "max-age=60, must-revalidate", true, true, true},
+		{"shared authorized s-maxage", "s-maxage=60", true, true, true},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", tc.cacheControl)
+			w.Write([]byte("body"))
+		}))
+		cache := NewMemoryCache()
+		client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{SharedCache: tc.shared}}
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		if tc.authorization {
+			req.Header.Set("Authorization", "Bearer token")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		server.Close()
+		if _, stored := cache.Get(cacheKey(req)); stored != tc.stored {
+			t.Fatalf("%s: got stored %v, want %v", tc.name, stored, tc.stored)
+		}
+	}
+}
+
+func TestSharedCacheFreshness(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	date := time.Now().UTC().Truncate(time.Second)
+	entry := func(cacheControl string) []byte {
+		return []byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nDate: %s\r\nCache-Control: %s\r\n\r\ncached",
+			date.Format(time.RFC1123), cacheControl))
+	}
+
+	for _, tc := range []struct {
+		name         string
+		entry        []byte
+		cacheControl string
+		shared       bool
+		cached       bool
+	}{
+		{"max-age", entry("max-age=60, s-maxage=10"), "", false, true},
+		{"s-maxage", entry("max-age=60, s-maxage=10"), "", true, false},
+		{"s-maxage longer", entry("max-age=10, s-maxage=60"), "", true, true},
+		{"proxy-revalidate", entry("max-age=10, proxy-revalidate"), "max-stale", false, true},
+		{"shared proxy-revalidate", entry("max-age=10, proxy-revalidate"), "max-stale", true, false},
+		{"shared s-maxage max-stale", entry("s-maxage=10"), "max-stale", true, false},
+	} {
+		clock = &fakeClock{elapsed: 30 * time.Second}
+		cache := NewMemoryCache()
+		client := &CachedClient{
+			Cache:     cache,
+			Transport: transportMock{err: errors.New("origin down")},
+			Options:   CacheOptions{SharedCache: tc.shared},
+		}
+		req, _ := http.NewRequest("GET", "http://example.com/data", nil)
+		if tc.cacheControl != "" {
+			req.Header.Set("Cache-Control", tc.cacheControl)
+		}
+		cache.Set(cacheKey(req), tc.entry, 0)
+
+		resp, err := client.Do(req)
+		if cached := err == nil; cached != tc.cached {
+			t.Fatalf("%s: got served from cache %v (err: %v), want %v", tc.name, cached, err, tc.cached)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestQualifiedDirectives(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		cacheControl string
+		shared       bool
+		cached       bool
+		stripped     []string
+	}{
+		{"no-cache", "max-age=60, no-cache", false, false, nil},
+		{"no-cache fields", `max-age=60, no-cache="Set-Cookie, X-Session"`, false, true, []string{"Set-Cookie", "X-Session"}},
+		{"private fields", `max-age=60, private="set-cookie"`, false, true, nil},
+		{"shared private fields", `max-age=60, private="set-cookie"`, true, true, []string{"Set-Cookie"}},
+	} {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Cache-Control", tc.cacheControl)
+			w.Header().Set("Set-Cookie", "session=1")
+			w.Header().Set("X-Session", "1")
+			w.Write([]byte("body"))
+		}))
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}, Options: CacheOptions{SharedCache: tc.shared}}
+		do := func() *http.Response {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			return resp
+		}
+
+		if resp := do(); resp.Header.Get("Set-Cookie") == "" {
+			t.Fatalf("%s: got the fields stripped from the origin response", tc.name)
+		}
+		resp := do()
+		server.Close()
+		if cached := requests == 1; cached != tc.cached {
+			t.Fatalf("%s: got served from cache %v, want %v", tc.name, cached, tc.cached)
+		}
+		if !tc.cached {
+			continue
+		}
+		for _, field := range tc.stripped {
+			if v := resp.Header.Get(field); v != "" {
+				t.Fatalf("%s: got %s %q from cache, want it stripped", tc.name, field, v)
+			}
+		}
+		if len(tc.stripped) == 0 && resp.Header.Get("Set-Cookie") == "" {
+			t.Fatalf("%s: got Set-Cookie stripped", tc.name)
+		}
+	}
+}
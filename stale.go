@@ -0,0 +1,81 @@
+package httpcache
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StaleLabels configures the headers added to stale JSON responses served from the cache, so that
+// downstream consumers can flag possibly outdated data. Responses are labeled when they are served
+// past their freshness lifetime (as allowed by a max-stale request directive), or in place of a
+// failed refresh (stale-if-error).
+type StaleLabels struct {
+	// Stale is the name of the header set to "true" on stale responses. Not set if empty.
+	Stale string
+	// AsOf is the name of the header set to the RFC 3339 timestamp of the Date of stale responses.
+	// Not set if empty or if the response has no Date.
+	AsOf string
+	// If set, Extra returns additional headers to set on stale responses
+	Extra func(meta EntryMetadata) http.Header
+}
+
+// DefaultStaleLabels returns StaleLabels setting the X-Data-Stale and X-Data-As-Of headers
+func DefaultStaleLabels() *StaleLabels {
+	return &StaleLabels{Stale: "X-Data-Stale", AsOf: "X-Data-As-Of"}
+}
+
+// Warning header values added to stale responses, unless Options.DisableStaleWarnings is set
+const (
+	staleWarning        = `110 - "Response is Stale"`
+	disconnectedWarning = `112 - "Disconnected Operation"`
+)
+
+// labelStale adds a stale Warning and the configured StaleLabels to resp, a stale response stored
+// under key about to be served from the cache. disconnected is true if resp is served because the
+// origin couldn't be reached.
+func (cc *CachedClient) labelStale(key string, resp *http.Response, disconnected bool) {
+	cc.emit(Event{Type: EventServeStale, Key: key})
+	if !cc.Options.DisableStaleWarnings {
+		resp.Header.Add("Warning", staleWarning)
+		if disconnected {
+			resp.Header.Add("Warning", disconnectedWarning)
+		}
+	}
+	labels := cc.Options.StaleLabels
+	if labels == nil || !isJSON(resp.Header) {
+		return
+	}
+	meta := GetEntryMetadata(resp)
+	if labels.Stale != "" {
+		resp.Header.Set(labels.Stale, "true")
+	}
+	if labels.AsOf != "" && !meta.Date.IsZero() {
+		resp.Header.Set(labels.AsOf, meta.Date.UTC().Format(time.RFC3339))
+	}
+	if labels.Extra != nil {
+		for k, v := range labels.Extra(meta) {
+			resp.Header[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+}
+
+// isJSON returns true if the Content-Type of a response is JSON, including +json structured types
+func isJSON(respHeaders http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(respHeaders.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// expired returns true if a response is older than its freshness lifetime, regardless of any
+// request directive
+func (cc *CachedClient) expired(respHeaders http.Header) bool {
+	date, err := responseDate(respHeaders)
+	if err != nil {
+		return true
+	}
+	return currentAge(respHeaders, date) >= cc.lifetime(respHeaders, parseCacheControl(respHeaders), date)
+}
@@ -0,0 +1,109 @@
+package httpcache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStaleLabels(t *testing.T) {
+	resetTest()
+	date := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	entry := func(contentType string, maxAge int) []byte {
+		return []byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nDate: %s\r\nCache-Control: max-age=%d, stale-if-error\r\nContent-Type: %s\r\n\r\n{}",
+			date.Format(time.RFC1123), maxAge, contentType))
+	}
+	labels := DefaultStaleLabels()
+	labels.Extra = func(meta EntryMetadata) http.Header {
+		return http.Header{"X-Data-Age": []string{"old"}}
+	}
+
+	for _, tc := range []struct {
+		name         string
+		entry        []byte
+		cacheControl string
+		labeled      bool
+	}{
+		{"max-stale", entry("application/json", 60), "max-stale", true},
+		{"stale-if-error", entry("application/problem+json; charset=utf-8", 60), "", true},
+		{"not json", entry("text/plain", 60), "max-stale", false},
+		{"fresh", entry("application/json", 7200), "", false},
+	} {
+		cache := NewMemoryCache()
+		client := &CachedClient{
+			Cache:     cache,
+			Transport: transportMock{err: errors.New("origin down")},
+			Options:   CacheOptions{StaleLabels: labels},
+		}
+		req, err := http.NewRequest("GET", "http://example.com/data", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.cacheControl != "" {
+			req.Header.Set("Cache-Control", tc.cacheControl)
+		}
+		cache.Set(cacheKey(req), tc.entry, 0)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("X-Data-Stale") == "true"; got != tc.labeled {
+			t.Fatalf("%s: got labeled %v, want %v", tc.name, got, tc.labeled)
+		}
+		if !tc.labeled {
+			continue
+		}
+		if got, want := resp.Header.Get("X-Data-As-Of"), date.Format(time.RFC3339); got != want {
+			t.Fatalf("%s: got X-Data-As-Of %q, want %q", tc.name, got, want)
+		}
+		if got := resp.Header.Get("X-Data-Age"); got != "old" {
+			t.Fatalf("%s: got X-Data-Age %q, want the extra header", tc.name, got)
+		}
+	}
+}
+
+func TestStaleWarnings(t *testing.T) {
+	resetTest()
+	date := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	entry := []byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nDate: %s\r\nCache-Control: max-age=60, stale-if-error\r\n\r\nbody",
+		date.Format(time.RFC1123)))
+
+	for _, tc := range []struct {
+		name         string
+		cacheControl string
+		transport    http.RoundTripper
+		disable      bool
+		want         []string
+	}{
+		{"max-stale", "max-stale", transportMock{err: errors.New("unused")}, false, []string{staleWarning}},
+		{"disconnected", "", transportMock{err: errors.New("origin down")}, false, []string{staleWarning, disconnectedWarning}},
+		{"origin error", "", transportMock{response: &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody, Header: http.Header{}}}, false, []string{staleWarning}},
+		{"disabled", "max-stale", transportMock{err: errors.New("unused")}, true, nil},
+	} {
+		cache := NewMemoryCache()
+		client := &CachedClient{
+			Cache:     cache,
+			Transport: tc.transport,
+			Options:   CacheOptions{DisableStaleWarnings: tc.disable},
+		}
+		req, _ := http.NewRequest("GET", "http://example.com/data", nil)
+		if tc.cacheControl != "" {
+			req.Header.Set("Cache-Control", tc.cacheControl)
+		}
+		cache.Set(cacheKey(req), entry, 0)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		got := resp.Header["Warning"]
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Fatalf("%s: got Warning %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
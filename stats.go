@@ -0,0 +1,81 @@
+package httpcache
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of the effectiveness of a CachedClient, see CachedClient.Stats
+type Stats struct {
+	// Hits and Misses count the requests for which a fresh entry was found or not. Requests finding
+	// a stale entry count as neither.
+	Hits, Misses int64
+	// NotModified counts the stale entries the origin confirmed with a 304 response
+	NotModified int64
+	// StaleIfError counts the stale entries served because the origin failed (RFC 5861)
+	StaleIfError int64
+	// BytesServed is the total size of the response bodies served from the cache
+	BytesServed int64
+	// UpstreamAvoided counts the requests served from the cache without contacting the origin
+	UpstreamAvoided int64
+}
+
+// HitRatio returns the share of hits among the hits and misses
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// clientStats holds the counters behind Stats, updated atomically
+type clientStats struct {
+	hits, misses, notModified, staleIfError, bytesServed, upstreamAvoided int64
+}
+
+// counters returns the counters of the client, shared by canary arms with their parent
+func (cc *CachedClient) counters() *clientStats {
+	owner := cc.owner()
+	owner.statsOnce.Do(func() {
+		owner.stats = &clientStats{}
+	})
+	return owner.stats
+}
+
+// Stats returns a snapshot of the counters of the client, which are kept since it was created
+func (cc *CachedClient) Stats() Stats {
+	c := cc.counters()
+	return Stats{
+		Hits:            atomic.LoadInt64(&c.hits),
+		Misses:          atomic.LoadInt64(&c.misses),
+		NotModified:     atomic.LoadInt64(&c.notModified),
+		StaleIfError:    atomic.LoadInt64(&c.staleIfError),
+		BytesServed:     atomic.LoadInt64(&c.bytesServed),
+		UpstreamAvoided: atomic.LoadInt64(&c.upstreamAvoided),
+	}
+}
+
+// countDecision counts the hits and misses reported by e
+func (cc *CachedClient) countDecision(e Event) {
+	if e.Type != EventDecision {
+		return
+	}
+	switch e.Decision {
+	case DecisionHit:
+		atomic.AddInt64(&cc.counters().hits, 1)
+	case DecisionMiss:
+		atomic.AddInt64(&cc.counters().misses, 1)
+	}
+}
+
+// countServed counts resp, a response being served from the cache, and whether the origin was
+// contacted for it
+func (cc *CachedClient) countServed(resp *http.Response, contacted bool) {
+	c := cc.counters()
+	if resp.ContentLength > 0 {
+		atomic.AddInt64(&c.bytesServed, resp.ContentLength)
+	}
+	if !contacted {
+		atomic.AddInt64(&c.upstreamAvoided, 1)
+	}
+}
@@ -0,0 +1,55 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	var failing int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=10, stale-if-error=3600")
+		w.Header().Set("Etag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+	get := func() {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	get()
+	get()
+	clock = &fakeClock{elapsed: 20 * time.Second}
+	get()
+	atomic.StoreInt32(&failing, 1)
+	get()
+
+	want := Stats{Hits: 1, Misses: 1, NotModified: 1, StaleIfError: 1, BytesServed: 12, UpstreamAvoided: 1}
+	if got := client.Stats(); got != want {
+		t.Fatalf("got stats %+v, want %+v", got, want)
+	}
+	if got := client.Stats().HitRatio(); got != 0.5 {
+		t.Fatalf("got hit ratio %v, want 0.5", got)
+	}
+}
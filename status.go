@@ -0,0 +1,60 @@
+package httpcache
+
+import "net/http"
+
+// HeuristicallyCacheableStatusCodes are the status codes of the responses that can be stored
+// without explicit expiration time, as defined by RFC 9110 section 15.1, to be used as
+// CacheOptions.CacheableStatusCodes. Partial (206) responses are handled apart (see
+// CacheOptions.StorePartial).
+var HeuristicallyCacheableStatusCodes = []int{
+	http.StatusOK,
+	http.StatusNonAuthoritativeInfo,
+	http.StatusNoContent,
+	http.StatusMultipleChoices,
+	http.StatusMovedPermanently,
+	http.StatusPermanentRedirect,
+	http.StatusNotFound,
+	http.StatusMethodNotAllowed,
+	http.StatusGone,
+	http.StatusRequestURITooLong,
+	http.StatusNotImplemented,
+}
+
+// heuristicallyCacheable returns true if code is one of HeuristicallyCacheableStatusCodes
+func heuristicallyCacheable(code int) bool {
+	return containsStatus(HeuristicallyCacheableStatusCodes, code)
+}
+
+func containsStatus(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// storableStatus returns true if the status code of resp allows storing it. If
+// Options.CacheableStatusCodes is set, it must list the status code, and responses whose status code
+// isn't heuristically cacheable also need an explicit expiration time. Error responses are always
+// storable if Options.ErrorTTL is set.
+func (cc *CachedClient) storableStatus(resp *http.Response) bool {
+	codes := cc.Options.CacheableStatusCodes
+	if codes == nil || (cc.Options.ErrorTTL > 0 && errorStatus(resp.StatusCode)) {
+		return true
+	}
+	if !containsStatus(codes, resp.StatusCode) {
+		return false
+	}
+	return heuristicallyCacheable(resp.StatusCode) || hasExplicitExpiration(resp.Header, parseCacheControl(resp.Header))
+}
+
+// errorStatus returns true if code is the status code of an error response that Options.ErrorTTL
+// applies to: 404, 410, 429 or a server error
+func errorStatus(code int) bool {
+	switch code {
+	case http.StatusNotFound, http.StatusGone, http.StatusTooManyRequests:
+		return true
+	}
+	return code >= 500 && code < 600
+}
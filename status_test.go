@@ -0,0 +1,112 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheableStatusCodes(t *testing.T) {
+	resetTest()
+	for _, c := range []struct {
+		code         int
+		cacheControl string
+		codes        []int
+		lifetimes    map[int]time.Duration
+		requests     int
+	}{
+		// No policy: stored whatever the status, served while fresh
+		{http.StatusInternalServerError, "max-age=60", nil, nil, 1},
+		{http.StatusNotFound, "max-age=60", HeuristicallyCacheableStatusCodes, nil, 1},
+		{http.StatusNotFound, "max-age=60", []int{http.StatusOK}, nil, 3},
+		// Not heuristically cacheable: needs an explicit expiration time
+		{http.StatusInternalServerError, "max-age=60", []int{http.StatusInternalServerError}, nil, 1},
+		{http.StatusInternalServerError, "public", []int{http.StatusInternalServerError},
+			map[int]time.Duration{http.StatusInternalServerError: time.Minute}, 3},
+		// Heuristic lifetimes
+		{http.StatusGone, "", HeuristicallyCacheableStatusCodes, map[int]time.Duration{http.StatusGone: time.Minute}, 1},
+		{http.StatusGone, "", HeuristicallyCacheableStatusCodes, nil, 3},
+		{http.StatusGone, "max-age=0", HeuristicallyCacheableStatusCodes, map[int]time.Duration{http.StatusGone: time.Minute}, 3},
+	} {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if c.cacheControl != "" {
+				w.Header().Set("Cache-Control", c.cacheControl)
+			}
+			w.WriteHeader(c.code)
+			w.Write([]byte("body"))
+		}))
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+			Options: CacheOptions{CacheableStatusCodes: c.codes, HeuristicLifetimes: c.lifetimes}}
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != c.code {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, c.code)
+			}
+		}
+		server.Close()
+		if requests != c.requests {
+			t.Fatalf("%d with %q and codes %v: got %d origin requests, want %d", c.code, c.cacheControl, c.codes, requests, c.requests)
+		}
+	}
+}
+
+func TestErrorTTL(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	for _, c := range []struct {
+		code         int
+		cacheControl string
+		requests     int
+	}{
+		{http.StatusNotFound, "", 1},
+		{http.StatusServiceUnavailable, "max-age=0", 1},
+		{http.StatusTooManyRequests, "no-store", 2},
+		{http.StatusForbidden, "", 2},
+	} {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if c.cacheControl != "" {
+				w.Header().Set("Cache-Control", c.cacheControl)
+			}
+			w.WriteHeader(c.code)
+		}))
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{},
+			Options: CacheOptions{ErrorTTL: 10 * time.Second, CacheableStatusCodes: []int{http.StatusOK}}}
+		do := func() {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != c.code {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, c.code)
+			}
+		}
+		clock = &fakeClock{}
+		do()
+		clock = &fakeClock{elapsed: 5 * time.Second}
+		do()
+		if requests != c.requests {
+			t.Fatalf("%d with %q: got %d origin requests, want %d", c.code, c.cacheControl, requests, c.requests)
+		}
+		clock = &fakeClock{elapsed: 15 * time.Second}
+		do()
+		server.Close()
+		if requests != c.requests+1 {
+			t.Fatalf("%d with %q: got %d origin requests past ErrorTTL, want %d", c.code, c.cacheControl, requests, c.requests+1)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package httpcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A StreamingCache is a Cache or CacheV2 backend that can also read and write entries as streams.
+// When the backend of a CachedClient implements it, GET responses are teed into the cache while
+// they are streamed to the caller, instead of being buffered whole in memory.
+type StreamingCache interface {
+	// GetReader returns a reader over the stored representation of a response and true if
+	// present, false if not. The caller closes the reader when done.
+	GetReader(key string) (r io.ReadCloser, ok bool)
+	// SetWriter returns a writer storing a response for a given key. The entry must not become
+	// visible to readers until the writer is closed. Writers should implement AbortWriter, so
+	// that the entries of bodies that aren't read to the end are discarded without being committed.
+	SetWriter(key string, ttl int) (w io.WriteCloser, err error)
+}
+
+// An AbortWriter is a writer returned by StreamingCache.SetWriter that can discard the entry being
+// written. Entries of writers that don't implement it are committed by Close and deleted right after,
+// so concurrent readers may be served them truncated in between.
+type AbortWriter interface {
+	io.WriteCloser
+	// Abort discards the entry being written without making it visible to readers
+	Abort() error
+}
+
+// abortWrite discards the entry being written by w, through Abort if w is an AbortWriter. Otherwise
+// the partial entry is committed by Close, and true is returned so that it can be deleted.
+func abortWrite(w io.WriteCloser) bool {
+	if aw, ok := w.(AbortWriter); ok {
+		aw.Abort()
+		return false
+	}
+	w.Close()
+	return true
+}
+
+// streamingCache returns the StreamingCache of the client backend, if any and ctx doesn't carry a
+// scratch cache, which must receive the writes instead
+func (cc *CachedClient) streamingCache(ctx context.Context) (StreamingCache, bool) {
+	if scratchLayerFromContext(ctx) != nil {
+		return nil, false
+	}
+	if cc.CacheV2 != nil {
+		sc, ok := cc.CacheV2.(StreamingCache)
+		return sc, ok
+	}
+	sc, ok := cc.Cache.(StreamingCache)
+	return sc, ok
+}
+
+// cachedResponseStream returns the http.Response stored in sc under key, or ErrCacheMiss if
+// there is none. Closing the response body closes the underlying cache reader.
+func cachedResponseStream(sc StreamingCache, key string, req *http.Request) (*http.Response, error) {
+	r, ok := sc.GetReader(key)
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(r), req)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	resp.Body = &streamBody{Reader: resp.Body, closers: []io.Closer{resp.Body, r}}
+	return resp, nil
+}
+
+type streamBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *streamBody) Close() error {
+	var err error
+	for _, c := range b.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// writeResponseHead writes the status line and headers of resp in a form that http.ReadResponse
+// can parse back, followed by the unencoded body. The body is delimited by Content-Length when
+// known and by the end of the stream otherwise.
+func writeResponseHead(w io.Writer, resp *http.Response) error {
+	major, minor := resp.ProtoMajor, resp.ProtoMinor
+	if major == 0 {
+		major, minor = 1, 1
+	}
+	status := resp.Status
+	if !strings.HasPrefix(status, strconv.Itoa(resp.StatusCode)+" ") {
+		status = fmt.Sprintf("%03d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	if _, err := fmt.Fprintf(w, "HTTP/%d.%d %s\r\n", major, minor, status); err != nil {
+		return err
+	}
+
+	header := make(http.Header, len(resp.Header))
+	for k, v := range resp.Header {
+		header[k] = v
+	}
+	header.Del("Transfer-Encoding")
+	header.Del("Content-Length")
+	if resp.ContentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	if err := header.Write(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+// streamEntry tees the body of resp, a response to req, into a new entry of sc for key. The entry is
+// committed when the body is read to EOF, and discarded if the body is closed early, the write fails
+// or key was invalidated since started, when the request fetching it started.
+func (cc *CachedClient) streamEntry(req *http.Request, sc StreamingCache, key string, resp *http.Response, started time.Time) error {
+	ctx := req.Context()
+	if cc.buriedSince(key, started) {
+		return fmt.Errorf("httpcache: entry invalidated while being fetched")
+	}
+	w, err := sc.SetWriter(key, cc.entryTTL(ctx, key))
+	if err != nil {
+		return err
+	}
+	if err := writeResponseHead(w, cc.entryResponse(req, resp)); err != nil {
+		if abortWrite(w) {
+			cc.backend(ctx).Delete(ctx, key)
+		}
+		return err
+	}
+	cc.purgeDerived(ctx, key)
+	cc.emit(Event{Type: EventStore, Key: key, Size: -1})
+	resp.Body = &teeReadCloser{
+		R: resp.Body,
+		W: w,
+		OnAbort: func() {
+			cc.backend(ctx).Delete(ctx, key)
+		},
+		OnCommit: func() {
+			unlock := cc.owner().keyLocks.lock(key)
+			defer unlock()
+			if cc.buriedSince(key, started) {
+				cc.backend(ctx).Delete(ctx, key)
+				return
+			}
+			cc.negativeLookups(ctx).forget(key)
+		},
+	}
+	return nil
+}
+
+// teeReadCloser is a wrapper around ReadCloser R that copies everything read from it into W,
+// closing W when EOF is reached. If R is closed before EOF or W fails, W is aborted (see
+// AbortWriter). OnAbort is only called if W can't abort, to delete the partial copy committed in
+// place of the entry instead: the entry already stored, if any, is kept otherwise. OnCommit, if
+// set, is called once W is closed.
+type teeReadCloser struct {
+	R        io.ReadCloser
+	W        io.WriteCloser
+	OnAbort  func()
+	OnCommit func()
+
+	done   bool
+	failed bool
+}
+
+func (t *teeReadCloser) Read(p []byte) (n int, err error) {
+	n, err = t.R.Read(p)
+	if n > 0 && !t.failed && !t.done {
+		if _, werr := t.W.Write(p[:n]); werr != nil {
+			t.abort()
+		}
+	}
+	if err == io.EOF && !t.done {
+		t.done = true
+		if !t.failed {
+			if cerr := t.W.Close(); cerr != nil {
+				t.failed = true
+			} else if t.OnCommit != nil {
+				t.OnCommit()
+			}
+		}
+	}
+	return n, err
+}
+
+func (t *teeReadCloser) Close() error {
+	if !t.done {
+		t.done = true
+		t.abort()
+	}
+	return t.R.Close()
+}
+
+func (t *teeReadCloser) abort() {
+	if t.failed {
+		return
+	}
+	t.failed = true
+	if abortWrite(t.W) {
+		t.OnAbort()
+	}
+}
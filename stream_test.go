@@ -0,0 +1,163 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryStreamingCache is a MemoryCache implementing StreamingCache, committing written
+// entries on Close and counting the readers left open and the entries committed
+type memoryStreamingCache struct {
+	*MemoryCache
+	mu          sync.Mutex
+	openReaders int
+	commits     int
+}
+
+type countingReader struct {
+	io.Reader
+	c *memoryStreamingCache
+}
+
+func (r *countingReader) Close() error {
+	r.c.mu.Lock()
+	r.c.openReaders--
+	r.c.mu.Unlock()
+	return nil
+}
+
+type commitWriter struct {
+	bytes.Buffer
+	commit func([]byte)
+}
+
+func (w *commitWriter) Close() error {
+	w.commit(w.Bytes())
+	return nil
+}
+
+func (w *commitWriter) Abort() error {
+	return nil
+}
+
+func (c *memoryStreamingCache) GetReader(key string) (io.ReadCloser, bool) {
+	val, ok := c.Get(key)
+	if !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	c.openReaders++
+	c.mu.Unlock()
+	return &countingReader{Reader: bytes.NewReader(val), c: c}, true
+}
+
+func (c *memoryStreamingCache) SetWriter(key string, ttl int) (io.WriteCloser, error) {
+	return &commitWriter{commit: func(b []byte) {
+		c.mu.Lock()
+		c.commits++
+		c.mu.Unlock()
+		c.Set(key, b, ttl)
+	}}, nil
+}
+
+func TestStreamingCache(t *testing.T) {
+	resetTest()
+	body := strings.Repeat("streamed content ", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if r.URL.Path == "/chunked" {
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/sized", "/chunked"} {
+		cache := &memoryStreamingCache{MemoryCache: NewMemoryCache()}
+		client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{MarkCachedResponses: true}}
+
+		for i := 0; i < 2; i++ {
+			req, err := http.NewRequest("GET", server.URL+path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != body {
+				t.Fatalf("%s: got a body of %d bytes, want %d", path, len(got), len(body))
+			}
+			if fromCache := resp.Header.Get(XFromCache) == "1"; fromCache != (i == 1) {
+				t.Fatalf("%s: request %d served from cache: %v", path, i, fromCache)
+			}
+		}
+		if cache.openReaders != 0 {
+			t.Fatalf("%s: %d cache readers left open", path, cache.openReaders)
+		}
+	}
+}
+
+func TestStreamingCacheAbortedBody(t *testing.T) {
+	resetTest()
+	cache := &memoryStreamingCache{MemoryCache: NewMemoryCache()}
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}}
+
+	req, err := http.NewRequest("GET", s.server.URL+"/method", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, ok := cache.Get(cacheKey(req)); ok {
+		t.Fatal("partially read response was stored")
+	}
+	if cache.commits != 0 {
+		t.Fatal("partially read response was committed before being discarded")
+	}
+}
+
+func TestStreamingCacheAbortedRefresh(t *testing.T) {
+	resetTest()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	cache := &memoryStreamingCache{MemoryCache: NewMemoryCache()}
+	client := &CachedClient{Cache: cache, Transport: &http.Transport{}}
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// The stale entry is refreshed, but the new body isn't read to the end
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, ok := cache.Get(cacheKey(req)); !ok {
+		t.Fatal("stored entry was deleted when the write of its refresh was aborted")
+	}
+}
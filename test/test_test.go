@@ -2,6 +2,7 @@ package test_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/lggomez/httpcache/v2"
 	"github.com/lggomez/httpcache/v2/test"
@@ -10,3 +11,49 @@ import (
 func TestMemoryCache(t *testing.T) {
 	test.Cache(t, httpcache.NewMemoryCache())
 }
+
+func TestTieredCache(t *testing.T) {
+	test.Cache(t, httpcache.NewTieredCache(httpcache.NewMemoryCache(), httpcache.NewMemoryCache()))
+}
+
+func TestLRUCache(t *testing.T) {
+	test.Cache(t, httpcache.NewLRUCache(100, 1<<20))
+}
+
+func TestIndexedCache(t *testing.T) {
+	test.Cache(t, httpcache.NewIndexedCache(httpcache.NewMemoryCache(), nil))
+}
+
+func TestCompressedCache(t *testing.T) {
+	test.Cache(t, httpcache.NewCompressedCache(httpcache.NewMemoryCache(), httpcache.GzipCodec{}))
+}
+
+func TestEncryptedCache(t *testing.T) {
+	c, err := httpcache.NewEncryptedCache(httpcache.NewMemoryCache(), make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Cache(t, c)
+}
+
+func TestHashedKeyCache(t *testing.T) {
+	test.Cache(t, httpcache.NewHashedKeyCache(httpcache.NewMemoryCache(), 16))
+}
+
+func TestNamespacedCache(t *testing.T) {
+	test.Cache(t, httpcache.NewNamespacedCache(httpcache.NewMemoryCache(), "ns:"))
+}
+
+func TestInstrumentedCache(t *testing.T) {
+	test.Cache(t, httpcache.NewInstrumentedCache(httpcache.NewMemoryCache(), &httpcache.CounterMetrics{}))
+}
+
+func TestCircuitBreakerCache(t *testing.T) {
+	test.Cache(t, httpcache.AdaptCacheV2(httpcache.NewCircuitBreakerCache(httpcache.AdaptCache(httpcache.NewMemoryCache()), httpcache.CircuitBreakerOptions{})))
+}
+
+func TestTieredCacheWithTombstones(t *testing.T) {
+	tc := httpcache.NewTieredCache(httpcache.NewMemoryCache(), httpcache.NewMemoryCache())
+	tc.TombstoneWindow = time.Minute
+	test.Cache(t, tc)
+}
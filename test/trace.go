@@ -0,0 +1,83 @@
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lggomez/httpcache/v2"
+)
+
+// UpdateGoldenEnv is the environment variable which, when set to a non-empty value, makes Golden
+// rewrite the golden files with the output of the tests instead of comparing them
+const UpdateGoldenEnv = "HTTPCACHE_UPDATE_GOLDEN"
+
+// DecisionTrace is a httpcache.Observer recording the decisions of a CachedClient as text, one
+// line per event, for comparison against a golden file (see Golden). Only the deterministic parts
+// of the events are recorded: times, latencies, ages and entry sizes are left out. Requests must be
+// made one at a time for the order of the lines to be deterministic.
+type DecisionTrace struct {
+	mu       sync.Mutex
+	replacer *strings.Replacer
+	lines    []string
+}
+
+// NewDecisionTrace returns an empty DecisionTrace. replacements are old, new string pairs applied
+// to the recorded keys, typically to replace the random address of a httptest.Server with a fixed
+// placeholder.
+func NewDecisionTrace(replacements ...string) *DecisionTrace {
+	return &DecisionTrace{replacer: strings.NewReplacer(replacements...)}
+}
+
+// Observe records e
+func (dt *DecisionTrace) Observe(e httpcache.Event) {
+	line := e.Type.String()
+	switch e.Type {
+	case httpcache.EventDecision:
+		line += " " + e.Decision.String()
+	case httpcache.EventRevalidate, httpcache.EventUpstream:
+		line += fmt.Sprintf(" %d", e.Status)
+	}
+	line += " " + dt.replacer.Replace(e.Key)
+	dt.mu.Lock()
+	dt.lines = append(dt.lines, line)
+	dt.mu.Unlock()
+}
+
+// Note records a comment line, usually to introduce the events of the next request
+func (dt *DecisionTrace) Note(format string, args ...interface{}) {
+	dt.mu.Lock()
+	dt.lines = append(dt.lines, "# "+fmt.Sprintf(format, args...))
+	dt.mu.Unlock()
+}
+
+// String returns the recorded lines
+func (dt *DecisionTrace) String() string {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+	if len(dt.lines) == 0 {
+		return ""
+	}
+	return strings.Join(dt.lines, "\n") + "\n"
+}
+
+// Golden compares got with the content of the golden file at path, failing t if they differ.
+// When the UpdateGoldenEnv environment variable is set, the file is written with got instead.
+func Golden(t *testing.T, path string, got string) {
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to create it)", err, UpdateGoldenEnv)
+	}
+	if got != string(want) {
+		t.Fatalf("trace differs from %s (set %s=1 to update it)\ngot:\n%s\nwant:\n%s", path, UpdateGoldenEnv, got, want)
+	}
+}
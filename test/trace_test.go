@@ -0,0 +1,45 @@
+package test_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+func TestDecisionTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=3600")
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	trace := test.NewDecisionTrace(server.URL, "{origin}")
+	client := &httpcache.CachedClient{
+		Cache:     httpcache.NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options:   httpcache.CacheOptions{Observer: trace},
+	}
+	for _, r := range []struct{ method, path string }{
+		{"GET", "/"},
+		{"GET", "/"},
+		{"POST", "/"},
+		{"GET", "/private"},
+	} {
+		trace.Note("%s %s", r.method, r.path)
+		req, _ := http.NewRequest(r.method, server.URL+r.path, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	test.Golden(t, "testdata/decisions.golden", trace.String())
+}
@@ -0,0 +1,402 @@
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// tieredAccessKey is the back tier key under which TieredCache persists its access metadata
+const tieredAccessKey = "httpcache/tiered/access-metadata"
+
+// tombstonePrefix starts the tombstone records TieredCache stores in place of deleted entries
+const tombstonePrefix = "httpcache-tombstone "
+
+// WarmOrder defines which entries TieredCache.Warm loads first
+type WarmOrder int
+
+const (
+	// WarmMostRecent loads the most recently accessed entries first
+	WarmMostRecent WarmOrder = iota
+	// WarmMostFrequent loads the most frequently accessed entries first
+	WarmMostFrequent
+)
+
+// TieredCache is a Cache composed of a fast front tier (usually in-memory) and a slower
+// back tier (usually persistent or remote). Writes go to both tiers, while reads check the
+// front tier first and promote back tier hits into it.
+//
+// TieredCache keeps the last access time of every key it has seen, so entries that have not
+// been used for a while can be demoted out of the front tier to keep it small. Keys are forgotten
+// once their TTL has passed, or once a Get finds them in neither tier.
+type TieredCache struct {
+	Front Cache
+	Back  Cache
+	// PromoteTTL is the front tier TTL, in seconds, of the back tier hits whose TTL isn't known
+	// because they were stored by another process sharing the back tier. It bounds how long the
+	// front tier can serve an entry that was replaced or removed remotely. 0 means no expiry.
+	PromoteTTL int
+	// TombstoneWindow, if positive, makes Delete store a timestamped tombstone record in both tiers
+	// instead of removing the entry, for this long. Tombstones are read as misses, and during the
+	// window no tier accepts a copy of the entry whose Date isn't later than the deletion: neither
+	// the promotion of a copy read from a slower tier, nor the write of a response fetched before
+	// the deletion by another process sharing the back tier. Copies without a Date are refused too.
+	// Set reads both tiers first to find their tombstones.
+	TombstoneWindow time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]tierEntry
+	tombstones map[string]time.Time
+	sweepAt    int // size of entries that triggers the next sweep of the expired ones
+}
+
+type tierEntry struct {
+	accessed time.Time
+	hits     int
+	// ttl is the TTL, in seconds, of the entry stored at stored. 0 means no expiry.
+	ttl     int
+	stored  time.Time
+	inFront bool
+}
+
+// expired returns true if the TTL of the entry has passed
+func (e tierEntry) expired() bool {
+	return e.ttl > 0 && clock.since(e.stored) >= time.Duration(e.ttl)*time.Second
+}
+
+// remainingTTL returns the TTL, in seconds, left to the entry, so that the copies loaded into the
+// front tier don't outlive the back tier one. 0 means no expiry.
+func (e tierEntry) remainingTTL() int {
+	if e.ttl <= 0 {
+		return 0
+	}
+	return int(ttlSeconds(time.Duration(e.ttl)*time.Second - clock.since(e.stored)))
+}
+
+// tierAccessRecord is the persisted form of a tierEntry
+type tierAccessRecord struct {
+	Key      string    `json:"key"`
+	Accessed time.Time `json:"accessed"`
+	Hits     int       `json:"hits"`
+	TTL      int       `json:"ttl"`
+	Stored   time.Time `json:"stored"`
+}
+
+// NewTieredCache returns a new TieredCache using front and back as its tiers
+func NewTieredCache(front, back Cache) *TieredCache {
+	return &TieredCache{Front: front, Back: back, entries: map[string]tierEntry{}}
+}
+
+// Get returns the []byte representation of the response and true if present in any tier,
+// promoting back tier hits into the front tier
+func (tc *TieredCache) Get(key string) (resp []byte, ok bool) {
+	if resp, ok = tc.Front.Get(key); ok {
+		if _, buried := parseTombstone(resp); buried {
+			return nil, false
+		}
+		tc.touch(key, true)
+		return resp, true
+	}
+	if resp, ok = tc.Back.Get(key); !ok {
+		tc.mu.Lock()
+		delete(tc.entries, key)
+		tc.mu.Unlock()
+		return nil, false
+	}
+	if at, buried := parseTombstone(resp); buried {
+		// Shadow the back tier until the tombstone expires
+		if ttl := tc.tombstoneTTL(at); ttl > 0 {
+			tc.Front.Set(key, resp, ttl)
+		}
+		return nil, false
+	}
+	if !tc.acceptable(key, resp, nil) {
+		return nil, false
+	}
+
+	tc.mu.Lock()
+	e, known := tc.entries[key]
+	if !known || e.expired() {
+		// Tracked as long as the front tier copy lives
+		e.ttl, e.stored = tc.PromoteTTL, time.Now()
+		tc.entries[key] = e
+		tc.sweepLocked()
+	}
+	tc.mu.Unlock()
+	tc.Front.Set(key, resp, e.remainingTTL())
+	tc.touch(key, true)
+	return resp, true
+}
+
+// Set saves response resp with key in both tiers, unless it is older than a tombstone for key
+func (tc *TieredCache) Set(key string, resp []byte, ttl int) {
+	if !tc.acceptable(key, resp, tc.Front) || !tc.acceptable(key, resp, tc.Back) {
+		return
+	}
+	tc.Front.Set(key, resp, ttl)
+	tc.Back.Set(key, resp, ttl)
+
+	tc.mu.Lock()
+	e := tc.entries[key]
+	now := time.Now()
+	tc.entries[key] = tierEntry{accessed: now, hits: e.hits, ttl: ttl, stored: now, inFront: true}
+	tc.sweepLocked()
+	tc.mu.Unlock()
+}
+
+// sweepLocked forgets the keys whose TTL has passed, once entries has grown enough since the last
+// sweep for its cost to be amortized. tc.mu must be held.
+func (tc *TieredCache) sweepLocked() {
+	if len(tc.entries) < tc.sweepAt {
+		return
+	}
+	for key, e := range tc.entries {
+		if e.expired() {
+			delete(tc.entries, key)
+		}
+	}
+	tc.sweepAt = 2*len(tc.entries) + 64
+}
+
+// Delete removes key from both tiers, replacing it with a tombstone if TombstoneWindow is set
+func (tc *TieredCache) Delete(key string) {
+	if tc.TombstoneWindow <= 0 {
+		tc.Front.Delete(key)
+		tc.Back.Delete(key)
+		tc.mu.Lock()
+		delete(tc.entries, key)
+		tc.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	tc.mu.Lock()
+	delete(tc.entries, key)
+	if tc.tombstones == nil {
+		tc.tombstones = map[string]time.Time{}
+	}
+	for k, at := range tc.tombstones {
+		if clock.since(at) >= tc.TombstoneWindow {
+			delete(tc.tombstones, k)
+		}
+	}
+	tc.tombstones[key] = now
+	tc.mu.Unlock()
+
+	record := []byte(tombstonePrefix + now.UTC().Format(time.RFC3339Nano))
+	ttl := tc.tombstoneTTL(now)
+	tc.Front.Set(key, record, ttl)
+	tc.Back.Set(key, record, ttl)
+}
+
+// tombstoneTTL returns the TTL, in seconds, of a tombstone recorded at, or 0 if it expired
+func (tc *TieredCache) tombstoneTTL(at time.Time) int {
+	remaining := tc.TombstoneWindow - clock.since(at)
+	if remaining <= 0 {
+		return 0
+	}
+	return int((remaining + time.Second - 1) / time.Second)
+}
+
+// acceptable returns false if resp is a copy of an entry deleted after it was fetched, according to
+// the tombstones known locally and, if tier isn't nil, the tombstone stored in tier
+func (tc *TieredCache) acceptable(key string, resp []byte, tier Cache) bool {
+	if tc.TombstoneWindow <= 0 {
+		return true
+	}
+	tc.mu.Lock()
+	at, buried := tc.tombstones[key]
+	tc.mu.Unlock()
+	if tier != nil {
+		if stored, ok := tier.Get(key); ok {
+			if storedAt, ok := parseTombstone(stored); ok && storedAt.After(at) {
+				at, buried = storedAt, true
+			}
+		}
+	}
+	if !buried || clock.since(at) >= tc.TombstoneWindow {
+		return true
+	}
+	date, ok := entryDate(resp)
+	return ok && date.After(at)
+}
+
+// buried returns true if key was deleted through this TieredCache within the TombstoneWindow
+func (tc *TieredCache) buried(key string) bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	at, ok := tc.tombstones[key]
+	return ok && clock.since(at) < tc.TombstoneWindow
+}
+
+// parseTombstone returns the deletion time recorded in b and true if b is a tombstone record
+func parseTombstone(b []byte) (time.Time, bool) {
+	if !bytes.HasPrefix(b, []byte(tombstonePrefix)) {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, string(b[len(tombstonePrefix):]))
+	return at, err == nil
+}
+
+// entryDate returns the Date of the response serialized in b
+func entryDate(b []byte) (time.Time, bool) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), nil)
+	if err != nil {
+		return time.Time{}, false
+	}
+	resp.Body.Close()
+	date, err := responseDate(resp.Header)
+	return date, err == nil
+}
+
+// Keys returns the keys of the back tier if it implements KeyLister, and otherwise the keys
+// this TieredCache has stored or loaded. The keys deleted through this TieredCache are left out
+// while their tombstone lasts, but the keys deleted by other processes aren't.
+func (tc *TieredCache) Keys() []string {
+	if kl, ok := tc.Back.(KeyLister); ok {
+		var keys []string
+		for _, key := range kl.Keys() {
+			if key != tieredAccessKey && !tc.buried(key) {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	keys := make([]string, 0, len(tc.entries))
+	for key := range tc.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (tc *TieredCache) touch(key string, inFront bool) {
+	tc.mu.Lock()
+	e := tc.entries[key]
+	e.accessed = time.Now()
+	e.hits++
+	e.inFront = inFront
+	tc.entries[key] = e
+	tc.mu.Unlock()
+}
+
+// DemoteIdle removes from the front tier every entry that hasn't been accessed for at least
+// idle. The back tier copy is left untouched, so a later Get will promote the entry again.
+// It returns the number of demoted entries.
+func (tc *TieredCache) DemoteIdle(idle time.Duration) int {
+	var idleKeys []string
+	tc.mu.Lock()
+	for key, e := range tc.entries {
+		if e.expired() {
+			delete(tc.entries, key)
+			continue
+		}
+		if e.inFront && clock.since(e.accessed) >= idle {
+			e.inFront = false
+			tc.entries[key] = e
+			idleKeys = append(idleKeys, key)
+		}
+	}
+	tc.mu.Unlock()
+
+	for _, key := range idleKeys {
+		tc.Front.Delete(key)
+	}
+	return len(idleKeys)
+}
+
+// SaveAccessMetadata persists the access time and hit count of every known key into the
+// back tier, so that a future process can use Warm to preload its front tier
+func (tc *TieredCache) SaveAccessMetadata() {
+	tc.mu.Lock()
+	records := make([]tierAccessRecord, 0, len(tc.entries))
+	for key, e := range tc.entries {
+		records = append(records, tierAccessRecord{Key: key, Accessed: e.accessed, Hits: e.hits, TTL: e.ttl, Stored: e.stored})
+	}
+	tc.mu.Unlock()
+
+	b, err := json.Marshal(records)
+	if err != nil {
+		return
+	}
+	tc.Back.Set(tieredAccessKey, b, 0)
+}
+
+// Warm preloads the front tier with at most n entries from the back tier, picked in the given
+// order from the access metadata saved by SaveAccessMetadata. The saved access times only order the
+// entries: loaded entries are considered accessed when loaded, and keep the TTL they have left. It is meant to be called on startup,
+// before the cache starts serving requests, and returns the number of loaded entries.
+func (tc *TieredCache) Warm(n int, order WarmOrder) int {
+	b, ok := tc.Back.Get(tieredAccessKey)
+	if !ok {
+		return 0
+	}
+	var records []tierAccessRecord
+	if err := json.Unmarshal(b, &records); err != nil {
+		return 0
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if order == WarmMostFrequent && records[i].Hits != records[j].Hits {
+			return records[i].Hits > records[j].Hits
+		}
+		return records[i].Accessed.After(records[j].Accessed)
+	})
+
+	loaded := 0
+	for _, r := range records {
+		if loaded >= n {
+			break
+		}
+		resp, ok := tc.Back.Get(r.Key)
+		if !ok {
+			continue
+		}
+		if _, buried := parseTombstone(resp); buried || !tc.acceptable(r.Key, resp, nil) {
+			continue
+		}
+		// Loading counts as an access, so that the entry isn't demoted right away by DemoteIdle
+		e := tierEntry{accessed: time.Now(), hits: r.Hits, ttl: r.TTL, stored: r.Stored, inFront: true}
+		if e.expired() {
+			continue
+		}
+		tc.Front.Set(r.Key, resp, e.remainingTTL())
+		tc.mu.Lock()
+		tc.entries[r.Key] = e
+		tc.mu.Unlock()
+		loaded++
+	}
+	return loaded
+}
+
+// StartMaintenance runs DemoteIdle(idle) every interval in a background goroutine until
+// the returned stop function is called. The access metadata is saved into the back tier
+// after every run, and one last time when stopping.
+func (tc *TieredCache) StartMaintenance(interval, idle time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				tc.DemoteIdle(idle)
+				tc.SaveAccessMetadata()
+			case <-done:
+				tc.SaveAccessMetadata()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
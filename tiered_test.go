@@ -0,0 +1,266 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTieredCachePromotesBackHits(t *testing.T) {
+	resetTest()
+	front, back := NewMemoryCache(), NewMemoryCache()
+	tc := NewTieredCache(front, back)
+
+	back.Set("key", []byte("value"), 0)
+	if _, ok := front.Get("key"); ok {
+		t.Fatal("front tier contains key before it was read")
+	}
+	if v, ok := tc.Get("key"); !ok || string(v) != "value" {
+		t.Fatalf("got %q, %v, want value, true", v, ok)
+	}
+	if _, ok := front.Get("key"); !ok {
+		t.Fatal("back tier hit wasn't promoted into the front tier")
+	}
+}
+
+func TestTieredCachePromoteTTL(t *testing.T) {
+	resetTest()
+	front, back := NewMemoryCache(), NewMemoryCache()
+	tc := NewTieredCache(front, back)
+	tc.PromoteTTL = 10
+
+	// Stored by another process: the promoted copy is bounded by PromoteTTL
+	back.Set("remote", []byte("value"), 0)
+	tc.Get("remote")
+	// Stored through this cache: the promoted copy keeps the entry TTL
+	tc.Set("local", []byte("value"), 0)
+	front.Delete("local")
+	tc.Get("local")
+
+	clock = &fakeClock{elapsed: time.Minute}
+	if _, ok := front.Get("remote"); ok {
+		t.Fatal("promoted remote entry outlived PromoteTTL in the front tier")
+	}
+	if _, ok := front.Get("local"); !ok {
+		t.Fatal("promoted local entry expired, want its own TTL kept")
+	}
+}
+
+func TestTieredCacheDemoteIdle(t *testing.T) {
+	resetTest()
+	front, back := NewMemoryCache(), NewMemoryCache()
+	tc := NewTieredCache(front, back)
+	tc.Set("key", []byte("value"), 0)
+
+	if n := tc.DemoteIdle(time.Minute); n != 0 {
+		t.Fatalf("demoted %d entries, want 0", n)
+	}
+
+	clock = &fakeClock{elapsed: 2 * time.Minute}
+	if n := tc.DemoteIdle(time.Minute); n != 1 {
+		t.Fatalf("demoted %d entries, want 1", n)
+	}
+	if _, ok := front.Get("key"); ok {
+		t.Fatal("idle entry still present in the front tier")
+	}
+	if _, ok := back.Get("key"); !ok {
+		t.Fatal("idle entry was removed from the back tier")
+	}
+	if _, ok := tc.Get("key"); !ok {
+		t.Fatal("demoted entry can't be read back")
+	}
+	if _, ok := front.Get("key"); !ok {
+		t.Fatal("demoted entry wasn't promoted again on read")
+	}
+}
+
+func TestTieredCacheWarm(t *testing.T) {
+	resetTest()
+	back := NewMemoryCache()
+	tc := NewTieredCache(NewMemoryCache(), back)
+	tc.Set("cold", []byte("1"), 0)
+	tc.Set("hot", []byte("2"), 0)
+	for i := 0; i < 3; i++ {
+		tc.Get("hot")
+	}
+	tc.Get("cold")
+	tc.SaveAccessMetadata()
+
+	front := NewMemoryCache()
+	restarted := NewTieredCache(front, back)
+	if n := restarted.Warm(1, WarmMostFrequent); n != 1 {
+		t.Fatalf("warmed %d entries, want 1", n)
+	}
+	if _, ok := front.Get("hot"); !ok {
+		t.Fatal("most frequently used entry wasn't loaded")
+	}
+	if _, ok := front.Get("cold"); ok {
+		t.Fatal("loaded more entries than requested")
+	}
+
+	front = NewMemoryCache()
+	restarted = NewTieredCache(front, back)
+	restarted.Warm(1, WarmMostRecent)
+	if _, ok := front.Get("cold"); !ok {
+		t.Fatal("most recently used entry wasn't loaded")
+	}
+}
+
+func TestTieredCacheWarmedEntriesArentIdle(t *testing.T) {
+	resetTest()
+	back := NewMemoryCache()
+	tc := NewTieredCache(NewMemoryCache(), back)
+	tc.Set("key", []byte("1"), 0)
+	tc.mu.Lock()
+	e := tc.entries["key"]
+	e.accessed = time.Now().Add(-time.Hour)
+	tc.entries["key"] = e
+	tc.mu.Unlock()
+	tc.SaveAccessMetadata()
+
+	// The process restarts after a downtime longer than the idle threshold
+	restarted := NewTieredCache(NewMemoryCache(), back)
+	if n := restarted.Warm(1, WarmMostRecent); n != 1 {
+		t.Fatalf("warmed %d entries, want 1", n)
+	}
+	if n := restarted.DemoteIdle(time.Minute); n != 0 {
+		t.Fatalf("demoted %d entries right after warming, want 0", n)
+	}
+}
+
+func TestTieredCacheForgetsKeys(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	front, back := NewMemoryCache(), NewMemoryCache()
+	tc := NewTieredCache(front, back)
+	tc.Set("expiring", []byte("1"), 1)
+	tc.Set("removed", []byte("2"), 0)
+	tc.Set("kept", []byte("3"), 0)
+
+	// A key gone from both tiers is forgotten once looked up
+	front.Delete("removed")
+	back.Delete("removed")
+	tc.Get("removed")
+
+	// A key whose TTL has passed is forgotten on the next sweep
+	clock = &fakeClock{elapsed: 2 * time.Second}
+	tc.DemoteIdle(time.Hour)
+
+	if keys := tc.Keys(); len(keys) != 1 || keys[0] != "kept" {
+		t.Fatalf("got keys %v, want [kept]", keys)
+	}
+}
+
+// tieredEntry returns a serialized response with the given Date
+func tieredEntry(date time.Time) []byte {
+	return []byte("HTTP/1.1 200 OK\r\nDate: " + date.UTC().Format(http.TimeFormat) + "\r\nContent-Length: 4\r\n\r\nbody")
+}
+
+func TestTieredCacheTombstones(t *testing.T) {
+	resetTest()
+	front, back := NewMemoryCache(), NewMemoryCache()
+	tc := NewTieredCache(front, back)
+	tc.TombstoneWindow = time.Minute
+	old := tieredEntry(time.Now().Add(-time.Hour))
+
+	tc.Set("key", old, 0)
+	tc.Delete("key")
+	if _, ok := tc.Get("key"); ok {
+		t.Fatal("deleted entry still present")
+	}
+
+	// A slower tier, or another process, writes back the copy it had
+	back.Set("key", old, 0)
+	front.Delete("key")
+	if _, ok := tc.Get("key"); ok {
+		t.Fatal("old copy read back after its deletion")
+	}
+	if v, _ := front.Get("key"); string(v) == string(old) {
+		t.Fatal("old copy promoted after its deletion")
+	}
+	back.Delete("key")
+	tc.Set("key", old, 0)
+	if _, ok := tc.Get("key"); ok {
+		t.Fatal("old copy stored back after its deletion")
+	}
+
+	// Responses fetched after the deletion are stored
+	fresh := tieredEntry(time.Now().Add(2 * time.Second))
+	tc.Set("key", fresh, 0)
+	if v, ok := tc.Get("key"); !ok || string(v) != string(fresh) {
+		t.Fatalf("got %q, %v, want the response fetched after the deletion", v, ok)
+	}
+}
+
+func TestTieredCacheTombstonesFromOtherProcesses(t *testing.T) {
+	resetTest()
+	back := NewMemoryCache()
+	deleter := NewTieredCache(NewMemoryCache(), back)
+	writer := NewTieredCache(NewMemoryCache(), back)
+	deleter.TombstoneWindow, writer.TombstoneWindow = time.Minute, time.Minute
+
+	deleter.Delete("key")
+	writer.Set("key", tieredEntry(time.Now().Add(-time.Hour)), 0)
+	if _, ok := writer.Get("key"); ok {
+		t.Fatal("response fetched before a remote deletion was stored")
+	}
+	if _, ok := deleter.Get("key"); ok {
+		t.Fatal("remote write undid the deletion")
+	}
+}
+
+func TestTieredCacheTombstonesExpire(t *testing.T) {
+	resetTest()
+	tc := NewTieredCache(NewMemoryCache(), NewMemoryCache())
+	tc.TombstoneWindow = 10 * time.Millisecond
+	tc.Delete("key")
+	time.Sleep(20 * time.Millisecond)
+	tc.Set("key", []byte("value"), 0)
+	if v, ok := tc.Get("key"); !ok || string(v) != "value" {
+		t.Fatalf("got %q, %v, want writes accepted once the window elapsed", v, ok)
+	}
+}
+
+func TestTieredCacheTombstonesHiddenFromKeys(t *testing.T) {
+	tc := NewTieredCache(NewMemoryCache(), NewMemoryCache())
+	tc.TombstoneWindow = time.Minute
+	tc.Set("a", []byte("1"), 0)
+	tc.Set("b", []byte("2"), 0)
+	tc.Delete("a")
+	if keys := tc.Keys(); len(keys) != 1 || keys[0] != "b" {
+		t.Fatalf("got keys %v, want [b]", keys)
+	}
+}
+
+func TestTieredCacheRemainingTTL(t *testing.T) {
+	resetTest()
+	defer resetTest()
+	back := NewMemoryCache()
+	front := &ttlRecordingCache{MemoryCache: NewMemoryCache(), ttls: map[string]int{}}
+	tc := NewTieredCache(front, back)
+	tc.Set("key", []byte("value"), 600)
+	tc.SaveAccessMetadata()
+
+	clock = &fakeClock{elapsed: 4 * time.Minute}
+	front.Delete("key")
+	tc.Get("key")
+	if ttl := front.ttls["key"]; ttl != 360 {
+		t.Fatalf("promoted with a %ds TTL, want the remaining 360s", ttl)
+	}
+
+	front = &ttlRecordingCache{MemoryCache: NewMemoryCache(), ttls: map[string]int{}}
+	restarted := NewTieredCache(front, back)
+	if n := restarted.Warm(1, WarmMostRecent); n != 1 {
+		t.Fatalf("warmed %d entries, want 1", n)
+	}
+	if ttl := front.ttls["key"]; ttl != 360 {
+		t.Fatalf("warmed with a %ds TTL, want the remaining 360s", ttl)
+	}
+
+	clock = &fakeClock{elapsed: 11 * time.Minute}
+	front = &ttlRecordingCache{MemoryCache: NewMemoryCache(), ttls: map[string]int{}}
+	restarted = NewTieredCache(front, back)
+	if n := restarted.Warm(1, WarmMostRecent); n != 0 {
+		t.Fatalf("warmed %d expired entries, want 0", n)
+	}
+}
@@ -0,0 +1,37 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// TransformMark selects how the responses modified by the Transform option are flagged, so that
+// correctness-sensitive consumers can tell them apart from the origin payloads (RFC 9110 section
+// 15.3.4). Marks can be combined.
+type TransformMark int
+
+const (
+	// MarkStatus203 changes the status of transformed 200 responses to 203 Non-Authoritative Information
+	MarkStatus203 TransformMark = 1 << iota
+	// MarkWarning adds a "214 Transformation Applied" Warning header to transformed responses
+	MarkWarning
+)
+
+// transformWarning is the Warning header value added by MarkWarning
+const transformWarning = `214 - "Transformation Applied"`
+
+// transform applies the Transform option to resp, a response received from the origin, and marks
+// it as configured if it was modified
+func (cc *CachedClient) transform(req *http.Request, resp *http.Response) {
+	if cc.Options.Transform == nil || !cc.Options.Transform(req, resp) {
+		return
+	}
+	cc.log(req.Context(), fmt.Sprintf("[httpcache](%p) response transformed", req))
+	if cc.Options.MarkTransformed&MarkStatus203 != 0 && resp.StatusCode == http.StatusOK {
+		resp.StatusCode = http.StatusNonAuthoritativeInfo
+		resp.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	if cc.Options.MarkTransformed&MarkWarning != 0 {
+		resp.Header.Add("Warning", transformWarning)
+	}
+}
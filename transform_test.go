@@ -0,0 +1,115 @@
+package httpcache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransform(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("secret=1&public=2"))
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name    string
+		mark    TransformMark
+		status  int
+		warning string
+	}{
+		{"unmarked", 0, http.StatusOK, ""},
+		{"203", MarkStatus203, http.StatusNonAuthoritativeInfo, ""},
+		{"warning", MarkWarning, http.StatusOK, transformWarning},
+		{"both", MarkStatus203 | MarkWarning, http.StatusNonAuthoritativeInfo, transformWarning},
+	} {
+		client := &CachedClient{
+			Cache:     NewMemoryCache(),
+			Transport: &http.Transport{},
+			Options: CacheOptions{
+				MarkCachedResponses: true,
+				MarkTransformed:     tc.mark,
+				Transform: func(req *http.Request, resp *http.Response) bool {
+					body, _ := ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+					body = bytes.Replace(body, []byte("secret=1&"), nil, 1)
+					resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+					resp.ContentLength = int64(len(body))
+					resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+					return true
+				},
+			},
+		}
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "public=2" {
+				t.Fatalf("%s: got body %q, want the transformed body", tc.name, body)
+			}
+			if resp.StatusCode != tc.status || resp.Header.Get("Warning") != tc.warning {
+				t.Fatalf("%s: got status %d and warning %q, want %d and %q", tc.name, resp.StatusCode, resp.Header.Get("Warning"), tc.status, tc.warning)
+			}
+			if cached := resp.Header.Get(XFromCache) != ""; cached != (i == 1) {
+				t.Fatalf("%s: request %d served from cache: %v", tc.name, i, cached)
+			}
+		}
+	}
+}
+
+func TestTransformCoalesced(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+	client := &CachedClient{
+		Cache:     NewMemoryCache(),
+		Transport: &http.Transport{},
+		Options: CacheOptions{
+			CoalesceRequests: true,
+			MarkTransformed:  MarkStatus203,
+			Transform:        func(req *http.Request, resp *http.Response) bool { return true },
+		},
+	}
+
+	const n = 4
+	var wg sync.WaitGroup
+	statuses := make([]int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				return
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			statuses[i] = resp.StatusCode
+		}(i)
+	}
+	// Give every goroutine a chance to join the in-flight request
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, status := range statuses {
+		if status != http.StatusNonAuthoritativeInfo {
+			t.Fatalf("caller %d got status %d, want every caller to get the transformed response", i, status)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// variantIndexSuffix is appended to a response cache key to build the key of the index listing the
+// variants stored for that response (see CacheOptions.StoreVariants)
+const variantIndexSuffix = " variants"
+
+// varyNames returns the canonical names of the request headers listed in the Vary header of
+// respHeaders, or nil if it lists none or *
+func varyNames(respHeaders http.Header) []string {
+	var names []string
+	for _, header := range headerAllCommaSepValues(respHeaders, "vary") {
+		if header == "*" {
+			return nil
+		}
+		if header != "" {
+			names = append(names, http.CanonicalHeaderKey(header))
+		}
+	}
+	return names
+}
+
+// variantKey returns the key under which the variant of the response stored under key that was
+// selected by reqHeaders is stored, names being the headers the response varies on
+func (cc *CachedClient) variantKey(key string, names []string, reqHeaders http.Header) string {
+	values := url.Values{}
+	for _, name := range names {
+		values.Set(name, cc.varyValue(name, reqHeaders.Get(name)))
+	}
+	return key + " variant:" + values.Encode()
+}
+
+// storeVariant stores respBytes, the response to req stored under key, as the variant selected by
+// req, so that it can still be served once the response under key is replaced by another variant
+func (cc *CachedClient) storeVariant(req *http.Request, key string, respHeaders http.Header, respBytes []byte, started time.Time) {
+	names := varyNames(respHeaders)
+	if !cc.Options.StoreVariants || len(names) == 0 {
+		return
+	}
+	ctx := req.Context()
+	unlock := cc.owner().keyLocks.lock(key)
+	defer unlock()
+	if cc.buriedSince(key, started) {
+		return
+	}
+	vkey := cc.variantKey(key, names, req.Header)
+	if err := cc.backend(ctx).Set(ctx, vkey, respBytes, cc.entryTTL(ctx, key)); err != nil {
+		return
+	}
+	cc.addVariant(ctx, key, vkey)
+}
+
+func (cc *CachedClient) addVariant(ctx context.Context, key, vkey string) {
+	owner := cc.owner()
+	owner.mu.Lock()
+	defer owner.mu.Unlock()
+
+	indexKey := key + variantIndexSuffix
+	index, _ := cc.backend(ctx).Get(ctx, indexKey)
+	for _, existing := range strings.Split(string(index), "\n") {
+		if existing == vkey {
+			return
+		}
+	}
+	if len(index) > 0 {
+		index = append(index, '\n')
+	}
+	cc.backend(ctx).Set(ctx, indexKey, append(index, vkey...), 0)
+}
+
+// lookupVariant returns the stored variant of the response cached under key, resp, that matches
+// req, or nil if there is none
+func (cc *CachedClient) lookupVariant(req *http.Request, key string, resp *http.Response) *http.Response {
+	if !cc.Options.StoreVariants {
+		return nil
+	}
+	names := varyNames(resp.Header)
+	if len(names) == 0 {
+		return nil
+	}
+	variant, err := cachedResponse(cc.backend(req.Context()), cc.variantKey(key, names, req.Header), req)
+	if err != nil {
+		return nil
+	}
+	if !cc.varyMatches(variant, req) {
+		variant.Body.Close()
+		return nil
+	}
+	return variant
+}
+
+// purgeVariants removes every variant stored for the response stored under key
+func (cc *CachedClient) purgeVariants(ctx context.Context, key string) {
+	if !cc.Options.StoreVariants {
+		return
+	}
+	owner := cc.owner()
+	owner.mu.Lock()
+	defer owner.mu.Unlock()
+
+	indexKey := key + variantIndexSuffix
+	index, err := cc.backend(ctx).Get(ctx, indexKey)
+	if err != nil {
+		return
+	}
+	for _, vkey := range strings.Split(string(index), "\n") {
+		cc.backend(ctx).Delete(ctx, vkey)
+	}
+	cc.backend(ctx).Delete(ctx, indexKey)
+}
@@ -0,0 +1,52 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStoreVariants(t *testing.T) {
+	for _, store := range []bool{false, true} {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.Header().Set("Vary", "Accept")
+			w.Write([]byte(r.Header.Get("Accept")))
+		}))
+		cache := NewMemoryCache()
+		client := &CachedClient{Cache: cache, Transport: &http.Transport{}, Options: CacheOptions{StoreVariants: store}}
+		for _, accept := range []string{"application/json", "text/xml", "application/json", "text/xml"} {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			req.Header.Set("Accept", accept)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != accept {
+				t.Fatalf("got body %q for Accept %q", body, accept)
+			}
+		}
+		server.Close()
+
+		want := 4
+		if store {
+			want = 2
+		}
+		if requests != want {
+			t.Fatalf("store variants %v: got %d origin requests, want %d", store, requests, want)
+		}
+		if !store {
+			continue
+		}
+		client.evictEntry(context.Background(), server.URL)
+		if keys := cache.Keys(); len(keys) != 0 {
+			t.Fatalf("got keys %v after eviction, want the variants removed", keys)
+		}
+	}
+}
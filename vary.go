@@ -0,0 +1,64 @@
+package httpcache
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// A VaryNormalizer returns the canonical form of value, the value of the request header called name
+// when it is listed in the Vary header of a response, so that requests whose values only differ
+// textually match the same entry. See CacheOptions.NormalizeVary.
+type VaryNormalizer func(name, value string) string
+
+// acceptHeaders are the content negotiation headers whose values are case-insensitive lists
+var acceptHeaders = map[string]bool{
+	"Accept":          true,
+	"Accept-Charset":  true,
+	"Accept-Encoding": true,
+	"Accept-Language": true,
+}
+
+// NormalizeAcceptHeaders is a VaryNormalizer for the Accept, Accept-Charset, Accept-Encoding and
+// Accept-Language headers. Their values are lowercased, stripped of whitespace and quality values,
+// and sorted, so that "gzip, deflate, br" and "br,gzip;q=0.8,deflate" are the same. Entries with a
+// quality value of 0, which are refused rather than accepted, are kept as is. Other headers are
+// left unchanged.
+func NormalizeAcceptHeaders(name, value string) string {
+	if !acceptHeaders[http.CanonicalHeaderKey(name)] {
+		return value
+	}
+	seen := map[string]bool{}
+	var items []string
+	for _, item := range strings.Split(strings.ToLower(value), ",") {
+		params := strings.Split(item, ";")
+		for i := range params {
+			params[i] = strings.Replace(strings.TrimSpace(params[i]), " ", "", -1)
+		}
+		kept := params[:1]
+		for _, param := range params[1:] {
+			if !strings.HasPrefix(param, "q=") {
+				kept = append(kept, param)
+			} else if strings.Trim(strings.TrimPrefix(param, "q="), "0.") == "" {
+				kept = append(kept, "q=0")
+			}
+		}
+		item = strings.Join(kept, ";")
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// varyValue returns value, a value of the request header name, as compared to select stored
+// responses varying on it
+func (cc *CachedClient) varyValue(name, value string) string {
+	if cc.Options.NormalizeVary != nil {
+		return cc.Options.NormalizeVary(name, value)
+	}
+	return value
+}
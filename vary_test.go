@@ -0,0 +1,62 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeAcceptHeaders(t *testing.T) {
+	for _, c := range []struct {
+		name, value, want string
+	}{
+		{"Accept-Encoding", "gzip, deflate, br", "br,deflate,gzip"},
+		{"accept-encoding", "br,GZIP;q=0.8, deflate ;q=1", "br,deflate,gzip"},
+		{"Accept-Encoding", "gzip, identity;q=0, gzip", "gzip,identity;q=0"},
+		{"Accept-Encoding", "gzip;q=0.0", "gzip;q=0"},
+		{"Accept", "text/html;level=1, application/json", "application/json,text/html;level=1"},
+		{"Accept-Language", "en-US, fr;q=0.5", "en-us,fr"},
+		{"Accept-Encoding", "", ""},
+		{"User-Agent", "Go, x", "Go, x"},
+	} {
+		if got := NormalizeAcceptHeaders(c.name, c.value); got != c.want {
+			t.Errorf("NormalizeAcceptHeaders(%q, %q) = %q, want %q", c.name, c.value, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeVary(t *testing.T) {
+	for _, normalize := range []bool{false, true} {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Cache-Control", "max-age=3600")
+			w.Header().Set("Vary", "Accept-Encoding")
+			w.Write([]byte("body"))
+		}))
+		client := &CachedClient{Cache: NewMemoryCache(), Transport: &http.Transport{}}
+		if normalize {
+			client.Options.NormalizeVary = NormalizeAcceptHeaders
+		}
+		for _, encoding := range []string{"gzip, br", "br,gzip", "BR, gzip;q=0.9"} {
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		server.Close()
+
+		want := 3
+		if normalize {
+			want = 1
+		}
+		if requests != want {
+			t.Fatalf("normalize %v: got %d origin requests, want %d", normalize, requests, want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/lggomez/httpcache/v2/zstdcodec
+
+go 1.17
+
+require (
+	github.com/klauspost/compress v1.17.9
+	github.com/lggomez/httpcache/v2 v2.0.0
+)
+
+replace github.com/lggomez/httpcache/v2 => ../
@@ -0,0 +1,129 @@
+// Package zstdcodec compresses cached responses with Zstandard, optionally using a dictionary
+// trained on previously cached responses. For large numbers of similar responses (such as JSON
+// API payloads), a dictionary cuts the size of each entry far beyond generic compression.
+//
+// It is a separate module so that the main one stays free of dependencies.
+package zstdcodec
+
+import (
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"github.com/lggomez/httpcache/v2"
+)
+
+// Codec compresses and decompresses entries with Zstandard. It is safe for concurrent use, and
+// implements httpcache.Codec so it can be used with httpcache.NewCompressedCache.
+type Codec struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+}
+
+// New returns a new Codec, compressing with dictionary if not nil. Entries compressed with a
+// dictionary can only be decompressed by a Codec using the same dictionary.
+func New(dictionary []byte) (*Codec, error) {
+	var eopts []zstd.EOption
+	var dopts []zstd.DOption
+	if dictionary != nil {
+		eopts = append(eopts, zstd.WithEncoderDict(dictionary))
+		dopts = append(dopts, zstd.WithDecoderDicts(dictionary))
+	}
+	enc, err := zstd.NewWriter(nil, eopts...)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil, dopts...)
+	if err != nil {
+		enc.Close()
+		return nil, err
+	}
+	return &Codec{enc: enc, dec: dec}, nil
+}
+
+// Compress returns the compressed form of b
+func (c *Codec) Compress(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	return c.enc.EncodeAll(b, nil), nil
+}
+
+// Decompress returns the original form of b, compressed by Compress
+func (c *Codec) Decompress(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	return c.dec.DecodeAll(b, nil)
+}
+
+// Close releases the resources of the codec
+func (c *Codec) Close() {
+	c.enc.Close()
+	c.dec.Close()
+}
+
+// Cache is a httpcache.Cache wrapper compressing entries with a Codec before storing them in the
+// wrapped cache. Entries that can't be decompressed are reported as missing.
+type Cache struct {
+	inner httpcache.Cache
+	codec *Codec
+}
+
+// NewCache returns a new Cache storing entries in inner, compressed with codec
+func NewCache(inner httpcache.Cache, codec *Codec) *Cache {
+	return &Cache{inner: inner, codec: codec}
+}
+
+// Get returns the []byte representation of the response and true if present, false if not
+func (c *Cache) Get(key string) (resp []byte, ok bool) {
+	b, ok := c.inner.Get(key)
+	if !ok {
+		return nil, false
+	}
+	resp, err := c.codec.Decompress(b)
+	if err != nil {
+		return nil, false
+	}
+	return resp, true
+}
+
+// Set saves response resp compressed to the cache with key
+func (c *Cache) Set(key string, resp []byte, ttl int) {
+	b, err := c.codec.Compress(resp)
+	if err != nil {
+		return
+	}
+	c.inner.Set(key, b, ttl)
+}
+
+// Delete removes key from the cache
+func (c *Cache) Delete(key string) {
+	c.inner.Delete(key)
+}
+
+// Keys returns the keys of the wrapped cache, if it implements httpcache.KeyLister
+func (c *Cache) Keys() []string {
+	if kl, ok := c.inner.(httpcache.KeyLister); ok {
+		return kl.Keys()
+	}
+	return nil
+}
+
+// TrainDictionary builds a dictionary of at most maxSize bytes from up to maxSamples entries of c,
+// which must implement httpcache.KeyLister (otherwise httpcache.ErrNotEnumerable is returned).
+// Training needs a good amount of representative entries: a few hundreds at least.
+func TrainDictionary(c httpcache.Cache, maxSamples, maxSize int) ([]byte, error) {
+	kl, ok := c.(httpcache.KeyLister)
+	if !ok {
+		return nil, httpcache.ErrNotEnumerable
+	}
+	var samples [][]byte
+	for _, key := range kl.Keys() {
+		if len(samples) >= maxSamples {
+			break
+		}
+		if b, ok := c.Get(key); ok && len(b) > 0 {
+			samples = append(samples, b)
+		}
+	}
+	return dict.BuildZstdDict(samples, dict.Options{MaxDictSize: maxSize, HashBytes: 6})
+}
@@ -0,0 +1,82 @@
+package zstdcodec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lggomez/httpcache/v2"
+	"github.com/lggomez/httpcache/v2/test"
+)
+
+func TestCache(t *testing.T) {
+	codec, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer codec.Close()
+	test.Cache(t, NewCache(httpcache.NewMemoryCache(), codec))
+	test.Cache(t, httpcache.NewCompressedCache(httpcache.NewMemoryCache(), codec))
+}
+
+// jsonEntry returns a serialized JSON API response, similar to those of other ids
+func jsonEntry(id int) []byte {
+	body := fmt.Sprintf(`{"id":%d,"type":"customer","attributes":{"name":"Customer %d","email":"customer%d@example.com","status":"active","plan":"enterprise","created_at":"2020-01-%02dT10:00:00Z"},"links":{"self":"https://api.example.com/v1/customers/%d"}}`,
+		id, id, id, id%28+1, id)
+	return []byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nCache-Control: max-age=300\r\nContent-Length: %d\r\n\r\n%s", len(body), body))
+}
+
+func TestTrainDictionary(t *testing.T) {
+	samples := httpcache.NewMemoryCache()
+	for i := 0; i < 500; i++ {
+		samples.Set(fmt.Sprintf("https://api.example.com/v1/customers/%d", i), jsonEntry(i), 0)
+	}
+	dictionary, err := TrainDictionary(samples, 500, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	trained, err := New(dictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trained.Close()
+
+	entry := jsonEntry(1234)
+	withoutDict, _ := plain.Compress(entry)
+	withDict, _ := trained.Compress(entry)
+	if len(withDict) >= len(withoutDict) {
+		t.Fatalf("got %d bytes with the dictionary and %d without, want smaller entries", len(withDict), len(withoutDict))
+	}
+	back, err := trained.Decompress(withDict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(back) != string(entry) {
+		t.Fatal("entry changed through compression")
+	}
+	if _, err := plain.Decompress(withDict); err == nil {
+		t.Fatal("entry decompressed without its dictionary")
+	}
+}
+
+func TestTrainDictionaryNotEnumerable(t *testing.T) {
+	codec, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer codec.Close()
+	c := unlisted{NewCache(httpcache.NewMemoryCache(), codec)}
+	if _, err := TrainDictionary(c, 10, 1024); err != httpcache.ErrNotEnumerable {
+		t.Fatalf("got error %v, want ErrNotEnumerable", err)
+	}
+}
+
+// unlisted hides the KeyLister implementation of the wrapped Cache
+type unlisted struct {
+	httpcache.Cache
+}